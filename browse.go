@@ -0,0 +1,154 @@
+package stardict
+
+import (
+	"sort"
+)
+
+// Terms returns the headword of entry followed by its synonyms,
+// the returned slice must not be modified
+func (e *IdxEntry) Terms() []string {
+	return e.terms
+}
+
+// Offset returns the position of article in .dict file
+func (e *IdxEntry) Offset() uint64 {
+	return e.offset
+}
+
+// Size returns the size of article in .dict file
+func (e *IdxEntry) Size() uint64 {
+	return e.size
+}
+
+// EntryCount returns the number of entries in index
+func (idx *Idx) EntryCount() int {
+	return len(idx.entries)
+}
+
+// Entry returns the entry with the given index, or nil if out of range
+func (idx *Idx) Entry(entryIndex int) *IdxEntry {
+	if entryIndex < 0 || entryIndex >= len(idx.entries) {
+		return nil
+	}
+	return idx.entries[entryIndex]
+}
+
+// Walk calls fn for each entry in .idx order, until fn returns false
+func (idx *Idx) Walk(fn func(entryIndex int, entry *IdxEntry) bool) {
+	for entryIndex, entry := range idx.entries {
+		if !fn(entryIndex, entry) {
+			return
+		}
+	}
+}
+
+// Headword is a headword or synonym of an entry
+type Headword struct {
+	Term       string
+	EntryIndex int
+	// Synonym is true for terms from .syn file
+	Synonym bool
+}
+
+// buildHeadwordList returns all terms of idx sorted by CompareTerms
+func buildHeadwordList(idx *Idx) []Headword {
+	list := make([]Headword, 0, len(idx.entries))
+	for entryIndex, entry := range idx.entries {
+		for i, term := range entry.terms {
+			list = append(list, Headword{
+				Term:       term,
+				EntryIndex: entryIndex,
+				Synonym:    i > 0,
+			})
+		}
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		return CompareTerms(list[i].Term, list[j].Term) < 0
+	})
+	return list
+}
+
+// headwordList returns the sorted headword list, built on first call
+func (d *dictionaryImp) headwordList(idx *Idx) []Headword {
+	d.headwordOnce.Do(func() {
+		d.headwords = buildHeadwordList(idx)
+	})
+	return d.headwords
+}
+
+// WalkEntries calls fn for each entry in .idx order, until fn returns
+// false. The lock on the index is released before calling fn, so fn can
+// use the dictionary (like EntryByIndex or searches), while entries stay
+// valid even if the dictionary is unloaded meanwhile.
+func (d *dictionaryImp) WalkEntries(fn func(entryIndex int, entry *IdxEntry) bool) error {
+	idx, release, err := d.acquire()
+	if err != nil {
+		return err
+	}
+	// entries are never modified after loading
+	entries := idx.entries
+	release()
+	for entryIndex, entry := range entries {
+		if !fn(entryIndex, entry) {
+			break
+		}
+	}
+	return nil
+}
+
+// WalkHeadwords calls fn for each headword and synonym in alphabetical
+// order (see CompareTerms), until fn returns false. Like WalkEntries,
+// fn is called without holding the lock on the index.
+func (d *dictionaryImp) WalkHeadwords(fn func(Headword) bool) error {
+	idx, release, err := d.acquire()
+	if err != nil {
+		return err
+	}
+	// the list is never modified after it is built
+	list := d.headwordList(idx)
+	release()
+	for _, hw := range list {
+		if !fn(hw) {
+			break
+		}
+	}
+	return nil
+}
+
+// HeadwordsAfter returns up to limit headwords and synonyms that are
+// equal to or after term in alphabetical order
+func (d *dictionaryImp) HeadwordsAfter(term string, limit int) ([]Headword, error) {
+	idx, release, err := d.acquire()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	if limit <= 0 {
+		return nil, nil
+	}
+	list := d.headwordList(idx)
+	start := sort.Search(len(list), func(i int) bool {
+		return CompareTerms(list[i].Term, term) >= 0
+	})
+	end := start + min(limit, len(list)-start)
+	return append([]Headword(nil), list[start:end]...), nil
+}
+
+// HeadwordsBefore returns up to limit headwords and synonyms that are
+// before term in alphabetical order, the result is in alphabetical order
+func (d *dictionaryImp) HeadwordsBefore(term string, limit int) ([]Headword, error) {
+	idx, release, err := d.acquire()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	if limit <= 0 {
+		return nil, nil
+	}
+	list := d.headwordList(idx)
+	end := sort.Search(len(list), func(i int) bool {
+		return CompareTerms(list[i].Term, term) >= 0
+	})
+	start := max(end-limit, 0)
+	return append([]Headword(nil), list[start:end]...), nil
+}
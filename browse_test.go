@@ -0,0 +1,102 @@
+package stardict
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestHeadwords(t *testing.T) {
+	d := openTestDict(t, testEntries)
+	if err := d.Load(); err != nil {
+		t.Fatal(err)
+	}
+	var terms []string
+	err := d.WalkHeadwords(func(hw Headword) bool {
+		terms = append(terms, hw.Term)
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"apple", "apples", "banana", "hello world", "hi"}
+	if !reflect.DeepEqual(terms, expected) {
+		t.Fatalf("expected %v, got %v", expected, terms)
+	}
+
+	after, err := d.HeadwordsAfter("b", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(after) != 2 || after[0].Term != "banana" || after[1].Term != "hello world" || after[1].Synonym {
+		t.Fatalf("unexpected headwords after b: %+v", after)
+	}
+	before, err := d.HeadwordsBefore("banana", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(before) != 2 || before[1].Term != "apples" || !before[1].Synonym {
+		t.Fatalf("unexpected headwords before banana: %+v", before)
+	}
+	if after, _ := d.HeadwordsAfter("b", math.MaxInt); len(after) != 3 {
+		t.Fatalf("unexpected headwords after b: %+v", after)
+	}
+	for _, limit := range []int{0, -1} {
+		after, err := d.HeadwordsAfter("b", limit)
+		if err != nil || after != nil {
+			t.Fatalf("unexpected headwords after b with limit %d: %+v, %v", limit, after, err)
+		}
+		before, err := d.HeadwordsBefore("banana", limit)
+		if err != nil || before != nil {
+			t.Fatalf("unexpected headwords before banana with limit %d: %+v, %v", limit, before, err)
+		}
+	}
+
+	count := 0
+	err = d.WalkEntries(func(entryIndex int, entry *IdxEntry) bool {
+		if entry.Size() == 0 || len(entry.Terms()) == 0 {
+			t.Fatalf("invalid entry %d", entryIndex)
+		}
+		count++
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != len(testEntries) {
+		t.Fatalf("expected %d entries, got %d", len(testEntries), count)
+	}
+}
+
+func TestWalkHeadwordsReentrant(t *testing.T) {
+	d := openTestDict(t, testEntries)
+	if err := d.Load(); err != nil {
+		t.Fatal(err)
+	}
+	d.SetErrorHandler(func(error) {})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_ = d.WalkHeadwords(func(hw Headword) bool {
+			closed := make(chan struct{})
+			go func() {
+				d.Close()
+				close(closed)
+			}()
+			select {
+			case <-closed:
+			case <-time.After(time.Second):
+				t.Error("Close waits for WalkHeadwords callback")
+			}
+			// would block forever if the read lock was still held
+			_ = d.EntryByIndex(hw.EntryIndex)
+			return false
+		})
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("deadlock in WalkHeadwords callback")
+	}
+}
@@ -0,0 +1,365 @@
+package stardict
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/ilius/go-stardict/v2/murmur3"
+)
+
+// indexCacheVersion must be incremented whenever the layout of cache files
+// (or the way Idx is built) changes, so old snapshots are ignored
+const indexCacheVersion = 2
+
+const indexCacheExt = ".idxcache"
+
+var indexCacheMagic = []byte("GOSDIDX\x00")
+
+var errIndexCacheInvalid = errors.New("invalid index cache file")
+
+// IndexCacheDir is the directory where snapshots of parsed indexes are stored,
+// keyed by hash of the .idx file, offset size and cache format version.
+// It defaults to DefaultIndexCacheDir(), set it to empty string to
+// disable caching.
+var IndexCacheDir = DefaultIndexCacheDir()
+
+// DefaultIndexCacheDir returns the index cache directory in user's cache
+// directory ($XDG_CACHE_HOME on Linux), or empty string if there is none
+func DefaultIndexCacheDir() string {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(cacheDir, "go-stardict", "idx")
+}
+
+// ClearIndexCache removes all index snapshots (and term index snapshots)
+// from IndexCacheDir
+func ClearIndexCache() error {
+	if IndexCacheDir == "" {
+		return nil
+	}
+	dirEntries, err := os.ReadDir(IndexCacheDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	for _, de := range dirEntries {
+		switch filepath.Ext(de.Name()) {
+		case indexCacheExt, termIndexCacheExt:
+		default:
+			continue
+		}
+		err := os.Remove(filepath.Join(IndexCacheDir, de.Name()))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func hashFile(fpath string) ([]byte, error) {
+	file, err := os.Open(fpath)
+	if err != nil {
+		return nil, err
+	}
+	defer closeCloser(file)
+	hash := murmur3.New128()
+	if _, err := io.Copy(hash, file); err != nil {
+		return nil, err
+	}
+	return hash.Sum(nil), nil
+}
+
+// cacheFileName returns the name of a cache file in IndexCacheDir, the
+// same .idx file is parsed differently with 32 and 64 bit offsets
+func cacheFileName(idxHash []byte, is64 bool, version int, ext string) string {
+	offsetBits := 32
+	if is64 {
+		offsetBits = 64
+	}
+	return fmt.Sprintf("%s-%d-v%d%s", hex.EncodeToString(idxHash), offsetBits, version, ext)
+}
+
+func indexCachePath(idxHash []byte, info *Info) string {
+	return filepath.Join(IndexCacheDir, cacheFileName(idxHash, info.Is64, indexCacheVersion, indexCacheExt))
+}
+
+// readIndexCached is like ReadIndex, but reuses (or creates) a snapshot
+// in IndexCacheDir
+func readIndexCached(idxPath string, synPath string, info *Info, opts indexOptions) (*Idx, error) {
+	if IndexCacheDir == "" {
+		return readIndex(idxPath, synPath, info, opts)
+	}
+	idxHash, err := hashFile(idxPath)
+	if err != nil {
+		return nil, err
+	}
+	var synHash []byte
+	if synPath != "" {
+		synHash, err = hashFile(synPath)
+		if err != nil {
+			return nil, err
+		}
+	}
+	cachePath := indexCachePath(idxHash, info)
+	idx, err := loadIndexCache(cachePath, synHash, opts.normalizer)
+	if err == nil {
+		opts.log().Debug("Loaded index from cache", "path", idxPath, "cache", cachePath)
+		idx.errorHandler = opts.errorHandler
+		return idx, nil
+	}
+	if !os.IsNotExist(err) {
+		opts.log().Warn("ignoring index cache", "cache", cachePath, "err", err)
+	}
+	idx, err = readIndex(idxPath, synPath, info, opts)
+	if err != nil {
+		return nil, err
+	}
+	err = saveIndexCache(cachePath, synHash, idx)
+	if err != nil {
+		idx.handleError(fmt.Errorf("error saving index cache: %w", err))
+	}
+	return idx, nil
+}
+
+// Cache file layout (all integers are uvarint unless noted):
+//
+//	magic (8 bytes), version
+//	len(synHash), synHash
+//	len(normalizerKey), normalizerKey
+//	len(entries), then for each entry:
+//		len(terms), then for each term: len(term), term
+//		len(keys), then for each key: len(key), key
+//		offset, size
+//	len(byWordPrefix), then for each prefix:
+//		prefix rune, len(indexes), indexes...
+//	murmur3-128 checksum of all previous bytes (16 bytes)
+
+func saveIndexCache(cachePath string, synHash []byte, idx *Idx) error {
+	return saveCacheFile(cachePath, func(w *cacheWriter) {
+		writeIndexCache(w, synHash, idx)
+	})
+}
+
+func writeIndexCache(w *cacheWriter, synHash []byte, idx *Idx) {
+	w.write(indexCacheMagic)
+	w.uint(indexCacheVersion)
+	w.bytes(synHash)
+	w.bytes([]byte(idx.normalizer.Key()))
+
+	w.uint(uint64(len(idx.entries)))
+	for _, entry := range idx.entries {
+		w.strings(entry.terms)
+		w.strings(entry.keys)
+		w.uint(entry.offset)
+		w.uint(entry.size)
+	}
+
+	w.uint(uint64(len(idx.byWordPrefix)))
+	for prefix, indexList := range idx.byWordPrefix {
+		w.uint(uint64(prefix))
+		w.uint(uint64(len(indexList)))
+		for _, index := range indexList {
+			w.uint(uint64(index))
+		}
+	}
+}
+
+func loadIndexCache(cachePath string, synHash []byte, normalizer *Normalizer) (*Idx, error) {
+	r, err := readCacheFile(cachePath, indexCacheMagic)
+	if err != nil {
+		return nil, err
+	}
+	if version := r.uint(); version != indexCacheVersion {
+		return nil, fmt.Errorf("%w: version %d", errIndexCacheInvalid, version)
+	}
+	if !bytes.Equal(r.bytes(), synHash) {
+		return nil, fmt.Errorf("%w: synonym file has changed", errIndexCacheInvalid)
+	}
+	if string(r.bytes()) != normalizer.Key() {
+		return nil, fmt.Errorf("%w: normalizer has changed", errIndexCacheInvalid)
+	}
+
+	entryCount := r.count()
+	idx := newIdx(entryCount)
+	idx.normalizer = normalizer
+	for range entryCount {
+		idx.entries = append(idx.entries, &IdxEntry{
+			terms:  r.strings(),
+			keys:   r.strings(),
+			offset: r.uint(),
+			size:   r.uint(),
+		})
+	}
+
+	prefixCount := r.count()
+	for range prefixCount {
+		prefix := rune(r.uint())
+		indexList := make([]int, r.count())
+		for i := range indexList {
+			index := r.uint()
+			if index >= uint64(entryCount) {
+				r.err = errIndexCacheInvalid
+				break
+			}
+			indexList[i] = int(index)
+		}
+		idx.byWordPrefix[prefix] = indexList
+	}
+	if err := r.finish(); err != nil {
+		return nil, err
+	}
+	return idx, nil
+}
+
+// saveCacheFile writes a cache file followed by its checksum,
+// replacing the old file atomically
+func saveCacheFile(cachePath string, write func(w *cacheWriter)) error {
+	err := os.MkdirAll(filepath.Dir(cachePath), 0o755)
+	if err != nil {
+		return err
+	}
+	file, err := os.CreateTemp(filepath.Dir(cachePath), "tmp-*"+filepath.Ext(cachePath))
+	if err != nil {
+		return err
+	}
+	tmpPath := file.Name()
+	hash := murmur3.New128()
+	w := &cacheWriter{w: bufio.NewWriter(io.MultiWriter(file, hash))}
+	write(w)
+	err = w.w.Flush()
+	if err == nil {
+		_, err = file.Write(hash.Sum(nil))
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(tmpPath)
+		return err
+	}
+	// rename is atomic, so concurrent readers never see a partial file
+	return os.Rename(tmpPath, cachePath)
+}
+
+// readCacheFile verifies the checksum and magic of a cache file,
+// and returns a reader positioned after magic
+func readCacheFile(cachePath string, magic []byte) (*cacheReader, error) {
+	data, err := os.ReadFile(cachePath)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < len(magic)+16 {
+		return nil, errIndexCacheInvalid
+	}
+	body := data[:len(data)-16]
+	hash := murmur3.New128()
+	_, _ = hash.Write(body)
+	if !bytes.Equal(hash.Sum(nil), data[len(data)-16:]) {
+		return nil, fmt.Errorf("%w: checksum mismatch", errIndexCacheInvalid)
+	}
+	if !bytes.HasPrefix(body, magic) {
+		return nil, fmt.Errorf("%w: bad magic", errIndexCacheInvalid)
+	}
+	return &cacheReader{data: body, pos: len(magic)}, nil
+}
+
+// cacheWriter writes integers and strings in the layout read by cacheReader
+type cacheWriter struct {
+	w   *bufio.Writer
+	buf [binary.MaxVarintLen64]byte
+}
+
+func (w *cacheWriter) write(b []byte) {
+	_, _ = w.w.Write(b)
+}
+
+func (w *cacheWriter) uint(n uint64) {
+	_, _ = w.w.Write(w.buf[:binary.PutUvarint(w.buf[:], n)])
+}
+
+func (w *cacheWriter) bytes(b []byte) {
+	w.uint(uint64(len(b)))
+	_, _ = w.w.Write(b)
+}
+
+func (w *cacheWriter) strings(list []string) {
+	w.uint(uint64(len(list)))
+	for _, str := range list {
+		w.uint(uint64(len(str)))
+		_, _ = w.w.WriteString(str)
+	}
+}
+
+type cacheReader struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+func (r *cacheReader) uint() uint64 {
+	if r.err != nil {
+		return 0
+	}
+	n, size := binary.Uvarint(r.data[r.pos:])
+	if size <= 0 {
+		r.err = errIndexCacheInvalid
+		return 0
+	}
+	r.pos += size
+	return n
+}
+
+// count reads a length, making sure it can not exceed the remaining data
+func (r *cacheReader) count() int {
+	n := r.uint()
+	if n > uint64(len(r.data)-r.pos) {
+		r.err = errIndexCacheInvalid
+		return 0
+	}
+	return int(n)
+}
+
+// strings reads a list of strings, returns nil for empty list
+func (r *cacheReader) strings() []string {
+	n := r.count()
+	if n == 0 {
+		return nil
+	}
+	list := make([]string, n)
+	for i := range list {
+		list[i] = string(r.bytes())
+	}
+	return list
+}
+
+// finish returns the read error, or an error if data is not fully read
+func (r *cacheReader) finish() error {
+	if r.err != nil {
+		return r.err
+	}
+	if r.pos != len(r.data) {
+		return fmt.Errorf("%w: trailing data", errIndexCacheInvalid)
+	}
+	return nil
+}
+
+func (r *cacheReader) bytes() []byte {
+	n := r.count()
+	if r.err != nil {
+		return nil
+	}
+	b := r.data[r.pos : r.pos+n]
+	r.pos += n
+	return b
+}
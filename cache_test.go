@@ -0,0 +1,107 @@
+package stardict
+
+import (
+	"bytes"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIndexCache(t *testing.T) {
+	IndexCacheDir = t.TempDir()
+	defer func() { IndexCacheDir = "" }()
+
+	d := openTestDict(t, testEntries)
+	expected, err := ReadIndex(d.idxPath, d.synPath, d.Info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Load(); err != nil {
+		t.Fatal(err)
+	}
+	matches, _ := filepath.Glob(filepath.Join(IndexCacheDir, "*"+indexCacheExt))
+	if len(matches) != 1 {
+		t.Fatalf("expected 1 cache file, got %v", matches)
+	}
+	// next Load must read the cache file instead of rebuilding it
+	oldTime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	if err := os.Chtimes(matches[0], oldTime, oldTime); err != nil {
+		t.Fatal(err)
+	}
+	cached, err := NewDictionary(filepath.Dir(d.ifoPath), "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hitLog := &bytes.Buffer{}
+	cached.SetLogger(slog.New(slog.NewTextHandler(hitLog, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	if err := cached.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(hitLog.String(), "Loaded index from cache") {
+		t.Fatalf("cache is not used: %s", hitLog.String())
+	}
+	if stat, err := os.Stat(matches[0]); err != nil || !stat.ModTime().Equal(oldTime) {
+		t.Fatalf("cache file is rewritten: %v", err)
+	}
+	if results := cached.SearchExact("banana", 1, time.Second); len(results) != 1 {
+		t.Fatalf("unexpected results from cached index: %v", results)
+	}
+	synHash, _ := hashFile(d.synPath)
+	idx, err := loadIndexCache(matches[0], synHash, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(idx.entries, expected.entries) {
+		t.Fatalf("entries mismatch: %#v", idx.entries)
+	}
+	if !reflect.DeepEqual(idx.byWordPrefix, expected.byWordPrefix) {
+		t.Fatalf("byWordPrefix mismatch: %#v", idx.byWordPrefix)
+	}
+
+	_, err = loadIndexCache(matches[0], nil, nil)
+	if err == nil {
+		t.Fatal("expected error for changed synonym file")
+	}
+
+	data, _ := os.ReadFile(matches[0])
+	data[len(data)/2] ^= 0xff
+	_ = os.WriteFile(matches[0], data, 0o644)
+	_, err = loadIndexCache(matches[0], synHash, nil)
+	if err == nil {
+		t.Fatal("expected error for corrupted cache file")
+	}
+	// corrupted cache must be replaced
+	d, err = NewDictionary(filepath.Dir(d.ifoPath), "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	logBuf := &bytes.Buffer{}
+	d.SetLogger(slog.New(slog.NewTextHandler(logBuf, nil)))
+	if err := d.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(logBuf.String(), "ignoring index cache") {
+		t.Fatalf("expected warning in dictionary logger, got %#v", logBuf.String())
+	}
+	if _, err := loadIndexCache(matches[0], synHash, nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestIndexCacheKey(t *testing.T) {
+	IndexCacheDir = t.TempDir()
+	defer func() { IndexCacheDir = "" }()
+	hash := []byte{1, 2}
+	info32, info64 := &Info{}, &Info{Is64: true}
+	if indexCachePath(hash, info32) == indexCachePath(hash, info64) {
+		t.Fatal("32 and 64 bit indexes share cache file")
+	}
+	name := cacheFileName(hash, true, 7, indexCacheExt)
+	if name != "0102-64-v7"+indexCacheExt {
+		t.Fatalf("unexpected cache file name %#v", name)
+	}
+}
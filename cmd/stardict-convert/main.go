@@ -0,0 +1,264 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	stardict "github.com/ilius/go-stardict/v2"
+	"github.com/ilius/go-stardict/v2/convert"
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s <command> [options] <input> <output>\n\n", os.Args[0])
+	fmt.Fprintln(os.Stderr, "Commands:")
+	fmt.Fprintln(os.Stderr, "  export    convert a StarDict dictionary (.ifo) into another format")
+	fmt.Fprintln(os.Stderr, "  import    convert another format into a StarDict dictionary (.ifo)")
+	fmt.Fprintln(os.Stderr, "  merge     merge StarDict dictionaries into one")
+	fmt.Fprintln(os.Stderr, "  split     split a StarDict dictionary by letter ranges or size")
+	fmt.Fprintln(os.Stderr, "  filter    keep entries of a StarDict dictionary in a word list or matching a regex")
+	fmt.Fprintln(os.Stderr, "\nExport formats:")
+	for _, format := range convert.Formats() {
+		fmt.Fprintf(os.Stderr, "  %-8s  %-6s  %s\n", format.Name, format.Ext, format.Description)
+	}
+	fmt.Fprintln(os.Stderr, "\nImport formats:")
+	for _, importer := range convert.Importers() {
+		exts := strings.Join(importer.Exts, " ")
+		fmt.Fprintf(os.Stderr, "  %-8s  %-11s  %s\n", importer.Name, exts, importer.Description)
+	}
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	switch os.Args[1] {
+	case "export":
+		exportMain(os.Args[2:])
+	case "import":
+		importMain(os.Args[2:])
+	case "merge":
+		mergeMain(os.Args[2:])
+	case "split":
+		splitMain(os.Args[2:])
+	case "filter":
+		filterMain(os.Args[2:])
+	case "-h", "-help", "--help", "help":
+		usage()
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %#v\n", os.Args[1])
+		usage()
+		os.Exit(2)
+	}
+}
+
+func exportMain(args []string) {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	formatName := flags.String("format", "", "output format, detected from output extension by default")
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s export [-format name] <input.ifo> <output>\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	_ = flags.Parse(args)
+	if flags.NArg() != 2 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	inputPath, outputPath := flags.Arg(0), flags.Arg(1)
+
+	format := convert.FormatByPath(outputPath)
+	if *formatName != "" {
+		format = convert.FormatByName(*formatName)
+	}
+	if format == nil {
+		log.Fatalf("unknown output format, use -format with one of: %s", formatNames())
+	}
+
+	dic, err := stardict.NewDictionary(
+		filepath.Dir(inputPath),
+		strings.TrimSuffix(filepath.Base(inputPath), ".ifo"),
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+	err = dic.Load()
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer dic.Close()
+	err = convert.Export(dic, format, outputPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func importMain(args []string) {
+	flags := flag.NewFlagSet("import", flag.ExitOnError)
+	formatName := flags.String("format", "", "input format, detected from input extension by default")
+	bookName := flags.String("name", "", "book name, read from input by default")
+	compress := flags.Bool("dictzip", false, "write .dict.dz instead of .dict")
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s import [-format name] [-name bookname] [-dictzip] <input> <output.ifo>\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	_ = flags.Parse(args)
+	if flags.NArg() != 2 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	inputPath, outputPath := flags.Arg(0), flags.Arg(1)
+	if filepath.Ext(outputPath) != ".ifo" {
+		log.Fatalf("output must be an .ifo file: %s", outputPath)
+	}
+
+	importer := convert.ImporterByPath(inputPath)
+	if *formatName != "" {
+		importer = convert.ImporterByName(*formatName)
+	}
+	if importer == nil {
+		log.Fatalf("unknown input format, use -format with one of: %s", importerNames())
+	}
+	err := convert.Import(importer, inputPath, outputPath, &convert.ImportOptions{
+		BookName: *bookName,
+		Compress: *compress,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// writeFlags adds the flags of stardict.WriteOptions
+func writeFlags(flags *flag.FlagSet) func() *stardict.WriteOptions {
+	bookName := flags.String("name", "", "book name of output")
+	compress := flags.Bool("dictzip", false, "write .dict.dz instead of .dict")
+	return func() *stardict.WriteOptions {
+		return &stardict.WriteOptions{
+			BookName: *bookName,
+			Compress: *compress,
+		}
+	}
+}
+
+func mergeMain(args []string) {
+	flags := flag.NewFlagSet("merge", flag.ExitOnError)
+	duplicates := flags.String("duplicates", "concat", "handling of duplicate headwords: concat, first or longest")
+	writeOptions := writeFlags(flags)
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s merge [options] <input.ifo>... <output.ifo>\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	_ = flags.Parse(args)
+	if flags.NArg() < 2 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	var strategy stardict.DuplicateStrategy
+	switch *duplicates {
+	case "concat":
+		strategy = stardict.DuplicateConcat
+	case "first":
+		strategy = stardict.DuplicateKeepFirst
+	case "longest":
+		strategy = stardict.DuplicateKeepLongest
+	default:
+		log.Fatalf("invalid -duplicates %#v", *duplicates)
+	}
+	inputs := flags.Args()[:flags.NArg()-1]
+	err := stardict.Merge(inputs, flags.Arg(flags.NArg()-1), strategy, writeOptions())
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func splitMain(args []string) {
+	flags := flag.NewFlagSet("split", flag.ExitOnError)
+	letters := flags.String("letters", "", "comma-separated first letter ranges, like a-f,g-m")
+	maxSize := flags.Int64("size", 0, "maximum size of articles in each part in bytes")
+	writeOptions := writeFlags(flags)
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s split (-letters ranges | -size bytes) [options] <input.ifo> <output-dir>\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	_ = flags.Parse(args)
+	if flags.NArg() != 2 || (*letters == "") == (*maxSize == 0) {
+		flags.Usage()
+		os.Exit(2)
+	}
+	var paths []string
+	var err error
+	if *letters != "" {
+		var ranges []stardict.LetterRange
+		for _, str := range strings.Split(*letters, ",") {
+			r, err := stardict.ParseLetterRange(strings.TrimSpace(str))
+			if err != nil {
+				log.Fatal(err)
+			}
+			ranges = append(ranges, r)
+		}
+		paths, err = stardict.SplitByLetter(flags.Arg(0), flags.Arg(1), ranges, writeOptions())
+	} else {
+		paths, err = stardict.SplitBySize(flags.Arg(0), flags.Arg(1), *maxSize, writeOptions())
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, fpath := range paths {
+		fmt.Println(fpath)
+	}
+}
+
+func filterMain(args []string) {
+	flags := flag.NewFlagSet("filter", flag.ExitOnError)
+	wordsPath := flags.String("words", "", "file with one word per line")
+	pattern := flags.String("regex", "", "regular expression that a headword or synonym must match")
+	writeOptions := writeFlags(flags)
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s filter (-words file | -regex pattern) [options] <input.ifo> <output.ifo>\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	_ = flags.Parse(args)
+	if flags.NArg() != 2 || (*wordsPath == "") == (*pattern == "") {
+		flags.Usage()
+		os.Exit(2)
+	}
+	var keep func(*stardict.RawEntry) bool
+	if *wordsPath != "" {
+		words, err := stardict.ReadWordList(*wordsPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		keep = stardict.FilterWords(words)
+	} else {
+		re, err := regexp.Compile(*pattern)
+		if err != nil {
+			log.Fatal(err)
+		}
+		keep = stardict.FilterRegex(re)
+	}
+	count, err := stardict.Filter(flags.Arg(0), flags.Arg(1), keep, writeOptions())
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("kept %d entries\n", count)
+}
+
+func formatNames() string {
+	var names []string
+	for _, format := range convert.Formats() {
+		names = append(names, format.Name)
+	}
+	return strings.Join(names, ", ")
+}
+
+func importerNames() string {
+	var names []string
+	for _, importer := range convert.Importers() {
+		names = append(names, importer.Name)
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	stardict "github.com/ilius/go-stardict/v2"
+)
+
+func main() {
+	jsonOutput := flag.Bool("json", false, "print differences as JSON Lines")
+	noArticles := flag.Bool("no-articles", false, "do not print line diffs of changed articles")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s [-json] [-no-articles] <old.ifo> <new.ifo>\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	out := bufio.NewWriter(os.Stdout)
+	encoder := json.NewEncoder(out)
+	encoder.SetEscapeHTML(false)
+	count := 0
+	err := stardict.Diff(flag.Arg(0), flag.Arg(1), func(entry *stardict.DiffEntry) error {
+		count++
+		if *noArticles {
+			entry.Lines = nil
+		}
+		if *jsonOutput {
+			return encoder.Encode(entry)
+		}
+		_, err := fmt.Fprintln(out, entry)
+		if err != nil {
+			return err
+		}
+		for _, line := range entry.Lines {
+			_, err := fmt.Fprintln(out, "    "+line)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if flushErr := out.Flush(); err == nil {
+		err = flushErr
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	if count > 0 {
+		os.Exit(1)
+	}
+}
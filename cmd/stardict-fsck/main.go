@@ -0,0 +1,106 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+
+	stardict "github.com/ilius/go-stardict/v2"
+)
+
+func main() {
+	fix := flag.Bool("fix", false, "rewrite .ifo counts and sort .idx / .syn files")
+	quiet := flag.Bool("q", false, "only print errors, not warnings")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s [-fix] [-q] <file.ifo | directory>...\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	var ifoPaths []string
+	for _, arg := range flag.Args() {
+		paths, err := findIfoFiles(arg)
+		if err != nil {
+			log.Fatal(err)
+		}
+		ifoPaths = append(ifoPaths, paths...)
+	}
+
+	exitCode := 0
+	for _, ifoPath := range ifoPaths {
+		var report *stardict.ValidationReport
+		var err error
+		if *fix {
+			report, err = stardict.Fix(ifoPath)
+		} else {
+			report, err = stardict.Validate(ifoPath)
+		}
+		if err != nil {
+			log.Printf("%s: %v", ifoPath, err)
+			exitCode = 1
+			continue
+		}
+		for _, problem := range report.Problems {
+			if *quiet && problem.Severity != stardict.SeverityError {
+				continue
+			}
+			fmt.Println(problem)
+		}
+		if !*fix {
+			if report.HasErrors() {
+				exitCode = 1
+			}
+			continue
+		}
+		if len(report.Problems) == 0 {
+			continue
+		}
+		// check again, some problems (like broken records) can not be fixed
+		after, err := stardict.Validate(ifoPath)
+		if err != nil {
+			log.Printf("%s: %v", ifoPath, err)
+			exitCode = 1
+			continue
+		}
+		if after.HasErrors() {
+			fmt.Printf("%s: could not fix all errors:\n", ifoPath)
+			for _, problem := range after.Problems {
+				if problem.Severity == stardict.SeverityError {
+					fmt.Println(problem)
+				}
+			}
+			exitCode = 1
+			continue
+		}
+		fmt.Printf("%s: fixed metadata and sort order\n", ifoPath)
+	}
+	os.Exit(exitCode)
+}
+
+func findIfoFiles(root string) ([]string, error) {
+	stat, err := os.Stat(root)
+	if err != nil {
+		return nil, err
+	}
+	if !stat.IsDir() {
+		return []string{root}, nil
+	}
+	var paths []string
+	err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && filepath.Ext(path) == ".ifo" {
+			paths = append(paths, path)
+		}
+		return nil
+	})
+	return paths, err
+}
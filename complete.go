@@ -0,0 +1,191 @@
+package stardict
+
+import (
+	"container/heap"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// completionItem is a normalized term of an entry
+type completionItem struct {
+	key        string
+	term       string
+	entryIndex int32
+	synonym    bool
+}
+
+// completionIndex is the list of all terms (including synonyms) sorted
+// by their normalized form, for finding completions of a prefix with
+// binary search
+type completionIndex struct {
+	items []completionItem
+	// keyBytes is the size of keys that are not shared with Idx
+	keyBytes int64
+}
+
+func buildCompletionIndex(idx *Idx) *completionIndex {
+	index := &completionIndex{
+		items: make([]completionItem, 0, len(idx.entries)),
+	}
+	for entryIndex, entry := range idx.entries {
+		for i, term := range entry.terms {
+			var key string
+			if entry.keys != nil {
+				key = entry.keys[i]
+			} else {
+				// ToLower returns term itself if it has no upper case
+				key = strings.ToLower(term)
+				if key != term {
+					index.keyBytes += int64(len(key))
+				}
+			}
+			index.items = append(index.items, completionItem{
+				key:        key,
+				term:       term,
+				entryIndex: int32(entryIndex),
+				synonym:    i > 0,
+			})
+		}
+	}
+	items := index.items
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].key != items[j].key {
+			return items[i].key < items[j].key
+		}
+		return items[i].term < items[j].term
+	})
+	return index
+}
+
+// memorySize returns an estimate of memory used by index in bytes
+func (index *completionIndex) memorySize() int64 {
+	return int64(cap(index.items))*40 + index.keyBytes
+}
+
+// prefixRange returns the range of items whose key starts with prefix
+func (index *completionIndex) prefixRange(prefix string) (int, int) {
+	items := index.items
+	start := sort.Search(len(items), func(i int) bool {
+		return items[i].key >= prefix
+	})
+	// keys with prefix are sorted before other keys after start
+	end := start + sort.Search(len(items)-start, func(i int) bool {
+		return !strings.HasPrefix(items[start+i].key, prefix)
+	})
+	return start, end
+}
+
+// completionIndex returns the completion index, built on first call
+func (d *dictionaryImp) completionIndex(idx *Idx) *completionIndex {
+	d.completionOnce.Do(func() {
+		d.completion = buildCompletionIndex(idx)
+		d.loadMu.Lock()
+		d.idxSize += d.completion.memorySize()
+		d.loadMu.Unlock()
+	})
+	return d.completion
+}
+
+func (item *completionItem) headword() Headword {
+	return Headword{
+		Term:       item.term,
+		EntryIndex: int(item.entryIndex),
+		Synonym:    item.synonym,
+	}
+}
+
+// Complete returns up to limit headwords and synonyms starting with
+// prefix, sorted by their normalized form
+func (d *dictionaryImp) Complete(prefix string, limit int) []Headword {
+	idx, release, err := d.acquire()
+	if err != nil {
+		d.handleError(err)
+		return nil
+	}
+	defer release()
+	if limit <= 0 {
+		return nil
+	}
+	index := d.completionIndex(idx)
+	start, end := index.prefixRange(d.completionKey(prefix))
+	end = min(end, start+limit)
+	result := make([]Headword, 0, end-start)
+	for i := start; i < end; i++ {
+		result = append(result, index.items[i].headword())
+	}
+	return result
+}
+
+// CompleteRanked is like Complete, but returns the limit headwords with
+// highest rank, for example word frequency. Headwords with equal rank
+// are sorted like Complete. It has to rank every completion of prefix.
+func (d *dictionaryImp) CompleteRanked(prefix string, limit int, rank func(term string) int) []Headword {
+	idx, release, err := d.acquire()
+	if err != nil {
+		d.handleError(err)
+		return nil
+	}
+	defer release()
+	if limit <= 0 {
+		return nil
+	}
+	index := d.completionIndex(idx)
+	start, end := index.prefixRange(d.completionKey(prefix))
+	h := &rankHeap{}
+	for i := start; i < end; i++ {
+		item := rankedItem{index: i, rank: rank(index.items[i].term)}
+		if h.Len() < limit {
+			heap.Push(h, item)
+			continue
+		}
+		if item.less((*h)[0]) {
+			continue
+		}
+		(*h)[0] = item
+		heap.Fix(h, 0)
+	}
+	result := make([]Headword, h.Len())
+	for i := len(result) - 1; i >= 0; i-- {
+		item := heap.Pop(h).(rankedItem)
+		result[i] = index.items[item.index].headword()
+	}
+	return result
+}
+
+// completionKey normalizes prefix, keeping trailing space which
+// is significant for completion
+func (d *dictionaryImp) completionKey(prefix string) string {
+	return d.normalizer.Normalize(strings.TrimLeftFunc(prefix, unicode.IsSpace))
+}
+
+type rankedItem struct {
+	index int
+	rank  int
+}
+
+// less returns true if a comes after b in ranked order
+func (a rankedItem) less(b rankedItem) bool {
+	if a.rank != b.rank {
+		return a.rank < b.rank
+	}
+	return a.index > b.index
+}
+
+// rankHeap is a min-heap keeping the best ranked items
+type rankHeap []rankedItem
+
+func (h rankHeap) Len() int           { return len(h) }
+func (h rankHeap) Less(i, j int) bool { return h[i].less(h[j]) }
+func (h rankHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+
+func (h *rankHeap) Push(x any) {
+	*h = append(*h, x.(rankedItem))
+}
+
+func (h *rankHeap) Pop() any {
+	old := *h
+	item := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return item
+}
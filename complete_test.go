@@ -0,0 +1,84 @@
+package stardict
+
+import (
+	"testing"
+)
+
+func TestComplete(t *testing.T) {
+	d := openTestDict(t, testEntries)
+	if err := d.Load(); err != nil {
+		t.Fatal(err)
+	}
+	terms := func(list []Headword) []string {
+		var result []string
+		for _, hw := range list {
+			result = append(result, hw.Term)
+		}
+		return result
+	}
+
+	got := terms(d.Complete("AP", 10))
+	if len(got) != 2 || got[0] != "apple" || got[1] != "apples" {
+		t.Fatalf("unexpected completions: %v", got)
+	}
+	// synonyms from .syn are completed too
+	got = terms(d.Complete("h", 1))
+	if len(got) != 1 || got[0] != "hello world" {
+		t.Fatalf("unexpected completions: %v", got)
+	}
+	got = terms(d.Complete("hello ", 10))
+	if len(got) != 1 {
+		t.Fatalf("unexpected completions: %v", got)
+	}
+
+	freq := map[string]int{"hi": 10, "hello world": 1}
+	got = terms(d.CompleteRanked("h", 1, func(term string) int {
+		return freq[term]
+	}))
+	if len(got) != 1 || got[0] != "hi" {
+		t.Fatalf("unexpected ranked completions: %v", got)
+	}
+	got = terms(d.CompleteRanked("a", 5, func(string) int { return 0 }))
+	if len(got) != 2 || got[0] != "apple" {
+		t.Fatalf("unexpected ranked completions: %v", got)
+	}
+}
+
+func TestCompletionIndex(t *testing.T) {
+	idx := newIdx(5)
+	for _, term := range []string{"b", "Ab", "a", "abc", "ac"} {
+		idx.Add(term, 0, 1)
+	}
+	index := buildCompletionIndex(idx)
+	// only "ab" is allocated by ToLower
+	if index.keyBytes != 2 {
+		t.Fatalf("unexpected keyBytes %d", index.keyBytes)
+	}
+	for prefix, expected := range map[string][2]int{
+		"":   {0, 5},
+		"a":  {0, 4},
+		"ab": {1, 3},
+		"b":  {4, 5},
+		"c":  {5, 5},
+	} {
+		start, end := index.prefixRange(prefix)
+		if start != expected[0] || end != expected[1] {
+			t.Errorf("prefix %#v: expected %v, got %d, %d", prefix, expected, start, end)
+		}
+	}
+}
+
+func TestCompletionIndexLazy(t *testing.T) {
+	d := openTestDict(t, testEntries)
+	if err := d.Load(); err != nil {
+		t.Fatal(err)
+	}
+	size := d.MemorySize()
+	if d.completion != nil {
+		t.Fatal("completion index is built by Load")
+	}
+	d.Complete("a", 1)
+	if d.completion == nil || d.MemorySize() != size+d.completion.memorySize() {
+		t.Fatalf("unexpected memory size %d", d.MemorySize())
+	}
+}
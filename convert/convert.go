@@ -0,0 +1,255 @@
+// Package convert exports StarDict dictionaries to other formats,
+// and imports other formats into StarDict
+package convert
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	common "codeberg.org/ilius/go-dict-commons"
+)
+
+// Entry is a dictionary entry. Terms are the headword followed by
+// its synonyms (alternates).
+type Entry struct {
+	Terms []string
+	Items []*common.SearchResultItem
+}
+
+// Info is the metadata of a dictionary
+type Info struct {
+	Name        string
+	Description string
+}
+
+// Writer writes entries in one format
+type Writer interface {
+	WriteEntry(entry *Entry) error
+	Close() error
+}
+
+// Format is an export format
+type Format struct {
+	Name string
+	// Ext is the extension of output file, like ".txt"
+	Ext         string
+	Description string
+	// NewWriter creates the output file(s)
+	NewWriter func(outputPath string, info *Info) (Writer, error)
+}
+
+var formats = []*Format{
+	{Name: "tabfile", Ext: ".txt", Description: "Tab-separated text", NewWriter: newTabfileWriter},
+	{Name: "jsonl", Ext: ".jsonl", Description: "JSON Lines", NewWriter: newJSONLWriter},
+	{Name: "dictd", Ext: ".index", Description: "dictd (.index and .dict.dz)", NewWriter: newDictdWriter},
+	{Name: "gls", Ext: ".gls", Description: "Babylon source", NewWriter: newGLSWriter},
+	{Name: "xdxf", Ext: ".xdxf", Description: "XDXF", NewWriter: newXDXFWriter},
+	{Name: "csv", Ext: ".csv", Description: "CSV", NewWriter: newCSVWriter},
+	{Name: "sql", Ext: ".sql", Description: "SQL script (for sqlite3 command)", NewWriter: newSQLWriter},
+	{Name: "sqlite", Ext: ".sqlite", Description: "SQLite database", NewWriter: newSQLiteWriter},
+}
+
+// Formats returns the supported export formats
+func Formats() []*Format {
+	return formats
+}
+
+// FormatByName returns the format with the given name, or nil
+func FormatByName(name string) *Format {
+	for _, format := range formats {
+		if format.Name == name {
+			return format
+		}
+	}
+	return nil
+}
+
+// FormatByPath returns the format of output file by its extension, or nil
+func FormatByPath(fpath string) *Format {
+	ext := strings.ToLower(filepath.Ext(fpath))
+	for _, format := range formats {
+		if format.Ext == ext {
+			return format
+		}
+	}
+	return nil
+}
+
+// Export writes all entries of dic into outputPath in the given format,
+// and copies its resources (res directory) next to outputPath
+func Export(dic common.Dictionary, format *Format, outputPath string) error {
+	count, err := dic.EntryCount()
+	if err != nil {
+		return err
+	}
+	w, err := format.NewWriter(outputPath, &Info{
+		Name:        dic.DictName(),
+		Description: dic.Description(),
+	})
+	if err != nil {
+		return err
+	}
+	for i := range count {
+		res := dic.EntryByIndex(i)
+		if res == nil {
+			break
+		}
+		items, err := entryItems(dic, i, res)
+		if err != nil {
+			_ = w.Close()
+			return fmt.Errorf("error reading %#v: %w", res.F_Terms[0], err)
+		}
+		err = w.WriteEntry(&Entry{
+			Terms: res.F_Terms,
+			Items: items,
+		})
+		if err != nil {
+			_ = w.Close()
+			return fmt.Errorf("error writing %#v: %w", res.F_Terms[0], err)
+		}
+	}
+	err = w.Close()
+	if err != nil {
+		return err
+	}
+	return CopyResources(resourceDir(dic), filepath.Join(filepath.Dir(outputPath), "res"))
+}
+
+// itemsReader is implemented by StarDict dictionaries, to report errors
+// of reading articles which Items() of search results hide
+type itemsReader interface {
+	ItemsErr(entryIndex int) ([]*common.SearchResultItem, error)
+}
+
+// entryItems returns the items of entry, or an error if its article
+// can not be read
+func entryItems(dic common.Dictionary, entryIndex int, res *common.SearchResultLow) ([]*common.SearchResultItem, error) {
+	if reader, ok := dic.(itemsReader); ok {
+		return reader.ItemsErr(entryIndex)
+	}
+	return res.Items(), nil
+}
+
+// resourceDir returns the res directory of dic, or empty string if
+// it has none
+func resourceDir(dic common.Dictionary) string {
+	if dir := dic.ResourceDir(); dir != "" {
+		return dir
+	}
+	if dic.InfoPath() == "" {
+		return ""
+	}
+	dir := filepath.Join(filepath.Dir(dic.InfoPath()), "res")
+	if stat, err := os.Stat(dir); err != nil || !stat.IsDir() {
+		return ""
+	}
+	return dir
+}
+
+// CopyResources copies files of srcDir into dstDir recursively,
+// it does nothing if srcDir is empty
+func CopyResources(srcDir string, dstDir string) error {
+	if srcDir == "" {
+		return nil
+	}
+	return filepath.WalkDir(srcDir, func(fpath string, de os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		relPath, err := filepath.Rel(srcDir, fpath)
+		if err != nil {
+			return err
+		}
+		dstPath := filepath.Join(dstDir, relPath)
+		if de.IsDir() {
+			return os.MkdirAll(dstPath, 0o755)
+		}
+		if !de.Type().IsRegular() {
+			return nil
+		}
+		return copyFile(fpath, dstPath)
+	})
+}
+
+func copyFile(srcPath string, dstPath string) error {
+	src, err := os.Open(srcPath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := os.Create(dstPath)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(dst, src)
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+var markupTagRE = regexp.MustCompile(`<[^>]*>`)
+
+// isMarkup returns true for item types that contain markup
+func isMarkup(t rune) bool {
+	switch t {
+	case 'h', 'g', 'x', 'k':
+		return true
+	}
+	return false
+}
+
+// isText returns true for item types that contain plain text
+func isText(t rune) bool {
+	switch t {
+	case 'm', 'l', 't', 'y', 'w':
+		return true
+	}
+	return false
+}
+
+// Text returns the definition as plain text, with markup removed.
+// Binary items and resource lists are skipped.
+func (e *Entry) Text() string {
+	var parts []string
+	for _, item := range e.Items {
+		switch {
+		case isText(item.Type):
+			parts = append(parts, string(item.Data))
+		case isMarkup(item.Type):
+			text := markupTagRE.ReplaceAllString(string(item.Data), "")
+			parts = append(parts, html.UnescapeString(text))
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+// HTML returns the definition as HTML, plain text items are escaped
+func (e *Entry) HTML() string {
+	var parts []string
+	for _, item := range e.Items {
+		switch {
+		case isText(item.Type):
+			text := html.EscapeString(string(item.Data))
+			parts = append(parts, strings.ReplaceAll(text, "\n", "<br>"))
+		case isMarkup(item.Type):
+			parts = append(parts, string(item.Data))
+		}
+	}
+	return strings.Join(parts, "<br>")
+}
+
+// IsHTML returns true if the definition has items with markup
+func (e *Entry) IsHTML() bool {
+	for _, item := range e.Items {
+		if isMarkup(item.Type) {
+			return true
+		}
+	}
+	return false
+}
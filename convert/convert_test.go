@@ -0,0 +1,315 @@
+package convert
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	common "codeberg.org/ilius/go-dict-commons"
+	stardict "github.com/ilius/go-stardict/v2"
+	"github.com/ilius/go-stardict/v2/dictzip"
+)
+
+func init() {
+	stardict.IndexCacheDir = ""
+}
+
+// writeTestDict writes a dictionary with sametypesequence=h,
+// a synonym and a resource file, and returns path of .ifo file
+func writeTestDict(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	entries := []struct {
+		term string
+		defi string
+	}{
+		{"apple", "<b>a fruit</b>\n<img src=\"apple.png\">"},
+		{"banana", "a yellow fruit &amp; more"},
+	}
+	var dictBuf, idxBuf, synBuf bytes.Buffer
+	for _, entry := range entries {
+		idxBuf.WriteString(entry.term)
+		idxBuf.WriteByte(0)
+		_ = binary.Write(&idxBuf, binary.BigEndian, uint32(dictBuf.Len()))
+		_ = binary.Write(&idxBuf, binary.BigEndian, uint32(len(entry.defi)))
+		dictBuf.WriteString(entry.defi)
+	}
+	synBuf.WriteString("apples")
+	synBuf.WriteByte(0)
+	_ = binary.Write(&synBuf, binary.BigEndian, uint32(0))
+	ifo := fmt.Sprintf(
+		"StarDict's dict ifo file\nversion=3.0.0\nbookname=Test\nwordcount=2\nsynwordcount=1\nidxfilesize=%d\nsametypesequence=h\ndescription=A test\n",
+		idxBuf.Len(),
+	)
+	files := map[string][]byte{
+		"test.ifo":      []byte(ifo),
+		"test.idx":      idxBuf.Bytes(),
+		"test.syn":      synBuf.Bytes(),
+		"test.dict":     dictBuf.Bytes(),
+		"res/apple.png": []byte("PNG"),
+	}
+	for name, data := range files {
+		fpath := filepath.Join(dir, name)
+		_ = os.MkdirAll(filepath.Dir(fpath), 0o755)
+		if err := os.WriteFile(fpath, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return filepath.Join(dir, "test.ifo")
+}
+
+func exportTest(t *testing.T, formatName string, outputName string) string {
+	t.Helper()
+	ifoPath := writeTestDict(t)
+	dic, err := stardict.NewDictionary(filepath.Dir(ifoPath), "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := dic.Load(); err != nil {
+		t.Fatal(err)
+	}
+	defer dic.Close()
+	outputPath := filepath.Join(t.TempDir(), outputName)
+	if err := Export(dic, FormatByName(formatName), outputPath); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(filepath.Dir(outputPath), "res", "apple.png"))
+	if err != nil || string(data) != "PNG" {
+		t.Fatalf("resource not copied: %v", err)
+	}
+	return outputPath
+}
+
+func readString(t *testing.T, fpath string) string {
+	t.Helper()
+	data, err := os.ReadFile(fpath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestExportTabfile(t *testing.T) {
+	out := readString(t, exportTest(t, "tabfile", "out.txt"))
+	expected := "apple|apples\t<b>a fruit</b>\\n<img src=\"apple.png\">\n" +
+		"banana\ta yellow fruit &amp; more\n"
+	if out != expected {
+		t.Fatalf("unexpected output:\n%s", out)
+	}
+}
+
+func TestExportJSONL(t *testing.T) {
+	out := readString(t, exportTest(t, "jsonl", "out.jsonl"))
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines, got %d", len(lines))
+	}
+	var entry jsonlEntry
+	if err := json.Unmarshal([]byte(lines[0]), &entry); err != nil {
+		t.Fatal(err)
+	}
+	if entry.Term != "apple" || len(entry.Alternates) != 1 || entry.Items[0].Type != "h" {
+		t.Fatalf("unexpected entry: %#v", entry)
+	}
+}
+
+func TestExportDictd(t *testing.T) {
+	outputPath := exportTest(t, "dictd", "out.index")
+	index := readString(t, outputPath)
+	file, err := os.Open(strings.TrimSuffix(outputPath, ".index") + ".dict.dz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dz, err := dictzip.NewReader(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dz.Close()
+	articles := map[string]string{}
+	for _, line := range strings.Split(strings.TrimSpace(index), "\n") {
+		parts := strings.Split(line, "\t")
+		if len(parts) != 3 {
+			t.Fatalf("bad index line %q", line)
+		}
+		data, err := dz.GetB64(parts[1], parts[2])
+		if err != nil {
+			t.Fatal(err)
+		}
+		articles[parts[0]] = string(data)
+	}
+	if articles["apples"] != "apple\n   a fruit\n   \n" {
+		t.Fatalf("unexpected article: %q", articles["apples"])
+	}
+	if articles["00-database-short"] != "00-database-short\n   Test\n" {
+		t.Fatalf("unexpected short name: %q", articles["00-database-short"])
+	}
+}
+
+func TestExportGLS(t *testing.T) {
+	out := readString(t, exportTest(t, "gls", "out.gls"))
+	if !strings.Contains(out, "#bookname=Test\n") {
+		t.Fatalf("missing bookname:\n%s", out)
+	}
+	if !strings.Contains(out, "\napple|apples\n<b>a fruit</b><br><img src=\"apple.png\">\n\n") {
+		t.Fatalf("unexpected output:\n%s", out)
+	}
+}
+
+func TestExportXDXF(t *testing.T) {
+	out := readString(t, exportTest(t, "xdxf", "out.xdxf"))
+	var doc struct {
+		FullName string `xml:"full_name"`
+		Articles []struct {
+			Keys []string `xml:"k"`
+		} `xml:"ar"`
+	}
+	if err := xml.Unmarshal([]byte(out), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.FullName != "Test" || len(doc.Articles) != 2 || len(doc.Articles[0].Keys) != 2 {
+		t.Fatalf("unexpected document: %#v", doc)
+	}
+}
+
+func TestExportCSV(t *testing.T) {
+	file, err := os.Open(exportTest(t, "csv", "out.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	rows, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 2 || rows[0][0] != "apple" || rows[0][2] != "apples" {
+		t.Fatalf("unexpected rows: %v", rows)
+	}
+}
+
+func TestExportSQL(t *testing.T) {
+	out := readString(t, exportTest(t, "sql", "out.sql"))
+	if !strings.Contains(out, "INSERT INTO alt VALUES (1, 'apples');\n") {
+		t.Fatalf("missing alternate:\n%s", out)
+	}
+	if !strings.HasSuffix(out, "COMMIT;\n") {
+		t.Fatalf("missing commit:\n%s", out)
+	}
+}
+
+func TestExportBrokenEntry(t *testing.T) {
+	ifoPath := writeTestDict(t)
+	// the article of banana is cut
+	dictPath := strings.TrimSuffix(ifoPath, ".ifo") + ".dict"
+	data, _ := os.ReadFile(dictPath)
+	if err := os.WriteFile(dictPath, data[:len(data)-5], 0o644); err != nil {
+		t.Fatal(err)
+	}
+	dic, err := stardict.NewDictionary(filepath.Dir(ifoPath), "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := dic.Load(); err != nil {
+		t.Fatal(err)
+	}
+	defer dic.Close()
+	outputPath := filepath.Join(t.TempDir(), "out.txt")
+	err = Export(dic, FormatByName("tabfile"), outputPath)
+	if !errors.Is(err, stardict.ErrArticleOutOfRange) || !strings.Contains(err.Error(), "banana") {
+		t.Fatalf("expected error for banana, got %v", err)
+	}
+}
+
+// sqlite3 runs query with sqlite3 command, the test is skipped if
+// it is not installed
+func sqlite3(t *testing.T, dbPath string, query string) string {
+	t.Helper()
+	if _, err := exec.LookPath("sqlite3"); err != nil {
+		t.Skip("sqlite3 is not installed")
+	}
+	out, err := exec.Command("sqlite3", dbPath, query).CombinedOutput()
+	if err != nil {
+		t.Fatalf("sqlite3: %v\n%s", err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+func TestExportSQLite(t *testing.T) {
+	dbPath := exportTest(t, "sqlite", "out.sqlite")
+	data, err := os.ReadFile(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(data, []byte("SQLite format 3\x00")) || len(data)%sqlitePageSize != 0 {
+		t.Fatalf("invalid database file of %d bytes", len(data))
+	}
+	out := sqlite3(t, dbPath, `PRAGMA integrity_check;
+SELECT value FROM info WHERE key = 'description';
+SELECT word.term FROM alt JOIN word ON word.id = alt.id WHERE alt.term = 'APPLES' COLLATE NOCASE;
+SELECT definition FROM word WHERE term = 'Banana' COLLATE NOCASE;`)
+	expected := "ok\nA test\napple\na yellow fruit &amp; more"
+	if out != expected {
+		t.Fatalf("expected %#v, got %#v", expected, out)
+	}
+}
+
+func TestSQLiteWriterLarge(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "large.sqlite")
+	w, err := newSQLiteWriter(dbPath, &Info{Name: "Large", Description: strings.Repeat("d", 5000)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	const count = 20000
+	for i := range count {
+		terms := []string{fmt.Sprintf("Term%05d", (i*7919)%count)}
+		if i%3 == 0 {
+			terms = append(terms, fmt.Sprintf("alt%d", i))
+		}
+		if i%1000 == 0 {
+			// longer than local payload of index cells
+			terms = append(terms, strings.Repeat("long", 500)+fmt.Sprint(i))
+		}
+		defi := strings.Repeat("x", i%50)
+		if i%997 == 0 {
+			defi = strings.Repeat("y", 3*sqlitePageSize+i)
+		}
+		err := w.WriteEntry(&Entry{
+			Terms: terms,
+			Items: []*common.SearchResultItem{{Type: 'm', Data: []byte(defi)}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	out := sqlite3(t, dbPath, `PRAGMA integrity_check;
+SELECT count(*) FROM word;
+SELECT count(*) FROM alt;
+SELECT length(value) FROM info WHERE key = 'description';
+SELECT id, length(definition) FROM word WHERE term = 'term07919' COLLATE NOCASE;
+SELECT id FROM alt WHERE term = 'ALT2991' COLLATE NOCASE;
+SELECT id FROM alt WHERE term = '`+strings.Repeat("long", 500)+`5000';`)
+	expected := "ok\n20000\n6687\n5000\n2|1\n2992\n5001"
+	if out != expected {
+		t.Fatalf("expected %#v, got %#v", expected, out)
+	}
+}
+
+func TestFormatByPath(t *testing.T) {
+	if format := FormatByPath("a/b.JSONL"); format == nil || format.Name != "jsonl" {
+		t.Fatalf("unexpected format: %v", format)
+	}
+	if format := FormatByPath("a/b.ifo"); format != nil {
+		t.Fatalf("unexpected format: %v", format)
+	}
+}
@@ -0,0 +1,41 @@
+package convert
+
+import (
+	"encoding/csv"
+	"strings"
+)
+
+// csvWriter writes rows of headword, definition and alternates
+// (separated by ",")
+type csvWriter struct {
+	*fileWriter
+	csv *csv.Writer
+}
+
+func newCSVWriter(outputPath string, _ *Info) (Writer, error) {
+	file, err := createFile(outputPath)
+	if err != nil {
+		return nil, err
+	}
+	return &csvWriter{
+		fileWriter: file,
+		csv:        csv.NewWriter(file),
+	}, nil
+}
+
+func (w *csvWriter) WriteEntry(entry *Entry) error {
+	return w.csv.Write([]string{
+		entry.Terms[0],
+		definition(entry),
+		strings.Join(entry.Terms[1:], ","),
+	})
+}
+
+func (w *csvWriter) Close() error {
+	w.csv.Flush()
+	err := w.csv.Error()
+	if closeErr := w.fileWriter.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
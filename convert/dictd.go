@@ -0,0 +1,303 @@
+package convert
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+
+	stardict "github.com/ilius/go-stardict/v2"
+	"github.com/ilius/go-stardict/v2/dictzip"
+)
+
+const dictdB64 = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/"
+
+// dictdEncode encodes a number in the base64 notation of dictd index
+func dictdEncode(n int) string {
+	if n == 0 {
+		return "A"
+	}
+	var buf []byte
+	for ; n > 0; n >>= 6 {
+		buf = append(buf, dictdB64[n&63])
+	}
+	for i, j := 0, len(buf)-1; i < j; i, j = i+1, j-1 {
+		buf[i], buf[j] = buf[j], buf[i]
+	}
+	return string(buf)
+}
+
+// dictdSortKey returns the key that dictd sorts index by (without
+// -allchars): lowercase, ignoring all but letters, digits and spaces
+func dictdSortKey(term string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == ' ' {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, term)
+}
+
+type dictdIndexLine struct {
+	term   string
+	offset int
+	size   int
+}
+
+// dictdWriter writes .dict.dz file while writing entries, and the sorted
+// .index file on Close. Alternates are extra index lines pointing to
+// the same article.
+type dictdWriter struct {
+	indexPath string
+	dictFile  *os.File
+	dict      *bufio.Writer
+	dz        *dictzip.Writer
+	offset    int
+	lines     []dictdIndexLine
+}
+
+func newDictdWriter(outputPath string, info *Info) (Writer, error) {
+	basePath := strings.TrimSuffix(outputPath, ".index")
+	dictFile, err := os.Create(basePath + ".dict.dz")
+	if err != nil {
+		return nil, err
+	}
+	dict := bufio.NewWriter(dictFile)
+	w := &dictdWriter{
+		indexPath: basePath + ".index",
+		dictFile:  dictFile,
+		dict:      dict,
+		dz:        dictzip.NewWriter(dict),
+	}
+	w.writeArticle([]string{"00-database-utf8"}, "")
+	w.writeArticle([]string{"00-database-short"}, info.Name)
+	if info.Description != "" {
+		w.writeArticle([]string{"00-database-info"}, info.Description)
+	}
+	return w, nil
+}
+
+func dictdTerm(term string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '\t' || r == '\n' {
+			return ' '
+		}
+		return r
+	}, term)
+}
+
+// writeArticle writes headword and indented definition lines
+func (w *dictdWriter) writeArticle(terms []string, text string) {
+	var sb strings.Builder
+	sb.WriteString(dictdTerm(terms[0]))
+	sb.WriteByte('\n')
+	for _, line := range strings.Split(text, "\n") {
+		sb.WriteString("   ")
+		sb.WriteString(line)
+		sb.WriteByte('\n')
+	}
+	// dictzip.Writer keeps data in memory and can not fail here
+	_, _ = w.dz.Write([]byte(sb.String()))
+	for _, term := range terms {
+		w.lines = append(w.lines, dictdIndexLine{
+			term:   dictdTerm(term),
+			offset: w.offset,
+			size:   sb.Len(),
+		})
+	}
+	w.offset += sb.Len()
+}
+
+func (w *dictdWriter) WriteEntry(entry *Entry) error {
+	w.writeArticle(entry.Terms, entry.Text())
+	return nil
+}
+
+func (w *dictdWriter) Close() error {
+	err := w.dz.Close()
+	if err == nil {
+		err = w.dict.Flush()
+	}
+	if closeErr := w.dictFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	sort.SliceStable(w.lines, func(i, j int) bool {
+		return dictdSortKey(w.lines[i].term) < dictdSortKey(w.lines[j].term)
+	})
+	index, err := createFile(w.indexPath)
+	if err != nil {
+		return err
+	}
+	for _, line := range w.lines {
+		_, _ = index.WriteString(line.term)
+		_ = index.WriteByte('\t')
+		_, _ = index.WriteString(dictdEncode(line.offset))
+		_ = index.WriteByte('\t')
+		_, _ = index.WriteString(dictdEncode(line.size))
+		_ = index.WriteByte('\n')
+	}
+	return index.Close()
+}
+
+// dictdDecode decodes a number in the base64 notation of dictd index
+func dictdDecode(str string) (int, error) {
+	if str == "" || len(str) > 10 {
+		return 0, fmt.Errorf("invalid number %#v", str)
+	}
+	n := 0
+	for _, c := range []byte(str) {
+		digit := strings.IndexByte(dictdB64, c)
+		if digit < 0 {
+			return 0, fmt.Errorf("invalid number %#v", str)
+		}
+		n = n<<6 | digit
+	}
+	return n, nil
+}
+
+// dictdArticleText removes the headword line from article, and the
+// indentation common to all lines. It returns the headword (which is
+// one of terms) and the text.
+func dictdArticleText(data []byte, terms []string) (string, string) {
+	lines := strings.Split(strings.TrimRight(string(data), " \t\r\n"), "\n")
+	headword := terms[0]
+	first := strings.TrimSpace(lines[0])
+	for _, term := range terms {
+		if strings.EqualFold(term, first) {
+			headword = term
+			lines = lines[1:]
+			break
+		}
+	}
+	indent := -1
+	for _, line := range lines {
+		trimmed := strings.TrimLeft(line, " \t")
+		if trimmed == "" {
+			continue
+		}
+		if n := len(line) - len(trimmed); indent < 0 || n < indent {
+			indent = n
+		}
+	}
+	for i, line := range lines {
+		switch {
+		case indent <= 0:
+		case len(line) >= indent:
+			lines[i] = line[indent:]
+		default:
+			// blank line
+			lines[i] = ""
+		}
+	}
+	return headword, strings.Trim(strings.Join(lines, "\n"), "\n")
+}
+
+type dictdArticle struct {
+	offset int
+	size   int
+	terms  []string
+}
+
+// dictdInfoKeys maps dictd info headwords to .ifo options
+var dictdInfoKeys = map[string]string{
+	"00-database-short": stardict.I_bookname,
+	"00-database-info":  stardict.I_description,
+	"00-database-url":   "website",
+}
+
+// readDictd reads .index file and .dict (or .dict.dz) file next to it.
+// Index lines pointing to the same article become synonyms.
+func readDictd(indexPath string, w *stardict.Writer) error {
+	basePath := strings.TrimSuffix(indexPath, ".index")
+	var readArticle func(offset int, size int) ([]byte, error)
+	data, err := os.ReadFile(basePath + ".dict")
+	switch {
+	case err == nil:
+		readArticle = func(offset int, size int) ([]byte, error) {
+			if offset+size > len(data) {
+				return nil, fmt.Errorf("article at %d exceeds .dict file", offset)
+			}
+			return data[offset : offset+size], nil
+		}
+	case os.IsNotExist(err):
+		file, err := os.Open(basePath + ".dict.dz")
+		if err != nil {
+			return err
+		}
+		dz, err := dictzip.NewReader(file)
+		if err != nil {
+			_ = file.Close()
+			return err
+		}
+		defer dz.Close()
+		readArticle = func(offset int, size int) ([]byte, error) {
+			return dz.Get(int64(offset), int64(size))
+		}
+	default:
+		return err
+	}
+
+	indexData, err := os.ReadFile(indexPath)
+	if err != nil {
+		return err
+	}
+	var articles []*dictdArticle
+	byPos := map[[2]int]*dictdArticle{}
+	for i, line := range strings.Split(string(indexData), "\n") {
+		line = strings.TrimRight(line, "\r")
+		if line == "" {
+			continue
+		}
+		parts := strings.Split(line, "\t")
+		if len(parts) < 3 {
+			return fmt.Errorf("%s:%d: invalid index line", indexPath, i+1)
+		}
+		offset, err := dictdDecode(parts[1])
+		if err != nil {
+			return fmt.Errorf("%s:%d: %w", indexPath, i+1, err)
+		}
+		size, err := dictdDecode(parts[2])
+		if err != nil {
+			return fmt.Errorf("%s:%d: %w", indexPath, i+1, err)
+		}
+		pos := [2]int{offset, size}
+		article := byPos[pos]
+		if article == nil {
+			article = &dictdArticle{offset: offset, size: size}
+			byPos[pos] = article
+			articles = append(articles, article)
+		}
+		article.terms = append(article.terms, parts[0])
+	}
+
+	w.Options[stardict.I_sametypesequence] = "m"
+	for _, article := range articles {
+		data, err := readArticle(article.offset, article.size)
+		if err != nil {
+			return err
+		}
+		headword, text := dictdArticleText(data, article.terms)
+		if strings.HasPrefix(headword, "00-database-") || strings.HasPrefix(headword, "00database") {
+			if key, ok := dictdInfoKeys[headword]; ok && text != "" {
+				w.Options[key] = text
+			}
+			continue
+		}
+		terms := []string{headword}
+		for _, term := range article.terms {
+			if term != headword {
+				terms = append(terms, term)
+			}
+		}
+		err = w.Add(terms, []byte(text))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package convert
+
+import (
+	"bufio"
+	"os"
+)
+
+// fileWriter is a buffered output file
+type fileWriter struct {
+	file *os.File
+	*bufio.Writer
+}
+
+func createFile(fpath string) (*fileWriter, error) {
+	file, err := os.Create(fpath)
+	if err != nil {
+		return nil, err
+	}
+	return &fileWriter{
+		file:   file,
+		Writer: bufio.NewWriter(file),
+	}, nil
+}
+
+// Close flushes the buffer and closes the file
+func (f *fileWriter) Close() error {
+	err := f.Flush()
+	if closeErr := f.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// definition returns HTML of entry if it has markup, or plain text
+func definition(entry *Entry) string {
+	if entry.IsHTML() {
+		return entry.HTML()
+	}
+	return entry.Text()
+}
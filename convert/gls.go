@@ -0,0 +1,43 @@
+package convert
+
+import (
+	"strings"
+)
+
+// glsWriter writes Babylon source: a header of "#key=value" lines,
+// then for each entry a line of terms separated by "|", a line of
+// definition and an empty line
+type glsWriter struct {
+	*fileWriter
+}
+
+func glsLine(str string) string {
+	str = strings.ReplaceAll(str, "\r", "")
+	return strings.ReplaceAll(str, "\n", "<br>")
+}
+
+func newGLSWriter(outputPath string, info *Info) (Writer, error) {
+	file, err := createFile(outputPath)
+	if err != nil {
+		return nil, err
+	}
+	_, _ = file.WriteString("\n#stripmethod=keep\n#sametypesequence=h\n")
+	_, _ = file.WriteString("#bookname=" + glsLine(info.Name) + "\n")
+	if info.Description != "" {
+		_, _ = file.WriteString("#description=" + glsLine(info.Description) + "\n")
+	}
+	_, _ = file.WriteString("\n")
+	return &glsWriter{fileWriter: file}, nil
+}
+
+func (w *glsWriter) WriteEntry(entry *Entry) error {
+	terms := make([]string, len(entry.Terms))
+	for i, term := range entry.Terms {
+		terms[i] = strings.ReplaceAll(glsLine(term), "|", " ")
+	}
+	_, _ = w.WriteString(strings.Join(terms, "|"))
+	_ = w.WriteByte('\n')
+	_, _ = w.WriteString(glsLine(entry.HTML()))
+	_, err := w.WriteString("\n\n")
+	return err
+}
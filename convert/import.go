@@ -0,0 +1,84 @@
+package convert
+
+import (
+	"path/filepath"
+	"strings"
+
+	stardict "github.com/ilius/go-stardict/v2"
+)
+
+// Importer reads a dictionary in another format
+type Importer struct {
+	Name string
+	// Exts are the extensions of input file, like ".txt"
+	Exts        []string
+	Description string
+	// Read adds entries and options of input file to w
+	Read func(inputPath string, w *stardict.Writer) error
+}
+
+var importers = []*Importer{
+	{Name: "tabfile", Exts: []string{".txt", ".tab"}, Description: "Tab-separated text", Read: readTabfile},
+	{Name: "dictd", Exts: []string{".index"}, Description: "dictd (.index and .dict or .dict.dz)", Read: readDictd},
+	{Name: "xdxf", Exts: []string{".xdxf", ".xml"}, Description: "XDXF", Read: readXDXF},
+}
+
+// Importers returns the supported import formats
+func Importers() []*Importer {
+	return importers
+}
+
+// ImporterByName returns the importer with the given name, or nil
+func ImporterByName(name string) *Importer {
+	for _, importer := range importers {
+		if importer.Name == name {
+			return importer
+		}
+	}
+	return nil
+}
+
+// ImporterByPath returns the importer of input file by its extension, or nil
+func ImporterByPath(fpath string) *Importer {
+	ext := strings.ToLower(filepath.Ext(fpath))
+	for _, importer := range importers {
+		for _, importerExt := range importer.Exts {
+			if importerExt == ext {
+				return importer
+			}
+		}
+	}
+	return nil
+}
+
+// ImportOptions are the options of Import
+type ImportOptions struct {
+	// BookName overrides the name read from input, which defaults to
+	// the input file name
+	BookName string
+	// Compress writes .dict.dz (dictzip) instead of .dict
+	Compress bool
+}
+
+// Import reads inputPath and writes a StarDict dictionary into ifoPath
+// (and the other files next to it). nil opts means default options.
+func Import(importer *Importer, inputPath string, ifoPath string, opts *ImportOptions) error {
+	if opts == nil {
+		opts = &ImportOptions{}
+	}
+	base := filepath.Base(inputPath)
+	w := stardict.NewWriter(
+		filepath.Dir(ifoPath),
+		strings.TrimSuffix(filepath.Base(ifoPath), ".ifo"),
+		strings.TrimSuffix(base, filepath.Ext(base)),
+	)
+	w.Compress = opts.Compress
+	err := importer.Read(inputPath, w)
+	if err != nil {
+		return err
+	}
+	if opts.BookName != "" {
+		w.Options[stardict.I_bookname] = opts.BookName
+	}
+	return w.Close()
+}
@@ -0,0 +1,127 @@
+package convert
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	stardict "github.com/ilius/go-stardict/v2"
+)
+
+type importedEntry struct {
+	terms []string
+	defi  string
+}
+
+// importTest imports inputPath, validates the result and returns its entries
+func importTest(t *testing.T, importerName string, inputPath string, compress bool) (map[string]string, []importedEntry) {
+	t.Helper()
+	ifoPath := filepath.Join(t.TempDir(), "out.ifo")
+	err := Import(ImporterByName(importerName), inputPath, ifoPath, &ImportOptions{Compress: compress})
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, err := stardict.Validate(ifoPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Problems) > 0 {
+		t.Fatalf("unexpected problems: %v", report.Problems)
+	}
+	dic, err := stardict.NewDictionary(filepath.Dir(ifoPath), "out")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := dic.Load(); err != nil {
+		t.Fatal(err)
+	}
+	defer dic.Close()
+	var entries []importedEntry
+	count, _ := dic.EntryCount()
+	for i := range count {
+		res := dic.EntryByIndex(i)
+		entries = append(entries, importedEntry{
+			terms: res.F_Terms,
+			defi:  string(res.Items()[0].Data),
+		})
+	}
+	return dic.Options, entries
+}
+
+func TestImportTabfile(t *testing.T) {
+	inputPath := filepath.Join(t.TempDir(), "test.txt")
+	data := "##name\tMy Dict\r\n" +
+		"banana\ta yellow\\nfruit\r\n" +
+		"\r\n" +
+		"apple|apples|a\\|b\ta fruit\\\\\n"
+	if err := os.WriteFile(inputPath, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	options, entries := importTest(t, "tabfile", inputPath, false)
+	if options[stardict.I_bookname] != "My Dict" {
+		t.Fatalf("unexpected bookname %#v", options[stardict.I_bookname])
+	}
+	expected := []importedEntry{
+		{terms: []string{"apple", "apples", "a|b"}, defi: "a fruit\\"},
+		{terms: []string{"banana"}, defi: "a yellow\nfruit"},
+	}
+	if !reflect.DeepEqual(entries, expected) {
+		t.Fatalf("unexpected entries: %#v", entries)
+	}
+
+	if err := os.WriteFile(inputPath, []byte("no tab\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	err := Import(ImporterByName("tabfile"), inputPath, filepath.Join(t.TempDir(), "x.ifo"), nil)
+	if err == nil {
+		t.Fatal("expected error for line without tab")
+	}
+}
+
+func TestImportDictd(t *testing.T) {
+	// dictd files written by export
+	indexPath := exportTest(t, "dictd", "out.index")
+	options, entries := importTest(t, "dictd", indexPath, true)
+	if options[stardict.I_bookname] != "Test" || options[stardict.I_description] != "A test" {
+		t.Fatalf("unexpected options: %v", options)
+	}
+	expected := []importedEntry{
+		{terms: []string{"apple", "apples"}, defi: "a fruit"},
+		{terms: []string{"banana"}, defi: "a yellow fruit & more"},
+	}
+	if !reflect.DeepEqual(entries, expected) {
+		t.Fatalf("unexpected entries: %#v", entries)
+	}
+}
+
+func TestImportXDXF(t *testing.T) {
+	inputPath := filepath.Join(t.TempDir(), "test.xdxf")
+	data := `<?xml version="1.0" encoding="UTF-8" ?>
+<xdxf lang_from="ENG" lang_to="DEU" format="visual">
+<full_name>Test &amp; more</full_name>
+<description>A <i>test</i></description>
+<ar><k>house</k><k>home</k>
+Haus&nbsp;<b>n</b></ar>
+<ar><k>coffee<opt> house</opt></k>
+Kaffee</ar>
+</xdxf>
+`
+	if err := os.WriteFile(inputPath, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	options, entries := importTest(t, "xdxf", inputPath, false)
+	if options[stardict.I_bookname] != "Test & more" || options[stardict.I_lang] != "eng-deu" {
+		t.Fatalf("unexpected options: %v", options)
+	}
+	if options[stardict.I_description] != "A test" || options[stardict.I_sametypesequence] != "x" {
+		t.Fatalf("unexpected options: %v", options)
+	}
+	expected := []importedEntry{
+		{terms: []string{"coffee house"}, defi: "<k>coffee<opt> house</opt></k>\nKaffee"},
+		{terms: []string{"house", "home"}, defi: "<k>house</k><k>home</k>\nHaus&nbsp;<b>n</b>"},
+	}
+	if !reflect.DeepEqual(entries, expected) {
+		t.Fatalf("unexpected entries: %#v", entries)
+	}
+}
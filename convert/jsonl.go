@@ -0,0 +1,57 @@
+package convert
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"unicode"
+)
+
+// jsonlItem is a definition item, binary data is base64-encoded
+type jsonlItem struct {
+	Type string `json:"type"`
+	Data string `json:"data"`
+}
+
+type jsonlEntry struct {
+	Term       string      `json:"term"`
+	Alternates []string    `json:"alternates,omitempty"`
+	Items      []jsonlItem `json:"items"`
+}
+
+// jsonlWriter writes one JSON object per line, keeping all items
+type jsonlWriter struct {
+	*fileWriter
+	encoder *json.Encoder
+}
+
+func newJSONLWriter(outputPath string, _ *Info) (Writer, error) {
+	file, err := createFile(outputPath)
+	if err != nil {
+		return nil, err
+	}
+	encoder := json.NewEncoder(file)
+	encoder.SetEscapeHTML(false)
+	return &jsonlWriter{
+		fileWriter: file,
+		encoder:    encoder,
+	}, nil
+}
+
+func (w *jsonlWriter) WriteEntry(entry *Entry) error {
+	items := make([]jsonlItem, len(entry.Items))
+	for i, item := range entry.Items {
+		data := string(item.Data)
+		if unicode.IsUpper(item.Type) {
+			data = base64.StdEncoding.EncodeToString(item.Data)
+		}
+		items[i] = jsonlItem{
+			Type: string(item.Type),
+			Data: data,
+		}
+	}
+	return w.encoder.Encode(jsonlEntry{
+		Term:       entry.Terms[0],
+		Alternates: entry.Terms[1:],
+		Items:      items,
+	})
+}
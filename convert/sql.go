@@ -0,0 +1,55 @@
+package convert
+
+import (
+	"strconv"
+	"strings"
+)
+
+// sqlWriter writes an SQL script that creates an SQLite database
+// when piped into sqlite3, with tables:
+//
+//	info(key, value)
+//	word(id, term, definition)
+//	alt(id, term), id is the id of word
+type sqlWriter struct {
+	*fileWriter
+	id int
+}
+
+func sqlQuote(str string) string {
+	return "'" + strings.ReplaceAll(str, "'", "''") + "'"
+}
+
+func newSQLWriter(outputPath string, info *Info) (Writer, error) {
+	file, err := createFile(outputPath)
+	if err != nil {
+		return nil, err
+	}
+	_, _ = file.WriteString(`BEGIN TRANSACTION;
+CREATE TABLE info (key TEXT PRIMARY KEY, value TEXT NOT NULL);
+CREATE TABLE word (id INTEGER PRIMARY KEY, term TEXT NOT NULL, definition TEXT NOT NULL);
+CREATE TABLE alt (id INTEGER NOT NULL, term TEXT NOT NULL);
+`)
+	_, _ = file.WriteString("INSERT INTO info VALUES ('name', " + sqlQuote(info.Name) + ");\n")
+	_, _ = file.WriteString("INSERT INTO info VALUES ('description', " + sqlQuote(info.Description) + ");\n")
+	return &sqlWriter{fileWriter: file}, nil
+}
+
+func (w *sqlWriter) WriteEntry(entry *Entry) error {
+	w.id++
+	id := strconv.Itoa(w.id)
+	_, _ = w.WriteString("INSERT INTO word VALUES (" + id + ", " +
+		sqlQuote(entry.Terms[0]) + ", " + sqlQuote(definition(entry)) + ");\n")
+	for _, alt := range entry.Terms[1:] {
+		_, _ = w.WriteString("INSERT INTO alt VALUES (" + id + ", " + sqlQuote(alt) + ");\n")
+	}
+	return nil
+}
+
+func (w *sqlWriter) Close() error {
+	_, _ = w.WriteString(`CREATE INDEX word_term ON word (term COLLATE NOCASE);
+CREATE INDEX alt_term ON alt (term COLLATE NOCASE);
+COMMIT;
+`)
+	return w.fileWriter.Close()
+}
@@ -0,0 +1,482 @@
+package convert
+
+import (
+	"bufio"
+	"encoding/binary"
+	"os"
+	"sort"
+)
+
+const sqlitePageSize = 4096
+
+// maximum payload stored in a cell, the rest goes to overflow pages,
+// see "B-tree Pages" in https://www.sqlite.org/fileformat.html
+const (
+	sqliteTableMaxLocal = sqlitePageSize - 35
+	sqliteIndexMaxLocal = (sqlitePageSize-12)*64/255 - 23
+	sqliteMinLocal      = (sqlitePageSize-12)*32/255 - 23
+)
+
+// b-tree page types
+const (
+	sqliteIndexInterior = 0x02
+	sqliteTableInterior = 0x05
+	sqliteIndexLeaf     = 0x0a
+	sqliteTableLeaf     = 0x0d
+)
+
+// sqliteSchema is the schema of tables and indexes, like the SQL script
+// of sqlWriter (but info table has no primary key, which needs an index)
+var sqliteSchema = []struct {
+	kind  string
+	name  string
+	table string
+	sql   string
+}{
+	{"table", "info", "info", "CREATE TABLE info (key TEXT NOT NULL, value TEXT NOT NULL)"},
+	{"table", "word", "word", "CREATE TABLE word (id INTEGER PRIMARY KEY, term TEXT NOT NULL, definition TEXT NOT NULL)"},
+	{"table", "alt", "alt", "CREATE TABLE alt (id INTEGER NOT NULL, term TEXT NOT NULL)"},
+	{"index", "word_term", "word", "CREATE INDEX word_term ON word (term COLLATE NOCASE)"},
+	{"index", "alt_term", "alt", "CREATE INDEX alt_term ON alt (term COLLATE NOCASE)"},
+}
+
+// sqliteWriter writes an SQLite database file directly, with the same
+// tables as sqlWriter. Tables are written while writing entries, since
+// rows are added in rowid order, and indexes are sorted and written on
+// Close. Page 1, which has the schema, is written last.
+type sqliteWriter struct {
+	file *os.File
+	buf  *bufio.Writer
+	// pageCount is the number of pages in file
+	pageCount uint32
+
+	info      *Info
+	word      *sqliteTable
+	alt       *sqliteTable
+	wordTerms []sqliteIndexKey
+	altTerms  []sqliteIndexKey
+}
+
+// sqliteIndexKey is an index entry, term of a row and its rowid
+type sqliteIndexKey struct {
+	term  string
+	rowid int64
+}
+
+func newSQLiteWriter(outputPath string, info *Info) (Writer, error) {
+	file, err := os.Create(outputPath)
+	if err != nil {
+		return nil, err
+	}
+	w := &sqliteWriter{
+		file: file,
+		buf:  bufio.NewWriter(file),
+		info: info,
+	}
+	// reserve page 1
+	w.addPage(make([]byte, sqlitePageSize))
+	w.word = &sqliteTable{w: w}
+	w.alt = &sqliteTable{w: w}
+	return w, nil
+}
+
+func (w *sqliteWriter) WriteEntry(entry *Entry) error {
+	id := w.word.lastRowid + 1
+	w.word.add(id, sqliteRecord(nil, entry.Terms[0], definition(entry)))
+	w.wordTerms = append(w.wordTerms, sqliteIndexKey{term: entry.Terms[0], rowid: id})
+	for _, alt := range entry.Terms[1:] {
+		altID := w.alt.lastRowid + 1
+		w.alt.add(altID, sqliteRecord(id, alt))
+		w.altTerms = append(w.altTerms, sqliteIndexKey{term: alt, rowid: altID})
+	}
+	return nil
+}
+
+func (w *sqliteWriter) Close() error {
+	info := &sqliteTable{w: w}
+	info.add(1, sqliteRecord("name", w.info.Name))
+	info.add(2, sqliteRecord("description", w.info.Description))
+	roots := []uint32{
+		info.finish(),
+		w.word.finish(),
+		w.alt.finish(),
+		w.writeIndex(w.wordTerms),
+		w.writeIndex(w.altTerms),
+	}
+
+	schema := &sqlitePage{kind: sqliteTableLeaf, offset: 100}
+	for i, item := range sqliteSchema {
+		record := sqliteRecord(item.kind, item.name, item.table, int64(roots[i]), item.sql)
+		schema.add(sqliteTableCell(int64(i+1), record, w))
+	}
+	page1 := schema.encode(0)
+	w.writeHeader(page1)
+
+	err := w.buf.Flush()
+	if err == nil {
+		_, err = w.file.WriteAt(page1, 0)
+	}
+	if closeErr := w.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// writeHeader writes the database header at the beginning of page 1
+func (w *sqliteWriter) writeHeader(page []byte) {
+	copy(page, "SQLite format 3\x00")
+	binary.BigEndian.PutUint16(page[16:], sqlitePageSize)
+	page[18] = 1 // file format write version
+	page[19] = 1 // file format read version
+	page[21] = 64
+	page[22] = 32
+	page[23] = 32
+	binary.BigEndian.PutUint32(page[24:], 1) // file change counter
+	binary.BigEndian.PutUint32(page[28:], w.pageCount)
+	binary.BigEndian.PutUint32(page[40:], 1) // schema cookie
+	binary.BigEndian.PutUint32(page[44:], 4) // schema format number
+	binary.BigEndian.PutUint32(page[56:], 1) // UTF-8
+	binary.BigEndian.PutUint32(page[92:], 1) // version-valid-for
+	binary.BigEndian.PutUint32(page[96:], 3040001)
+}
+
+// addPage appends a page to file and returns its number
+func (w *sqliteWriter) addPage(page []byte) uint32 {
+	_, _ = w.buf.Write(page)
+	w.pageCount++
+	return w.pageCount
+}
+
+// cell returns prefix followed by payload, writing the part of payload
+// that does not fit into overflow pages
+func (w *sqliteWriter) cell(prefix []byte, payload []byte, maxLocal int) []byte {
+	local := len(payload)
+	if local > maxLocal {
+		local = sqliteMinLocal + (len(payload)-sqliteMinLocal)%(sqlitePageSize-4)
+		if local > maxLocal {
+			local = sqliteMinLocal
+		}
+	}
+	cell := append(prefix, payload[:local]...)
+	if local == len(payload) {
+		return cell
+	}
+	first := w.pageCount + 1
+	rest := payload[local:]
+	for len(rest) > 0 {
+		page := make([]byte, sqlitePageSize)
+		n := copy(page[4:], rest)
+		rest = rest[n:]
+		if len(rest) > 0 {
+			binary.BigEndian.PutUint32(page, w.pageCount+2)
+		}
+		w.addPage(page)
+	}
+	return binary.BigEndian.AppendUint32(cell, first)
+}
+
+func sqliteTableCell(rowid int64, record []byte, w *sqliteWriter) []byte {
+	prefix := sqliteAppendVarint(nil, uint64(len(record)))
+	prefix = sqliteAppendVarint(prefix, uint64(rowid))
+	return w.cell(prefix, record, sqliteTableMaxLocal)
+}
+
+// sqlitePage is a b-tree page being filled
+type sqlitePage struct {
+	kind byte
+	// offset is the offset of page header, 100 for page 1
+	offset int
+	cells  [][]byte
+	size   int
+}
+
+func (p *sqlitePage) headerSize() int {
+	if p.kind == sqliteTableLeaf || p.kind == sqliteIndexLeaf {
+		return 8
+	}
+	return 12
+}
+
+// fits returns true if cell can be added to page
+func (p *sqlitePage) fits(cell []byte) bool {
+	return p.offset+p.headerSize()+p.size+len(cell)+2 <= sqlitePageSize
+}
+
+func (p *sqlitePage) add(cell []byte) {
+	p.cells = append(p.cells, cell)
+	p.size += len(cell) + 2
+}
+
+func (p *sqlitePage) removeLast() []byte {
+	cell := p.cells[len(p.cells)-1]
+	p.cells = p.cells[:len(p.cells)-1]
+	p.size -= len(cell) + 2
+	return cell
+}
+
+// encode returns the page, cells are stored at the end of page
+func (p *sqlitePage) encode(rightChild uint32) []byte {
+	page := make([]byte, sqlitePageSize)
+	header := page[p.offset:]
+	header[0] = p.kind
+	binary.BigEndian.PutUint16(header[3:], uint16(len(p.cells)))
+	if p.headerSize() == 12 {
+		binary.BigEndian.PutUint32(header[8:], rightChild)
+	}
+	content := sqlitePageSize
+	pointer := p.offset + p.headerSize()
+	for _, cell := range p.cells {
+		content -= len(cell)
+		copy(page[content:], cell)
+		binary.BigEndian.PutUint16(page[pointer:], uint16(content))
+		pointer += 2
+	}
+	binary.BigEndian.PutUint16(header[5:], uint16(content))
+	return page
+}
+
+// sqliteChild is a written page with the largest rowid in its subtree
+type sqliteChild struct {
+	page  uint32
+	rowid int64
+}
+
+// sqliteTable writes a table b-tree, rows must be added in rowid order
+type sqliteTable struct {
+	w         *sqliteWriter
+	leaf      *sqlitePage
+	lastRowid int64
+	// leaves are the written leaf pages
+	leaves []sqliteChild
+}
+
+func (t *sqliteTable) add(rowid int64, record []byte) {
+	cell := sqliteTableCell(rowid, record, t.w)
+	if t.leaf == nil {
+		t.leaf = &sqlitePage{kind: sqliteTableLeaf}
+	} else if !t.leaf.fits(cell) {
+		t.flushLeaf()
+	}
+	t.leaf.add(cell)
+	t.lastRowid = rowid
+}
+
+func (t *sqliteTable) flushLeaf() {
+	page := t.w.addPage(t.leaf.encode(0))
+	t.leaves = append(t.leaves, sqliteChild{page: page, rowid: t.lastRowid})
+	t.leaf = &sqlitePage{kind: sqliteTableLeaf}
+}
+
+// finish writes the last leaf and interior pages, and returns root page
+func (t *sqliteTable) finish() uint32 {
+	if t.leaf == nil {
+		t.leaf = &sqlitePage{kind: sqliteTableLeaf}
+	}
+	t.flushLeaf()
+	level := t.leaves
+	for len(level) > 1 {
+		level = t.w.writeTableLevel(level)
+	}
+	return level[0].page
+}
+
+// writeTableLevel writes interior pages for children, and returns them
+func (w *sqliteWriter) writeTableLevel(children []sqliteChild) []sqliteChild {
+	cellSize := func(child sqliteChild) int {
+		return 4 + len(sqliteAppendVarint(nil, uint64(child.rowid))) + 2
+	}
+	// every child is counted as a cell, though the last one is the
+	// right child pointer
+	var groups [][]sqliteChild
+	var group []sqliteChild
+	used := 12
+	for _, child := range children {
+		if used+cellSize(child) > sqlitePageSize {
+			groups = append(groups, group)
+			group, used = nil, 12
+		}
+		group = append(group, child)
+		used += cellSize(child)
+	}
+	groups = append(groups, group)
+	// a page with only the right child would have no cells
+	if n := len(groups); n > 1 && len(groups[n-1]) == 1 {
+		prev := groups[n-2]
+		groups[n-1] = append([]sqliteChild{prev[len(prev)-1]}, groups[n-1]...)
+		groups[n-2] = prev[:len(prev)-1]
+	}
+	parents := make([]sqliteChild, 0, len(groups))
+	for _, group := range groups {
+		page := &sqlitePage{kind: sqliteTableInterior}
+		for _, child := range group[:len(group)-1] {
+			cell := binary.BigEndian.AppendUint32(nil, child.page)
+			page.add(sqliteAppendVarint(cell, uint64(child.rowid)))
+		}
+		last := group[len(group)-1]
+		parents = append(parents, sqliteChild{
+			page:  w.addPage(page.encode(last.page)),
+			rowid: last.rowid,
+		})
+	}
+	return parents
+}
+
+// sqliteNocaseCompare compares like NOCASE collation of SQLite,
+// which only folds ASCII letters
+func sqliteNocaseCompare(a string, b string) int {
+	lower := func(c byte) byte {
+		if c >= 'A' && c <= 'Z' {
+			return c + 'a' - 'A'
+		}
+		return c
+	}
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if ca, cb := lower(a[i]), lower(b[i]); ca != cb {
+			return int(ca) - int(cb)
+		}
+	}
+	return len(a) - len(b)
+}
+
+// writeIndex writes an index b-tree of (term, rowid) and returns root page.
+// Unlike tables, each entry is stored once, either in a leaf or as
+// a divider in an interior page.
+func (w *sqliteWriter) writeIndex(keys []sqliteIndexKey) uint32 {
+	sort.Slice(keys, func(i, j int) bool {
+		if c := sqliteNocaseCompare(keys[i].term, keys[j].term); c != 0 {
+			return c < 0
+		}
+		return keys[i].rowid < keys[j].rowid
+	})
+	cells := make([][]byte, len(keys))
+	for i, key := range keys {
+		record := sqliteRecord(key.term, key.rowid)
+		prefix := sqliteAppendVarint(nil, uint64(len(record)))
+		cells[i] = w.cell(prefix, record, sqliteIndexMaxLocal)
+	}
+
+	var children []uint32
+	var dividers [][]byte
+	for i := 0; i < len(cells) || len(children) == 0; {
+		page := &sqlitePage{kind: sqliteIndexLeaf}
+		for i < len(cells) && page.fits(cells[i]) {
+			page.add(cells[i])
+			i++
+		}
+		if i < len(cells) {
+			// the next page must not be empty
+			if i == len(cells)-1 {
+				page.removeLast()
+				i--
+			}
+			dividers = append(dividers, cells[i])
+			i++
+		}
+		children = append(children, w.addPage(page.encode(0)))
+	}
+
+	for len(children) > 1 {
+		var parents []uint32
+		var parentDividers [][]byte
+		interiorCell := func(i int) []byte {
+			return append(binary.BigEndian.AppendUint32(nil, children[i]), dividers[i]...)
+		}
+		for i := 0; i < len(children); {
+			page := &sqlitePage{kind: sqliteIndexInterior}
+			for i < len(dividers) && page.fits(interiorCell(i)) {
+				page.add(interiorCell(i))
+				i++
+			}
+			if i < len(dividers) && i == len(dividers)-1 {
+				// the next page would have only the right child
+				page.removeLast()
+				i--
+			}
+			parents = append(parents, w.addPage(page.encode(children[i])))
+			if i < len(dividers) {
+				parentDividers = append(parentDividers, dividers[i])
+			}
+			i++
+		}
+		children, dividers = parents, parentDividers
+	}
+	return children[0]
+}
+
+// sqliteRecord encodes values (nil, int64 or string) in record format
+func sqliteRecord(values ...any) []byte {
+	var types, body []byte
+	for _, value := range values {
+		switch value := value.(type) {
+		case nil:
+			types = append(types, 0)
+		case string:
+			types = sqliteAppendVarint(types, uint64(len(value))*2+13)
+			body = append(body, value...)
+		case int64:
+			serialType, size := sqliteIntType(value)
+			types = append(types, serialType)
+			for i := size - 1; i >= 0; i-- {
+				body = append(body, byte(value>>(8*i)))
+			}
+		}
+	}
+	// header size includes its own varint
+	headerSize := len(types) + 1
+	for len(sqliteAppendVarint(nil, uint64(headerSize)))+len(types) != headerSize {
+		headerSize++
+	}
+	record := sqliteAppendVarint(nil, uint64(headerSize))
+	record = append(record, types...)
+	return append(record, body...)
+}
+
+// sqliteIntType returns serial type and size of an integer
+func sqliteIntType(value int64) (byte, int) {
+	switch {
+	case value == 0:
+		return 8, 0
+	case value == 1:
+		return 9, 0
+	case value >= -1<<7 && value < 1<<7:
+		return 1, 1
+	case value >= -1<<15 && value < 1<<15:
+		return 2, 2
+	case value >= -1<<23 && value < 1<<23:
+		return 3, 3
+	case value >= -1<<31 && value < 1<<31:
+		return 4, 4
+	case value >= -1<<47 && value < 1<<47:
+		return 5, 6
+	}
+	return 6, 8
+}
+
+// sqliteAppendVarint appends v in the big-endian varint format of SQLite
+func sqliteAppendVarint(buf []byte, v uint64) []byte {
+	if v>>56 != 0 {
+		var tmp [9]byte
+		tmp[8] = byte(v)
+		v >>= 8
+		for i := 7; i >= 0; i-- {
+			tmp[i] = byte(v&0x7f) | 0x80
+			v >>= 7
+		}
+		return append(buf, tmp[:]...)
+	}
+	var tmp [8]byte
+	n := 0
+	for {
+		tmp[n] = byte(v&0x7f) | 0x80
+		n++
+		v >>= 7
+		if v == 0 {
+			break
+		}
+	}
+	tmp[0] &= 0x7f
+	for i := n - 1; i >= 0; i-- {
+		buf = append(buf, tmp[i])
+	}
+	return buf
+}
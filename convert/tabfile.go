@@ -0,0 +1,132 @@
+package convert
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	stardict "github.com/ilius/go-stardict/v2"
+)
+
+var tabfileEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	"\n", `\n`,
+	"\t", `\t`,
+)
+
+// tabfileWriter writes one entry per line: headword and alternates
+// separated by "|", a tab, and the definition with escaped newlines
+type tabfileWriter struct {
+	*fileWriter
+}
+
+func newTabfileWriter(outputPath string, _ *Info) (Writer, error) {
+	file, err := createFile(outputPath)
+	if err != nil {
+		return nil, err
+	}
+	return &tabfileWriter{fileWriter: file}, nil
+}
+
+func (w *tabfileWriter) WriteEntry(entry *Entry) error {
+	for i, term := range entry.Terms {
+		if i > 0 {
+			_ = w.WriteByte('|')
+		}
+		_, _ = w.WriteString(strings.ReplaceAll(tabfileEscaper.Replace(term), "|", `\|`))
+	}
+	_ = w.WriteByte('\t')
+	_, _ = w.WriteString(tabfileEscaper.Replace(definition(entry)))
+	return w.WriteByte('\n')
+}
+
+// tabfileUnescape replaces escapes of tabfileEscaper (and `\|`),
+// unknown escapes are kept as is
+func tabfileUnescape(str string) string {
+	if !strings.Contains(str, `\`) {
+		return str
+	}
+	var sb strings.Builder
+	for i := 0; i < len(str); i++ {
+		c := str[i]
+		if c != '\\' || i == len(str)-1 {
+			sb.WriteByte(c)
+			continue
+		}
+		i++
+		switch str[i] {
+		case 'n':
+			sb.WriteByte('\n')
+		case 't':
+			sb.WriteByte('\t')
+		case '\\', '|':
+			sb.WriteByte(str[i])
+		default:
+			sb.WriteByte('\\')
+			sb.WriteByte(str[i])
+		}
+	}
+	return sb.String()
+}
+
+// splitTabfileTerms splits headword and alternates on "|",
+// except for escaped `\|`
+func splitTabfileTerms(str string) []string {
+	var terms []string
+	start := 0
+	for i := 0; i < len(str); i++ {
+		switch str[i] {
+		case '\\':
+			i++
+		case '|':
+			terms = append(terms, tabfileUnescape(str[start:i]))
+			start = i + 1
+		}
+	}
+	return append(terms, tabfileUnescape(str[start:]))
+}
+
+// readTabfile reads lines of "headword|alternate\tdefinition".
+// Lines like "##key\tvalue" set options of .ifo file, "##name" is
+// the bookname.
+func readTabfile(inputPath string, w *stardict.Writer) error {
+	file, err := os.Open(inputPath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	w.Options[stardict.I_sametypesequence] = "m"
+	r := bufio.NewReader(file)
+	for lineNum := 1; ; lineNum++ {
+		line, err := r.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return err
+		}
+		if lineNum == 1 {
+			line = strings.TrimPrefix(line, "\ufeff")
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line != "" {
+			termsStr, defi, ok := strings.Cut(line, "\t")
+			if !ok {
+				return fmt.Errorf("%s:%d: missing tab", inputPath, lineNum)
+			}
+			if key, isInfo := strings.CutPrefix(termsStr, "##"); isInfo {
+				if key == "name" {
+					key = stardict.I_bookname
+				}
+				w.Options[key] = tabfileUnescape(defi)
+			} else {
+				addErr := w.Add(splitTabfileTerms(termsStr), []byte(tabfileUnescape(defi)))
+				if addErr != nil {
+					return fmt.Errorf("%s:%d: %w", inputPath, lineNum, addErr)
+				}
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+	}
+}
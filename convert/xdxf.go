@@ -0,0 +1,156 @@
+package convert
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"html"
+	"io"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+
+	stardict "github.com/ilius/go-stardict/v2"
+)
+
+// xdxfWriter writes XDXF in visual format. Articles of type 'x' are
+// written as is, other items as escaped text.
+type xdxfWriter struct {
+	*fileWriter
+}
+
+func newXDXFWriter(outputPath string, info *Info) (Writer, error) {
+	file, err := createFile(outputPath)
+	if err != nil {
+		return nil, err
+	}
+	_, _ = file.WriteString(`<?xml version="1.0" encoding="UTF-8" ?>` + "\n")
+	_, _ = file.WriteString(`<xdxf format="visual">` + "\n")
+	_, _ = file.WriteString("<full_name>" + html.EscapeString(info.Name) + "</full_name>\n")
+	_, _ = file.WriteString("<description>" + html.EscapeString(info.Description) + "</description>\n")
+	return &xdxfWriter{fileWriter: file}, nil
+}
+
+func (w *xdxfWriter) WriteEntry(entry *Entry) error {
+	_, _ = w.WriteString("<ar>")
+	hasKey := false
+	var parts []string
+	for _, item := range entry.Items {
+		switch {
+		case item.Type == 'x':
+			data := string(item.Data)
+			hasKey = hasKey || strings.Contains(data, "<k>")
+			parts = append(parts, data)
+		case isText(item.Type):
+			parts = append(parts, html.EscapeString(string(item.Data)))
+		case isMarkup(item.Type):
+			text := markupTagRE.ReplaceAllString(string(item.Data), "")
+			parts = append(parts, html.EscapeString(html.UnescapeString(text)))
+		}
+	}
+	if !hasKey {
+		for _, term := range entry.Terms {
+			_, _ = w.WriteString("<k>" + html.EscapeString(term) + "</k>")
+		}
+	}
+	_ = w.WriteByte('\n')
+	_, _ = w.WriteString(strings.Join(parts, "\n"))
+	_, err := w.WriteString("\n</ar>\n")
+	return err
+}
+
+func (w *xdxfWriter) Close() error {
+	_, _ = w.WriteString("</xdxf>\n")
+	return w.fileWriter.Close()
+}
+
+var xdxfKeyRE = regexp.MustCompile(`(?s)<k(?:\s[^>]*)?>(.*?)</k>`)
+
+// xdxfKeys returns the unique keys (<k> tags) of article,
+// with markup like <opt> removed
+func xdxfKeys(article string) []string {
+	var keys []string
+	for _, match := range xdxfKeyRE.FindAllStringSubmatch(article, -1) {
+		key := html.UnescapeString(markupTagRE.ReplaceAllString(match[1], ""))
+		key = strings.Join(strings.Fields(key), " ")
+		if key == "" || slices.Contains(keys, key) {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// readXDXF reads articles of XDXF file as 'x' items, keys of each
+// article are its headword and synonyms
+func readXDXF(inputPath string, w *stardict.Writer) error {
+	file, err := os.Open(inputPath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	w.Options[stardict.I_sametypesequence] = "x"
+	decoder := xml.NewDecoder(bufio.NewReader(file))
+	decoder.Entity = xml.HTMLEntity
+	var inner struct {
+		XML string `xml:",innerxml"`
+	}
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", inputPath, err)
+		}
+		elem, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		switch elem.Name.Local {
+		case "xdxf":
+			var langFrom, langTo string
+			for _, attr := range elem.Attr {
+				switch attr.Name.Local {
+				case "lang_from":
+					langFrom = strings.ToLower(attr.Value)
+				case "lang_to":
+					langTo = strings.ToLower(attr.Value)
+				}
+			}
+			if langFrom != "" && langTo != "" {
+				w.Options[stardict.I_lang] = langFrom + "-" + langTo
+			}
+			continue
+		case "full_name", "full_title", "description", "ar":
+		default:
+			continue
+		}
+		inner.XML = ""
+		err = decoder.DecodeElement(&inner, &elem)
+		if err != nil {
+			return fmt.Errorf("%s: %w", inputPath, err)
+		}
+		switch elem.Name.Local {
+		case "full_name", "full_title":
+			w.Options[stardict.I_bookname] = xdxfText(inner.XML)
+		case "description":
+			w.Options[stardict.I_description] = xdxfText(inner.XML)
+		case "ar":
+			keys := xdxfKeys(inner.XML)
+			if len(keys) == 0 {
+				continue
+			}
+			err = w.Add(keys, []byte(strings.TrimSpace(inner.XML)))
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// xdxfText returns text of inner XML, with markup removed
+func xdxfText(str string) string {
+	return strings.TrimSpace(html.UnescapeString(markupTagRE.ReplaceAllString(str, "")))
+}
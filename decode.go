@@ -0,0 +1,169 @@
+package stardict
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+
+	common "codeberg.org/ilius/go-dict-commons"
+)
+
+// DecodeError is returned when an article can not be split into items
+type DecodeError struct {
+	// Offset is the position in article data where decoding failed
+	Offset int
+	// Type is the type of item being decoded, or 0 if unknown
+	Type   rune
+	Reason string
+}
+
+func (e *DecodeError) Error() string {
+	if e.Type == 0 {
+		return fmt.Sprintf("invalid article at %d: %s", e.Offset, e.Reason)
+	}
+	return fmt.Sprintf("invalid %q item at %d: %s", e.Type, e.Offset, e.Reason)
+}
+
+func isUpperItemType(t byte) bool {
+	return t >= 'A' && t <= 'Z'
+}
+
+func isLowerItemType(t byte) bool {
+	return t >= 'a' && t <= 'z'
+}
+
+// isValidItemType returns true for the types defined by StarDict format
+func isValidItemType(t rune) bool {
+	switch t {
+	case 'm', 'l', 'g', 't', 'x', 'y', 'k', 'w', 'h', 'r', 'n', 'W', 'P', 'X':
+		return true
+	}
+	return false
+}
+
+// decodeItems splits article data into typed items.
+// seq is the sametypesequence option, or empty if each item in data is
+// preceded by its type. Text items never include the trailing NUL.
+// On failure, items decoded so far are returned along with a *DecodeError.
+func decodeItems(data []byte, seq string) ([]*common.SearchResultItem, error) {
+	if seq != "" {
+		return decodeWithSametypesequence(data, seq)
+	}
+	return decodeWithoutSametypesequence(data)
+}
+
+// decodeWithSametypesequence decodes data without type bytes, where the
+// last item has no NUL terminator or size, since its end is the end of data
+func decodeWithSametypesequence(data []byte, seq string) (items []*common.SearchResultItem, err error) {
+	pos := 0
+	for i := 0; i < len(seq); i++ {
+		t := seq[i]
+		last := i == len(seq)-1
+		switch {
+		case isLowerItemType(t):
+			if last {
+				items = append(items, &common.SearchResultItem{
+					Type: rune(t),
+					Data: bytes.TrimSuffix(data[pos:], []byte{0}),
+				})
+				return items, nil
+			}
+			item, next, err := decodeTextItem(data, pos, t)
+			if err != nil {
+				return items, err
+			}
+			items = append(items, item)
+			pos = next
+		case isUpperItemType(t):
+			if last {
+				items = append(items, &common.SearchResultItem{
+					Type: rune(t),
+					Data: data[pos:],
+				})
+				return items, nil
+			}
+			item, next, err := decodeBinaryItem(data, pos, t)
+			if err != nil {
+				return items, err
+			}
+			items = append(items, item)
+			pos = next
+		default:
+			return items, &DecodeError{Offset: pos, Reason: fmt.Sprintf("invalid type %q in sametypesequence", t)}
+		}
+	}
+	return items, nil
+}
+
+// decodeWithoutSametypesequence decodes data where each item starts with
+// its type byte, the last text item may not have a NUL terminator
+func decodeWithoutSametypesequence(data []byte) (items []*common.SearchResultItem, err error) {
+	pos := 0
+	for pos < len(data) {
+		t := data[pos]
+		pos++
+		switch {
+		case isLowerItemType(t):
+			if bytes.IndexByte(data[pos:], 0) < 0 {
+				items = append(items, &common.SearchResultItem{
+					Type: rune(t),
+					Data: data[pos:],
+				})
+				return items, nil
+			}
+			item, next, err := decodeTextItem(data, pos, t)
+			if err != nil {
+				return items, err
+			}
+			items = append(items, item)
+			pos = next
+		case isUpperItemType(t):
+			item, next, err := decodeBinaryItem(data, pos, t)
+			if err != nil {
+				return items, err
+			}
+			items = append(items, item)
+			pos = next
+		default:
+			return items, &DecodeError{Offset: pos - 1, Reason: fmt.Sprintf("invalid type byte %q", t)}
+		}
+	}
+	return items, nil
+}
+
+// decodeTextItem decodes a NUL-terminated item starting at pos,
+// and returns the position after the NUL
+func decodeTextItem(data []byte, pos int, t byte) (*common.SearchResultItem, int, error) {
+	end := bytes.IndexByte(data[pos:], 0)
+	if end < 0 {
+		return nil, 0, &DecodeError{Offset: pos, Type: rune(t), Reason: "missing NUL terminator"}
+	}
+	item := &common.SearchResultItem{
+		Type: rune(t),
+		Data: data[pos : pos+end],
+	}
+	return item, pos + end + 1, nil
+}
+
+// decodeBinaryItem decodes an item with 4-byte big-endian size at pos,
+// and returns the position after its data
+func decodeBinaryItem(data []byte, pos int, t byte) (*common.SearchResultItem, int, error) {
+	if len(data)-pos < 4 {
+		return nil, 0, &DecodeError{Offset: pos, Type: rune(t), Reason: "missing size"}
+	}
+	size := uint64(binary.BigEndian.Uint32(data[pos : pos+4]))
+	pos += 4
+	if size > uint64(len(data)-pos) {
+		return nil, 0, &DecodeError{
+			Offset: pos - 4,
+			Type:   rune(t),
+			Reason: fmt.Sprintf("size %d exceeds article data (%d bytes left)", size, len(data)-pos),
+		}
+	}
+	end := pos + int(size)
+	item := &common.SearchResultItem{
+		Type: rune(t),
+		Data: data[pos:end],
+	}
+	return item, end, nil
+}
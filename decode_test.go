@@ -0,0 +1,70 @@
+package stardict
+
+import (
+	"errors"
+	"testing"
+
+	common "codeberg.org/ilius/go-dict-commons"
+)
+
+func itemsString(items []*common.SearchResultItem) string {
+	str := ""
+	for _, item := range items {
+		str += string(item.Type) + ":" + string(item.Data) + ";"
+	}
+	return str
+}
+
+func Test_decodeItems(t *testing.T) {
+	test := func(data string, seq string, expected string, expectErr bool) {
+		t.Helper()
+		items, err := decodeItems([]byte(data), seq)
+		if (err != nil) != expectErr {
+			t.Errorf("data=%q seq=%q: unexpected error: %v", data, seq, err)
+		}
+		if err != nil {
+			var decodeErr *DecodeError
+			if !errors.As(err, &decodeErr) {
+				t.Errorf("data=%q seq=%q: expected *DecodeError, got %T", data, seq, err)
+			}
+		}
+		actual := itemsString(items)
+		if actual != expected {
+			t.Errorf("data=%q seq=%q: expected %q, got %q", data, seq, expected, actual)
+		}
+	}
+	test("hello", "m", "m:hello;", false)
+	test("hello\x00", "m", "m:hello;", false)
+	test("abc\x00def", "tm", "t:abc;m:def;", false)
+	test("abc", "tm", "", true)
+	test("\x00\x00\x00\x02hidef", "Wm", "W:hi;m:def;", false)
+	test("\x00\x00\x00\x09hidef", "Wm", "", true)
+	test("\x00\x00", "Wm", "", true)
+	test("habc\x00mdef", "", "h:abc;m:def;", false)
+	test("habc\x00mdef\x00", "", "h:abc;m:def;", false)
+	test("mabc\x00P\x00\x00\x00\x01x", "", "m:abc;P:x;", false)
+	test("mabc\x00P\x00\x00\x00\x05x", "", "m:abc;", true)
+	test("mabc\x00\x01", "", "m:abc;", true)
+	test("", "", "", false)
+}
+
+func FuzzDecodeItems(f *testing.F) {
+	f.Add([]byte("abc\x00def"), "tm")
+	f.Add([]byte("\x00\x00\x00\x02hidef"), "Wm")
+	f.Add([]byte("habc\x00mdef"), "")
+	f.Add([]byte("mabc\x00P\x00\x00\x00\x01x"), "")
+	f.Fuzz(func(t *testing.T, data []byte, seq string) {
+		items, err := decodeItems(data, seq)
+		var decodeErr *DecodeError
+		if err != nil && !errors.As(err, &decodeErr) {
+			t.Fatalf("expected *DecodeError, got %T", err)
+		}
+		total := 0
+		for _, item := range items {
+			total += len(item.Data)
+		}
+		if total > len(data) {
+			t.Fatalf("items are larger than data: %d > %d", total, len(data))
+		}
+	})
+}
@@ -1,7 +1,9 @@
 package stardict
 
 import (
+	"fmt"
 	"log/slog"
+	"math"
 	"os"
 	"strings"
 	"sync"
@@ -19,10 +21,25 @@ type Dict struct {
 	filename string
 
 	file DictFile
+	// fileLock is held for reading while reading file, and for writing
+	// while closing it
+	fileLock sync.RWMutex
+	// lock serializes reads from .dict.dz reader
 	lock sync.Mutex
 
 	// rawDictFile is only set if we are using .dict, not .dict.dz
 	rawDictFile *os.File
+	// size is the size of (uncompressed) data
+	size uint64
+
+	logger *slog.Logger
+}
+
+func (d *Dict) log() *slog.Logger {
+	if d.logger != nil {
+		return d.logger
+	}
+	return slog.Default()
 }
 
 func (d *Dict) Open() error {
@@ -34,24 +51,37 @@ func (d *Dict) Open() error {
 	var file DictFile
 	if strings.HasSuffix(filename, ".dz") {
 		var err error
-		file, err = dictzip.NewReader(rawFile)
+		dz, err := dictzip.NewReader(rawFile)
 		if err != nil {
-			return err
+			closeCloser(rawFile)
+			return fmt.Errorf("%s: %w", filename, err)
 		}
+		file = dz
+		d.size = uint64(dz.Size())
 	} else {
+		stat, err := rawFile.Stat()
+		if err != nil {
+			closeCloser(rawFile)
+			return err
+		}
 		file = rawFile
 		d.rawDictFile = rawFile
+		d.size = uint64(stat.Size())
 	}
 
 	d.file = file
 	return nil
 }
 
+// Close closes the file, it waits for reads in progress
+// and can be called more than once
 func (d *Dict) Close() {
+	d.fileLock.Lock()
+	defer d.fileLock.Unlock()
 	if d.file == nil {
 		return
 	}
-	slog.Info("Closing dict", "filename", d.filename)
+	d.log().Info("Closing dict", "filename", d.filename)
 	closeCloser(d.file)
 	d.file = nil
 }
@@ -67,3 +97,36 @@ func ReadDict(filename string) (*Dict, error) {
 	}
 	return dict, nil
 }
+
+// articleInBounds returns true if the article at offset with size is
+// inside .dict data, without overflow of offset+size for 64-bit offsets
+func articleInBounds(offset uint64, size uint64, dictSize uint64) bool {
+	return size <= dictSize && offset <= dictSize-size
+}
+
+// checkBounds returns an error if the article at offset with size is
+// outside .dict data, usually because of a corrupt .idx entry. It is
+// checked before allocating the article buffer.
+func (d *Dict) checkBounds(offset uint64, size uint64) error {
+	if articleInBounds(offset, size, d.size) {
+		return nil
+	}
+	return &FormatError{
+		File:   d.filename,
+		Offset: int64(min(offset, math.MaxInt64)),
+		Err: fmt.Errorf(
+			"%w: %d bytes at offset %d, dict size is %d",
+			ErrArticleOutOfRange, size, offset, d.size,
+		),
+	}
+}
+
+// GetSequence returns data at the given offset, or nil on error
+func (d *Dict) GetSequence(offset uint64, size uint64) []byte {
+	p, err := d.ReadSequence(offset, size)
+	if err != nil {
+		d.log().Error("error in GetSequence", "err", err)
+		return nil
+	}
+	return p
+}
@@ -4,31 +4,40 @@
 package stardict
 
 import (
-	"log/slog"
+	"fmt"
 	"syscall"
 )
 
-// GetSequence returns data at the given offset
-func (d *Dict) GetSequence(offset uint64, size uint64) []byte {
+// ReadSequence returns data at the given offset
+func (d *Dict) ReadSequence(offset uint64, size uint64) ([]byte, error) {
+	d.fileLock.RLock()
+	defer d.fileLock.RUnlock()
 	if d.file == nil {
-		slog.Warn("GetSequence: file is closed")
-		return nil
+		return nil, fmt.Errorf("%s: %w", d.filename, ErrDictClosed)
+	}
+	if err := d.checkBounds(offset, size); err != nil {
+		return nil, err
 	}
 	p := make([]byte, size)
 	if d.rawDictFile != nil {
-		_, err := syscall.Pread(int(d.rawDictFile.Fd()), p, int64(offset))
+		n, err := syscall.Pread(int(d.rawDictFile.Fd()), p, int64(offset))
 		if err != nil {
-			slog.Error("error while reading dict file", "err", err, "filename", d.filename)
-			return nil
+			return nil, fmt.Errorf("error while reading %s: %w", d.filename, err)
+		}
+		if n < len(p) {
+			return nil, fmt.Errorf(
+				"%s: unexpected end of file reading %d bytes at %d",
+				d.filename, size, offset,
+			)
 		}
+		return p, nil
 	}
 	// we are using .dict.dz reader which uses Seek() and is not concurrent-safe
 	d.lock.Lock()
 	defer d.lock.Unlock()
 	_, err := d.file.ReadAt(p, int64(offset))
 	if err != nil {
-		slog.Error("error while reading dict file", "err", err, "filename", d.filename)
-		return nil
+		return nil, fmt.Errorf("error while reading %s: %w", d.filename, err)
 	}
-	return p
+	return p, nil
 }
@@ -4,22 +4,25 @@
 package stardict
 
 import (
-	"log/slog"
+	"fmt"
 )
 
-// GetSequence returns data at the given offset
-func (d *Dict) GetSequence(offset uint64, size uint64) []byte {
+// ReadSequence returns data at the given offset
+func (d *Dict) ReadSequence(offset uint64, size uint64) ([]byte, error) {
+	d.fileLock.RLock()
+	defer d.fileLock.RUnlock()
 	if d.file == nil {
-		slog.Warn("GetSequence: file is closed")
-		return nil
+		return nil, fmt.Errorf("%s: %w", d.filename, ErrDictClosed)
+	}
+	if err := d.checkBounds(offset, size); err != nil {
+		return nil, err
 	}
 	d.lock.Lock()
 	defer d.lock.Unlock()
 	p := make([]byte, size)
 	_, err := d.file.ReadAt(p, int64(offset))
 	if err != nil {
-		slog.Error("error while reading dict file", "err", err, "filename", d.filename)
-		return nil
+		return nil, fmt.Errorf("error while reading %s: %w", d.filename, err)
 	}
-	return p
+	return p, nil
 }
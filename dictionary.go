@@ -1,42 +1,129 @@
 package stardict
 
 import (
-	"bytes"
-	"encoding/binary"
-	"io"
+	"fmt"
+	"log/slog"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	common "codeberg.org/ilius/go-dict-commons"
-	"github.com/ilius/go-stardict/v2/murmur3"
 )
 
 // dictionaryImp stardict dictionary
+//
+// All methods are safe for concurrent use. Searches and article reads
+// (Items() of results) hold useMu for reading while they use idx and dict,
+// so Close and Unload wait for them (Unload skips a dictionary in use).
+// After Close, searches fail with ErrClosed and Load returns ErrClosed.
+// Without lazy loading, searches before Load fail with ErrNotLoaded.
 type dictionaryImp struct {
 	*Info
 
-	dict     *Dict
-	idx      *Idx
-	ifoPath  string
+	dict *Dict
+	idx  *Idx
+	// tree is the structure of tree dictionaries, loaded with idx
+	tree    *Tree
+	ifoPath string
+	// idxPath is the path of .idx file, or .tdx file if isTree
 	idxPath  string
 	dictPath string
 	synPath  string
 	resDir   string
 	resURL   string
-
-	decodeData func(data []byte) []*common.SearchResultItem
+	isTree   bool
+
+	normalizer *Normalizer
+	lemmatizer Lemmatizer
+
+	errorHandler func(error)
+	logger       *slog.Logger
+
+	// useMu is held for reading while idx or dict is in use,
+	// and for writing while unloading them
+	useMu sync.RWMutex
+	// loadMu guards loading of idx and dict
+	loadMu sync.Mutex
+	// lazy means idx and dict are loaded on first use instead of Load
+	lazy    bool
+	loadErr error
+	// idxSize is the estimated memory size of idx
+	idxSize int64
+	// lastUse is the time of last search in unix nanoseconds
+	lastUse atomic.Int64
+	pool    *Pool
+	// closed is set by Close, guarded by loadMu
+	closed bool
+
+	disabled atomic.Bool
+
+	suggestOnce  sync.Once
+	suggestIndex *suggestIndex
+
+	ngramOnce  sync.Once
+	ngramIndex *ngramIndex
+
+	headwordOnce sync.Once
+	headwords    []Headword
+
+	completionOnce sync.Once
+	completion     *completionIndex
+
+	// useFST enables the FST index of terms, see SetFSTIndex
+	useFST bool
+	terms  *termIndex
 }
 
 func (d *dictionaryImp) Disabled() bool {
-	return d.disabled
+	return d.disabled.Load()
 }
 
 func (d *dictionaryImp) Loaded() bool {
-	return d.dict != nil
+	d.loadMu.Lock()
+	defer d.loadMu.Unlock()
+	return !d.closed && d.idx != nil && d.dict != nil
 }
 
 func (d *dictionaryImp) SetDisabled(disabled bool) {
-	d.disabled = disabled
+	d.disabled.Store(disabled)
+}
+
+// SetNormalizer sets the Normalizer used for terms and queries,
+// must be called before Load
+func (d *dictionaryImp) SetNormalizer(normalizer *Normalizer) {
+	d.normalizer = normalizer
+}
+
+// SetLazy enables lazy loading: Load does nothing, and the index is
+// loaded on first search instead
+func (d *dictionaryImp) SetLazy(lazy bool) {
+	d.lazy = lazy
+}
+
+// SetLogger sets the logger, nil means slog.Default()
+func (d *dictionaryImp) SetLogger(logger *slog.Logger) {
+	d.logger = logger
+}
+
+func (d *dictionaryImp) log() *slog.Logger {
+	if d.logger != nil {
+		return d.logger
+	}
+	return slog.Default()
+}
+
+// SetErrorHandler sets the handler for errors that can not be returned
+// to the caller, nil means the global ErrorHandler
+func (d *dictionaryImp) SetErrorHandler(handler func(error)) {
+	d.errorHandler = handler
+}
+
+// normalizeQuery returns the form of query that is matched against index
+func (d *dictionaryImp) normalizeQuery(query string) string {
+	return d.normalizer.Normalize(strings.TrimSpace(query))
 }
 
 func (d *dictionaryImp) ResourceDir() string {
@@ -55,21 +142,22 @@ func (d *dictionaryImp) InfoPath() string {
 	return d.ifoPath
 }
 
+// Close closes .dict file and frees the index. It waits for searches and
+// article reads that are in progress, and can be called more than once.
 func (d *dictionaryImp) Close() {
-	d.dict.Close()
+	d.useMu.Lock()
+	defer d.useMu.Unlock()
+	d.loadMu.Lock()
+	defer d.loadMu.Unlock()
+	if d.closed {
+		return
+	}
+	d.closed = true
+	d.unload()
 }
 
 func (d *dictionaryImp) CalcHash() ([]byte, error) {
-	file, err := os.Open(d.idxPath)
-	if err != nil {
-		return nil, err
-	}
-	defer closeCloser(file)
-	hash := murmur3.New128()
-	if _, err := io.Copy(hash, file); err != nil {
-		return nil, err
-	}
-	return hash.Sum(nil), nil
+	return hashFile(d.idxPath)
 }
 
 func (d *dictionaryImp) newResult(entry *IdxEntry, entryIndex int, score uint8) *common.SearchResultLow {
@@ -77,90 +165,66 @@ func (d *dictionaryImp) newResult(entry *IdxEntry, entryIndex int, score uint8)
 		F_Score: score,
 		F_Terms: entry.terms,
 		Items: func() []*common.SearchResultItem {
-			return d.decodeData(d.dict.GetSequence(entry.offset, entry.size))
+			items, err := d.entryItems(entry)
+			if err != nil {
+				d.handleError(fmt.Errorf(
+					"error reading article of %#v from %#v: %w",
+					entry.terms[0], d.DictName(), err,
+				))
+			}
+			return items
 		},
 		F_EntryIndex: uint64(entryIndex),
 	}
 }
 
-func (d *dictionaryImp) EntryByIndex(index int) *common.SearchResultLow {
-	if index >= len(d.idx.entries) {
-		return nil
+// entryItems reads and decodes the article of entry,
+// loading the dictionary again if it was unloaded
+func (d *dictionaryImp) entryItems(entry *IdxEntry) ([]*common.SearchResultItem, error) {
+	_, release, err := d.acquire()
+	if err != nil {
+		return nil, err
 	}
-	entry := d.idx.entries[index]
-	return d.newResult(entry, index, 0)
+	defer release()
+	data, err := d.dict.ReadSequence(entry.offset, entry.size)
+	if err != nil {
+		return nil, err
+	}
+	return decodeItems(data, d.SameTypeSequence)
 }
 
-func (d *dictionaryImp) decodeWithSametypesequence(data []byte) (items []*common.SearchResultItem) {
-	seq := d.Options[I_sametypesequence]
-
-	seqLen := len(seq)
-
-	var dataPos int
-	dataSize := len(data)
-
-	for i, t := range seq {
-		switch t {
-		case 'm', 'l', 'g', 't', 'x', 'y', 'k', 'w', 'h', 'r':
-			// if last seq item
-			if i == seqLen-1 {
-				items = append(items, &common.SearchResultItem{Type: t, Data: data[dataPos:dataSize]})
-			} else {
-				end := bytes.IndexRune(data[dataPos:], '\000')
-				items = append(items, &common.SearchResultItem{Type: t, Data: data[dataPos : dataPos+end+1]})
-				dataPos += end + 1
-			}
-		case 'W', 'P':
-			if i == seqLen-1 {
-				items = append(items, &common.SearchResultItem{Type: t, Data: data[dataPos:dataSize]})
-			} else {
-				size := binary.BigEndian.Uint32(data[dataPos : dataPos+4])
-				items = append(items, &common.SearchResultItem{Type: t, Data: data[dataPos+4 : dataPos+int(size)+5]})
-				dataPos += int(size) + 5
-			}
-		}
+// ItemsErr is like Items() of the result with the given entry index,
+// but returns the error (usually *DecodeError) instead of passing it to
+// the error handler. Items decoded before the error are still returned.
+func (d *dictionaryImp) ItemsErr(entryIndex int) ([]*common.SearchResultItem, error) {
+	idx, release, err := d.acquire()
+	if err != nil {
+		return nil, err
 	}
-
-	return
+	release()
+	if entryIndex < 0 || entryIndex >= len(idx.entries) {
+		return nil, fmt.Errorf("entry index %d out of range", entryIndex)
+	}
+	return d.entryItems(idx.entries[entryIndex])
 }
 
-func (d *dictionaryImp) decodeWithoutSametypesequence(data []byte) (items []*common.SearchResultItem) {
-	var dataPos int
-	dataSize := len(data)
-
-	for {
-		t := data[dataPos]
-
-		dataPos++
-
-		switch t {
-		case 'm', 'l', 'g', 't', 'x', 'y', 'k', 'w', 'h', 'r':
-			end := bytes.IndexRune(data[dataPos:], '\000')
-
-			if end < 0 { // last item
-				items = append(items, &common.SearchResultItem{Type: rune(t), Data: data[dataPos:dataSize]})
-				dataPos = dataSize
-			} else {
-				items = append(items, &common.SearchResultItem{Type: rune(t), Data: data[dataPos : dataPos+end+1]})
-				dataPos += end + 1
-			}
-		case 'W', 'P':
-			size := binary.BigEndian.Uint32(data[dataPos : dataPos+4])
-			items = append(items, &common.SearchResultItem{Type: rune(t), Data: data[dataPos+4 : dataPos+int(size)+5]})
-			dataPos += int(size) + 5
-		}
-
-		if dataPos >= dataSize {
-			break
-		}
+func (d *dictionaryImp) EntryByIndex(index int) *common.SearchResultLow {
+	idx, release, err := d.acquire()
+	if err != nil {
+		d.handleError(err)
+		return nil
 	}
-
-	return
+	defer release()
+	if index < 0 || index >= len(idx.entries) {
+		return nil
+	}
+	entry := idx.entries[index]
+	return d.newResult(entry, index, 0)
 }
 
 // DictName returns book name
 func (d *dictionaryImp) DictName() string {
-	return d.Options[I_bookname]
+	return d.BookName
 }
 
 // NewDictionary returns a new Dictionary
@@ -181,8 +245,14 @@ func NewDictionary(path string, name string) (*dictionaryImp, error) {
 	if _, err := os.Stat(ifoPath); err != nil {
 		return nil, err
 	}
+	// tree dictionaries have .tdx (or .tdx.gz) instead of .idx
 	if _, err := os.Stat(idxPath); err != nil {
-		return nil, err
+		tdxPath, ok := findTreeIndex(path, name)
+		if !ok {
+			return nil, err
+		}
+		idxPath = tdxPath
+		d.isTree = true
 	}
 	if _, err := os.Stat(synPath); err != nil {
 		synPath = ""
@@ -209,30 +279,187 @@ func NewDictionary(path string, name string) (*dictionaryImp, error) {
 	d.idxPath = idxPath
 	d.synPath = synPath
 	d.dictPath = dictPath
-
-	if _, ok := info.Options[I_sametypesequence]; ok {
-		d.decodeData = d.decodeWithSametypesequence
-	} else {
-		d.decodeData = d.decodeWithoutSametypesequence
-	}
+	d.normalizer = DefaultNormalizer
+	d.lemmatizer = DefaultLemmatizer
 
 	return d, nil
 }
 
+// findTreeIndex returns the path of .tdx or .tdx.gz file
+func findTreeIndex(path string, name string) (string, bool) {
+	for _, ext := range []string{".tdx", ".tdx.gz"} {
+		tdxPath := filepath.Join(path, name+ext)
+		if _, err := os.Stat(tdxPath); err == nil {
+			return tdxPath, true
+		}
+	}
+	return "", false
+}
+
+// Load reads the index into memory and opens .dict file,
+// it does nothing if lazy loading is enabled
 func (d *dictionaryImp) Load() error {
+	d.loadMu.Lock()
+	defer d.loadMu.Unlock()
+	if d.closed {
+		return fmt.Errorf("%#v: %w", d.DictName(), ErrClosed)
+	}
+	if d.lazy || d.idx != nil {
+		return nil
+	}
+	return d.load()
+}
+
+// acquire returns the loaded index, loading it first if lazy loading
+// is enabled. idx and dict are not unloaded until release is called.
+func (d *dictionaryImp) acquire() (idx *Idx, release func(), err error) {
+	d.useMu.RLock()
+	idx, pool, err := d.loadIndex()
+	if err != nil {
+		d.useMu.RUnlock()
+		return nil, nil, err
+	}
+	d.lastUse.Store(time.Now().UnixNano())
+	return idx, func() {
+		d.useMu.RUnlock()
+		if pool != nil {
+			pool.evict(d)
+		}
+	}, nil
+}
+
+// loadIndex returns the loaded index, and if it was just loaded,
+// the pool (read under loadMu) that may need to evict dictionaries
+func (d *dictionaryImp) loadIndex() (*Idx, *Pool, error) {
+	d.loadMu.Lock()
+	defer d.loadMu.Unlock()
+	if d.closed {
+		return nil, nil, fmt.Errorf("%#v: %w", d.DictName(), ErrClosed)
+	}
+	if d.idx != nil {
+		return d.idx, nil, nil
+	}
+	if !d.lazy {
+		return nil, nil, fmt.Errorf("%#v: %w", d.DictName(), ErrNotLoaded)
+	}
+	// do not retry a failed lazy load on every search
+	if d.loadErr != nil {
+		return nil, nil, d.loadErr
+	}
+	t0 := time.Now()
+	err := d.load()
+	if err != nil {
+		d.loadErr = fmt.Errorf("error loading %#v: %w", d.DictName(), err)
+		return nil, nil, d.loadErr
+	}
+	d.log().Info("Loaded index", "path", d.idxPath, "dt", time.Since(t0))
+	return d.idx, d.pool, nil
+}
+
+func (d *dictionaryImp) load() error {
 	{
-		idx, err := ReadIndex(d.idxPath, d.synPath, d.Info)
+		opts := indexOptions{
+			normalizer:   d.normalizer,
+			errorHandler: d.errorHandler,
+			logger:       d.logger,
+		}
+		var idx *Idx
+		var err error
+		if d.isTree {
+			d.tree, err = ReadTree(d.idxPath)
+			if err == nil {
+				idx = d.tree.index(opts)
+			}
+		} else {
+			idx, err = readIndexCached(d.idxPath, d.synPath, d.Info, opts)
+		}
 		if err != nil {
 			return err
 		}
+		var terms *termIndex
+		if d.useFST {
+			terms, err = d.loadTermIndex(idx)
+			if err != nil {
+				return err
+			}
+		}
 		d.idx = idx
+		d.terms = terms
+		d.idxSize = idx.memorySize()
+		if terms != nil {
+			d.idxSize += terms.memorySize()
+		}
+		if d.tree != nil {
+			d.idxSize += d.tree.memorySize()
+		}
 	}
 	{
 		dict, err := ReadDict(d.dictPath)
 		if err != nil {
+			d.idx = nil
+			d.tree = nil
+			d.terms = nil
+			d.idxSize = 0
 			return err
 		}
+		dict.logger = d.logger
 		d.dict = dict
 	}
 	return nil
 }
+
+// MemorySize returns the estimated memory size of the loaded index
+// in bytes, or 0 if not loaded
+func (d *dictionaryImp) MemorySize() int64 {
+	d.loadMu.Lock()
+	defer d.loadMu.Unlock()
+	return d.idxSize
+}
+
+// LastUsed returns the time of last search, or zero time if not used
+func (d *dictionaryImp) LastUsed() time.Time {
+	nano := d.lastUse.Load()
+	if nano == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, nano)
+}
+
+// Unload frees the index and closes .dict file, both are loaded again on
+// next use. It returns false if the dictionary is not loaded or is in use.
+func (d *dictionaryImp) Unload() bool {
+	if !d.useMu.TryLock() {
+		return false
+	}
+	defer d.useMu.Unlock()
+	d.loadMu.Lock()
+	defer d.loadMu.Unlock()
+	if d.idx == nil {
+		return false
+	}
+	d.unload()
+	d.lazy = true
+	d.log().Info("Unloaded index", "path", d.idxPath)
+	return true
+}
+
+// unload closes dict and frees idx and search indexes built from it,
+// both useMu and loadMu must be locked
+func (d *dictionaryImp) unload() {
+	if d.dict != nil {
+		d.dict.Close()
+	}
+	d.dict = nil
+	d.idx = nil
+	d.tree = nil
+	d.idxSize = 0
+	d.suggestOnce = sync.Once{}
+	d.suggestIndex = nil
+	d.ngramOnce = sync.Once{}
+	d.ngramIndex = nil
+	d.headwordOnce = sync.Once{}
+	d.headwords = nil
+	d.completionOnce = sync.Once{}
+	d.completion = nil
+	d.terms = nil
+}
@@ -1,7 +1,65 @@
 package stardict
 
-import common "codeberg.org/ilius/go-dict-commons"
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	common "codeberg.org/ilius/go-dict-commons"
+)
 
 func init() {
 	var _ common.Dictionary = &dictionaryImp{}
+	var _ common.Dictionary = &ReverseDictionary{}
+}
+
+func TestDictionaryLifecycle(t *testing.T) {
+	d := openTestDict(t, testEntries)
+	d.SetErrorHandler(func(error) {})
+	if _, err := d.SearchRegex("app.*", 0, 0); !errors.Is(err, ErrNotLoaded) {
+		t.Fatalf("expected ErrNotLoaded before Load, got %v", err)
+	}
+	if err := d.Load(); err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			d.SetDisabled(i%2 == 0)
+			_ = d.Disabled()
+			for _, res := range d.SearchStartWith("ba", 0, 0) {
+				// article reads after Close fail instead of crashing
+				_ = res.Items()
+			}
+		}(i)
+	}
+	d.Close()
+	wg.Wait()
+	d.Close()
+
+	if d.Loaded() {
+		t.Fatal("closed dictionary is loaded")
+	}
+	if _, err := d.SearchRegex("app.*", 0, 0); !errors.Is(err, ErrClosed) {
+		t.Fatalf("expected ErrClosed after Close, got %v", err)
+	}
+	if err := d.Load(); !errors.Is(err, ErrClosed) {
+		t.Fatalf("expected ErrClosed from Load, got %v", err)
+	}
+}
+
+func TestSearchWordMatchEmptyQuery(t *testing.T) {
+	d := openTestDict(t, testEntries)
+	if err := d.Load(); err != nil {
+		t.Fatal(err)
+	}
+	for _, query := range []string{"", "   "} {
+		if results := d.SearchWordMatch(query, 1, time.Second); len(results) != 0 {
+			t.Errorf("unexpected results for %#v: %v", query, results)
+		}
+	}
 }
@@ -1,23 +1,39 @@
 /*
-Package dictzip provides a reader for files in the random access `dictzip` format.
+Package dictzip provides a reader and a writer for files in the random access `dictzip` format.
 
-Note: this is not concurrent-safe, since it calls fp.Seek()
+Note: Reader is not concurrent-safe, since it calls fp.Seek()
 */
 package dictzip
 
 import (
 	"compress/flate"
+	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
 	"sync"
 )
 
+var (
+	// ErrInvalidHeader is returned if the file is not in gzip format
+	ErrInvalidHeader = errors.New("invalid header")
+	// ErrMissingMetadata is returned for gzip files without dictzip
+	// random access metadata
+	ErrMissingMetadata = errors.New("missing dictzip metadata")
+	// ErrUnsupportedVersion is returned for unknown dictzip versions
+	ErrUnsupportedVersion = errors.New("unknown dictzip version")
+	// ErrOutOfRange is returned for reads past the end of uncompressed data
+	ErrOutOfRange = errors.New("read past end of dictzip data")
+)
+
 // Reader, This implements the io.ReadSeekCloser interface.
 type Reader struct {
 	fp        io.ReadSeekCloser
 	offsets   []int64
 	blockSize int64
-	lock      sync.Mutex
+	// size is the size of uncompressed data
+	size int64
+	lock sync.Mutex
 }
 
 func NewReader(rs io.ReadSeekCloser) (*Reader, error) {
@@ -40,11 +56,11 @@ func NewReader(rs io.ReadSeekCloser) (*Reader, error) {
 	p += n
 
 	if h[0] != 31 || h[1] != 139 {
-		return nil, fmt.Errorf("invalid header: %02X %02X", h[0], h[1])
+		return nil, fmt.Errorf("%w: %02X %02X", ErrInvalidHeader, h[0], h[1])
 	}
 
 	if h[2] != 8 {
-		return nil, fmt.Errorf("unknown compression method: %v", h[2])
+		return nil, fmt.Errorf("%w: unknown compression method: %v", ErrInvalidHeader, h[2])
 	}
 
 	flg := h[3]
@@ -106,13 +122,13 @@ func NewReader(rs io.ReadSeekCloser) (*Reader, error) {
 	}
 
 	if len(metadata) < 6 {
-		return nil, fmt.Errorf("missing dictzip metadata")
+		return nil, ErrMissingMetadata
 	}
 
 	version := int(metadata[0]) + 256*int(metadata[1])
 
 	if version != 1 {
-		return nil, fmt.Errorf("unknown dictzip version: %v", version)
+		return nil, fmt.Errorf("%w: %v", ErrUnsupportedVersion, version)
 	}
 
 	dz.blockSize = int64(metadata[2]) + 256*int64(metadata[3])
@@ -124,9 +140,44 @@ func NewReader(rs io.ReadSeekCloser) (*Reader, error) {
 		dz.offsets[i+1] = dz.offsets[i] + int64(metadata[6+2*i]) + 256*int64(metadata[7+2*i])
 	}
 
+	err = dz.readSize(blockCount)
+	if err != nil {
+		return nil, err
+	}
 	return dz, nil
 }
 
+// readSize sets the uncompressed size from the gzip trailer, which has
+// the size modulo 2^32, and is in the last chunk
+func (dz *Reader) readSize(blockCount int) error {
+	if blockCount == 0 || dz.blockSize == 0 {
+		return nil
+	}
+	_, err := dz.fp.Seek(-4, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+	var buf [4]byte
+	_, err = io.ReadFull(dz.fp, buf[:])
+	if err != nil {
+		return err
+	}
+	isize := int64(binary.LittleEndian.Uint32(buf[:]))
+	// the size is in (low, high], a range smaller than 2^32
+	low := int64(blockCount-1) * dz.blockSize
+	high := int64(blockCount) * dz.blockSize
+	dz.size = low + 1 + (isize-low-1)&0xffffffff
+	if dz.size > high {
+		dz.size = high
+	}
+	return nil
+}
+
+// Size returns the size of uncompressed data
+func (dz *Reader) Size() int64 {
+	return dz.size
+}
+
 func (dz *Reader) Close() error {
 	return dz.fp.Close()
 }
@@ -156,6 +207,10 @@ func (dz *Reader) Get(start, size int64) ([]byte, error) {
 		return nil, fmt.Errorf("negative start or size")
 	}
 
+	if size > dz.size || start > dz.size-size {
+		return nil, fmt.Errorf("%w: %d bytes at %d, size is %d", ErrOutOfRange, size, start, dz.size)
+	}
+
 	if int(start/dz.blockSize) >= len(dz.offsets) {
 		return nil, fmt.Errorf("start passed end of archive")
 	}
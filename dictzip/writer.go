@@ -0,0 +1,151 @@
+package dictzip
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+)
+
+// DefaultChunkSize is the size of uncompressed chunks used by dictzip
+const DefaultChunkSize = 58315
+
+// maxChunkCount is limited by the size of gzip extra field
+const maxChunkCount = (0xffff - 10) / 2
+
+// ErrTooLarge is returned by Writer if data does not fit in the
+// random access metadata
+var ErrTooLarge = errors.New("data too large for dictzip")
+
+// Writer compresses data in dictzip format. Compressed chunks are kept
+// in memory, since their sizes must be written in the header before them,
+// so the output is only written by Close.
+type Writer struct {
+	w         io.Writer
+	chunkSize int
+
+	chunk      []byte
+	chunkSizes []int
+	compressed bytes.Buffer
+	flater     *flate.Writer
+	crc        uint32
+	size       uint32
+	closed     bool
+}
+
+// NewWriter returns a Writer that writes into w on Close
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{
+		w:         w,
+		chunkSize: DefaultChunkSize,
+	}
+}
+
+func (dz *Writer) Write(p []byte) (int, error) {
+	if dz.closed {
+		return 0, errors.New("dictzip: write after close")
+	}
+	dz.crc = crc32.Update(dz.crc, crc32.IEEETable, p)
+	dz.size += uint32(len(p))
+	n := len(p)
+	for len(p) > 0 {
+		free := dz.chunkSize - len(dz.chunk)
+		if free > len(p) {
+			free = len(p)
+		}
+		dz.chunk = append(dz.chunk, p[:free]...)
+		p = p[free:]
+		if len(dz.chunk) == dz.chunkSize {
+			err := dz.flushChunk(false)
+			if err != nil {
+				return 0, err
+			}
+		}
+	}
+	return n, nil
+}
+
+// flushChunk compresses the current chunk, so it can be decompressed
+// without previous chunks. The last chunk ends the deflate stream.
+func (dz *Writer) flushChunk(last bool) error {
+	if len(dz.chunkSizes) == maxChunkCount {
+		return ErrTooLarge
+	}
+	start := dz.compressed.Len()
+	if dz.flater == nil {
+		flater, err := flate.NewWriter(&dz.compressed, flate.BestCompression)
+		if err != nil {
+			return err
+		}
+		dz.flater = flater
+	} else {
+		dz.flater.Reset(&dz.compressed)
+	}
+	_, err := dz.flater.Write(dz.chunk)
+	if err != nil {
+		return err
+	}
+	if last {
+		err = dz.flater.Close()
+	} else {
+		err = dz.flater.Flush()
+	}
+	if err != nil {
+		return err
+	}
+	size := dz.compressed.Len() - start
+	if size > 0xffff {
+		return ErrTooLarge
+	}
+	dz.chunkSizes = append(dz.chunkSizes, size)
+	dz.chunk = dz.chunk[:0]
+	return nil
+}
+
+// Close compresses the remaining data and writes the whole file,
+// it does not close the underlying writer
+func (dz *Writer) Close() error {
+	if dz.closed {
+		return nil
+	}
+	dz.closed = true
+	err := dz.flushChunk(true)
+	if err != nil {
+		return err
+	}
+
+	// RA subfield: version, chunk size, chunk count, compressed sizes
+	ra := make([]byte, 0, 6+2*len(dz.chunkSizes))
+	ra = binary.LittleEndian.AppendUint16(ra, 1)
+	ra = binary.LittleEndian.AppendUint16(ra, uint16(dz.chunkSize))
+	ra = binary.LittleEndian.AppendUint16(ra, uint16(len(dz.chunkSizes)))
+	for _, size := range dz.chunkSizes {
+		ra = binary.LittleEndian.AppendUint16(ra, uint16(size))
+	}
+
+	header := []byte{
+		31, 139, // magic
+		8,          // deflate
+		4,          // FEXTRA
+		0, 0, 0, 0, // mtime
+		2, // best compression
+		3, // unix
+	}
+	header = binary.LittleEndian.AppendUint16(header, uint16(4+len(ra)))
+	header = append(header, 'R', 'A')
+	header = binary.LittleEndian.AppendUint16(header, uint16(len(ra)))
+	header = append(header, ra...)
+
+	trailer := binary.LittleEndian.AppendUint32(nil, dz.crc)
+	trailer = binary.LittleEndian.AppendUint32(trailer, dz.size)
+
+	for _, part := range [][]byte{header, dz.compressed.Bytes(), trailer} {
+		_, err := dz.w.Write(part)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
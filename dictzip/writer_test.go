@@ -0,0 +1,82 @@
+package dictzip
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriter(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	data := make([]byte, 3*DefaultChunkSize+1234)
+	for i := range data {
+		data[i] = "abcdefgh \n"[rnd.Intn(10)]
+	}
+	fpath := filepath.Join(t.TempDir(), "test.dict.dz")
+	file, err := os.Create(fpath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := NewWriter(file)
+	if _, err := w.Write(data[:100]); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write(data[100:]); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	_ = file.Close()
+
+	// must be a valid gzip file
+	file, _ = os.Open(fpath)
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain, err := io.ReadAll(gz)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(plain, data) {
+		t.Fatal("gzip content mismatch")
+	}
+	_ = file.Close()
+
+	file, _ = os.Open(fpath)
+	dz, err := NewReader(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dz.Close()
+	for _, r := range [][2]int64{
+		{0, 10},
+		{DefaultChunkSize - 5, 10},
+		{DefaultChunkSize * 2, DefaultChunkSize + 100},
+		{int64(len(data)) - 7, 7},
+	} {
+		p, err := dz.Get(r[0], r[1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(p, data[r[0]:r[0]+r[1]]) {
+			t.Fatalf("mismatch at %d", r[0])
+		}
+	}
+	if dz.Size() != int64(len(data)) {
+		t.Fatalf("expected size %d, got %d", len(data), dz.Size())
+	}
+	if _, err := dz.Get(int64(len(data))-7, 8); !errors.Is(err, ErrOutOfRange) {
+		t.Fatalf("expected ErrOutOfRange, got %v", err)
+	}
+	if _, err := dz.Get(1, math.MaxInt64); !errors.Is(err, ErrOutOfRange) {
+		t.Fatalf("expected ErrOutOfRange, got %v", err)
+	}
+}
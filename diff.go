@@ -0,0 +1,641 @@
+package stardict
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	common "codeberg.org/ilius/go-dict-commons"
+)
+
+// DiffKind is the kind of a DiffEntry
+type DiffKind uint8
+
+const (
+	// DiffInfoChanged is a changed, added or removed .ifo option
+	DiffInfoChanged DiffKind = iota
+	// DiffAdded is a headword only in the new dictionary
+	DiffAdded
+	// DiffRemoved is a headword only in the old dictionary
+	DiffRemoved
+	// DiffArticleChanged is a headword with a changed article
+	DiffArticleChanged
+	// DiffSynonymAdded is a synonym only in the new dictionary,
+	// or pointing to a different headword
+	DiffSynonymAdded
+	// DiffSynonymRemoved is a synonym only in the old dictionary,
+	// or pointing to a different headword
+	DiffSynonymRemoved
+)
+
+func (k DiffKind) String() string {
+	switch k {
+	case DiffInfoChanged:
+		return "info"
+	case DiffAdded:
+		return "added"
+	case DiffRemoved:
+		return "removed"
+	case DiffArticleChanged:
+		return "changed"
+	case DiffSynonymAdded:
+		return "synonym-added"
+	case DiffSynonymRemoved:
+		return "synonym-removed"
+	}
+	return fmt.Sprintf("DiffKind(%d)", k)
+}
+
+func (k DiffKind) MarshalText() ([]byte, error) {
+	return []byte(k.String()), nil
+}
+
+// DiffEntry is a difference between two dictionaries
+type DiffEntry struct {
+	Kind DiffKind `json:"kind"`
+	// Headword is the changed headword, or the headword that Synonym
+	// points to
+	Headword string `json:"headword,omitempty"`
+	Synonym  string `json:"synonym,omitempty"`
+	// Key, Old and New are the .ifo option and its values
+	Key string `json:"key,omitempty"`
+	Old string `json:"old,omitempty"`
+	New string `json:"new,omitempty"`
+	// Lines is the line diff of decoded article items, each line starts
+	// with "-", "+" or " " (for context), and "..." separates hunks
+	Lines []string `json:"lines,omitempty"`
+}
+
+func (e *DiffEntry) String() string {
+	switch e.Kind {
+	case DiffInfoChanged:
+		return fmt.Sprintf("info %s: %#v -> %#v", e.Key, e.Old, e.New)
+	case DiffSynonymAdded, DiffSynonymRemoved:
+		return fmt.Sprintf("%s %s -> %s", e.Kind, e.Synonym, e.Headword)
+	}
+	return e.Kind.String() + " " + e.Headword
+}
+
+// idxStream reads records of a sorted .idx file one at a time
+type idxStream struct {
+	path string
+	r    *bufio.Reader
+	is64 bool
+
+	// index is the entry index of current record
+	index  int
+	term   string
+	offset uint64
+	size   uint64
+	done   bool
+}
+
+func newIdxStream(path string, is64 bool) (*idxStream, io.Closer, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &idxStream{
+		path:  path,
+		r:     bufio.NewReaderSize(file, 1<<16),
+		is64:  is64,
+		index: -1,
+	}, file, nil
+}
+
+// next reads the next record, and sets done at end of file
+func (s *idxStream) next() error {
+	term, err := s.r.ReadString(0)
+	if err == io.EOF && term == "" {
+		s.done = true
+		return nil
+	}
+	if err != nil {
+		return s.corrupt("truncated record")
+	}
+	term = term[:len(term)-1]
+	intSize := 4
+	if s.is64 {
+		intSize = 8
+	}
+	var buf [16]byte
+	if _, err := io.ReadFull(s.r, buf[:2*intSize]); err != nil {
+		return s.corrupt("truncated record")
+	}
+	if s.is64 {
+		s.offset = binary.BigEndian.Uint64(buf[:8])
+		s.size = binary.BigEndian.Uint64(buf[8:16])
+	} else {
+		s.offset = uint64(binary.BigEndian.Uint32(buf[:4]))
+		s.size = uint64(binary.BigEndian.Uint32(buf[4:8]))
+	}
+	if s.index >= 0 && CompareTerms(s.term, term) > 0 {
+		return s.corrupt(fmt.Sprintf("%#v is not sorted, fix it with stardict-fsck -fix", term))
+	}
+	s.term = term
+	s.index++
+	return nil
+}
+
+func (s *idxStream) corrupt(reason string) error {
+	return &FormatError{File: s.path, Err: fmt.Errorf("%w: %s", ErrCorruptIdx, reason)}
+}
+
+// diffSide is one of the compared dictionaries
+type diffSide struct {
+	d    *dictionaryImp
+	dict *Dict
+	// headwords are collected while reading .idx, if there is .syn file
+	headwords []string
+}
+
+func openDiffSide(ifoPath string) (*diffSide, error) {
+	d, err := NewDictionary(filepath.Dir(ifoPath), strings.TrimSuffix(filepath.Base(ifoPath), ".ifo"))
+	if err != nil {
+		return nil, err
+	}
+	if d.isTree {
+		return nil, fmt.Errorf("%s: tree dictionaries are not supported", ifoPath)
+	}
+	dict, err := ReadDict(d.dictPath)
+	if err != nil {
+		return nil, err
+	}
+	return &diffSide{d: d, dict: dict}, nil
+}
+
+// articleLines reads the article of the current record of s, and
+// returns its items as lines of text
+func (side *diffSide) articleLines(s *idxStream) ([]string, []byte, error) {
+	data, err := side.dict.ReadSequence(s.offset, s.size)
+	if err != nil {
+		return nil, nil, err
+	}
+	items, err := decodeItems(data, side.d.SameTypeSequence)
+	lines := itemLines(items)
+	if err != nil {
+		lines = append(lines, "[error: "+err.Error()+"]")
+	}
+	return lines, data, nil
+}
+
+// itemLines returns lines of items, each item is preceded by a line
+// of its type. Binary items are summarized by size and checksum.
+func itemLines(items []*common.SearchResultItem) []string {
+	var lines []string
+	for _, item := range items {
+		if item.Type >= 'A' && item.Type <= 'Z' {
+			lines = append(lines, fmt.Sprintf(
+				"[%c] %d bytes, crc32 %08x",
+				item.Type, len(item.Data), crc32.ChecksumIEEE(item.Data),
+			))
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("[%c]", item.Type))
+		lines = append(lines, strings.Split(string(item.Data), "\n")...)
+	}
+	return lines
+}
+
+// Diff compares two dictionaries by headword and synonyms, and calls fn
+// for each difference: .ifo options first, then headwords and then
+// synonyms, both in the order of CompareTerms. Indexes are read as
+// streams and must be sorted. Only the headwords of dictionaries with
+// synonyms are kept in memory.
+func Diff(oldIfoPath string, newIfoPath string, fn func(entry *DiffEntry) error) error {
+	oldSide, err := openDiffSide(oldIfoPath)
+	if err != nil {
+		return err
+	}
+	defer oldSide.dict.Close()
+	newSide, err := openDiffSide(newIfoPath)
+	if err != nil {
+		return err
+	}
+	defer newSide.dict.Close()
+
+	for _, entry := range diffInfo(oldSide.d.Info, newSide.d.Info) {
+		err := fn(entry)
+		if err != nil {
+			return err
+		}
+	}
+	err = diffHeadwords(oldSide, newSide, fn)
+	if err != nil {
+		return err
+	}
+	return diffSynonyms(oldSide, newSide, fn)
+}
+
+// diffInfo compares version and options of .ifo files
+func diffInfo(oldInfo *Info, newInfo *Info) []*DiffEntry {
+	var entries []*DiffEntry
+	if oldInfo.Version != newInfo.Version {
+		entries = append(entries, &DiffEntry{
+			Kind: DiffInfoChanged,
+			Key:  "version",
+			Old:  oldInfo.Version,
+			New:  newInfo.Version,
+		})
+	}
+	keys := make([]string, 0, len(oldInfo.Options)+len(newInfo.Options))
+	for key := range oldInfo.Options {
+		keys = append(keys, key)
+	}
+	for key := range newInfo.Options {
+		if _, ok := oldInfo.Options[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		oldValue, newValue := oldInfo.Options[key], newInfo.Options[key]
+		if oldValue == newValue {
+			continue
+		}
+		entries = append(entries, &DiffEntry{
+			Kind: DiffInfoChanged,
+			Key:  key,
+			Old:  oldValue,
+			New:  newValue,
+		})
+	}
+	return entries
+}
+
+// diffHeadwords merge-joins .idx files, duplicate headwords are
+// compared in the order they appear
+func diffHeadwords(oldSide *diffSide, newSide *diffSide, fn func(*DiffEntry) error) error {
+	oldStream, oldFile, err := newIdxStream(oldSide.d.idxPath, oldSide.d.Is64)
+	if err != nil {
+		return err
+	}
+	defer oldFile.Close()
+	newStream, newFile, err := newIdxStream(newSide.d.idxPath, newSide.d.Is64)
+	if err != nil {
+		return err
+	}
+	defer newFile.Close()
+
+	next := func(side *diffSide, s *idxStream) error {
+		err := s.next()
+		if err == nil && !s.done && side.d.synPath != "" {
+			side.headwords = append(side.headwords, s.term)
+		}
+		return err
+	}
+	if err := next(oldSide, oldStream); err != nil {
+		return err
+	}
+	if err := next(newSide, newStream); err != nil {
+		return err
+	}
+	for !oldStream.done || !newStream.done {
+		cmp := 0
+		switch {
+		case oldStream.done:
+			cmp = 1
+		case newStream.done:
+			cmp = -1
+		default:
+			cmp = CompareTerms(oldStream.term, newStream.term)
+		}
+		var entry *DiffEntry
+		switch {
+		case cmp < 0:
+			entry = &DiffEntry{Kind: DiffRemoved, Headword: oldStream.term}
+		case cmp > 0:
+			entry = &DiffEntry{Kind: DiffAdded, Headword: newStream.term}
+		default:
+			entry, err = diffArticles(oldSide, oldStream, newSide, newStream)
+			if err != nil {
+				return err
+			}
+		}
+		if entry != nil {
+			err := fn(entry)
+			if err != nil {
+				return err
+			}
+		}
+		if cmp <= 0 {
+			if err := next(oldSide, oldStream); err != nil {
+				return err
+			}
+		}
+		if cmp >= 0 {
+			if err := next(newSide, newStream); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// diffArticles compares articles of current records, and returns
+// nil if they are identical
+func diffArticles(oldSide *diffSide, oldStream *idxStream, newSide *diffSide, newStream *idxStream) (*DiffEntry, error) {
+	oldLines, oldData, err := oldSide.articleLines(oldStream)
+	if err != nil {
+		return nil, err
+	}
+	newLines, newData, err := newSide.articleLines(newStream)
+	if err != nil {
+		return nil, err
+	}
+	sameSeq := oldSide.d.SameTypeSequence == newSide.d.SameTypeSequence
+	if sameSeq && string(oldData) == string(newData) || slices.Equal(oldLines, newLines) {
+		return nil, nil
+	}
+	return &DiffEntry{
+		Kind:     DiffArticleChanged,
+		Headword: newStream.term,
+		Lines:    diffLines(oldLines, newLines, 2),
+	}, nil
+}
+
+// readSynGroups reads .syn file and calls fn with each synonym and
+// the sorted headwords it points to
+func readSynGroups(side *diffSide, fn func(term string, headwords []string) error) error {
+	if side.d.synPath == "" {
+		return nil
+	}
+	file, err := os.Open(side.d.synPath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	r := bufio.NewReaderSize(file, 1<<16)
+	var term string
+	var headwords []string
+	flush := func() error {
+		if len(headwords) == 0 {
+			return nil
+		}
+		sort.Strings(headwords)
+		err := fn(term, headwords)
+		headwords = nil
+		return err
+	}
+	for {
+		synTerm, err := r.ReadString(0)
+		if err == io.EOF && synTerm == "" {
+			return flush()
+		}
+		var buf [4]byte
+		if err == nil {
+			_, err = io.ReadFull(r, buf[:])
+		}
+		if err != nil {
+			return &FormatError{File: side.d.synPath, Err: fmt.Errorf("%w: truncated record", ErrCorruptSyn)}
+		}
+		synTerm = synTerm[:len(synTerm)-1]
+		entryIndex := int(binary.BigEndian.Uint32(buf[:]))
+		if entryIndex >= len(side.headwords) {
+			return &FormatError{File: side.d.synPath, Err: fmt.Errorf(
+				"%w: entry index %d of %#v out of range", ErrCorruptSyn, entryIndex, synTerm,
+			)}
+		}
+		if synTerm != term {
+			if len(headwords) > 0 && CompareTerms(term, synTerm) > 0 {
+				return &FormatError{File: side.d.synPath, Err: fmt.Errorf(
+					"%w: %#v is not sorted, fix it with stardict-fsck -fix", ErrCorruptSyn, synTerm,
+				)}
+			}
+			if err := flush(); err != nil {
+				return err
+			}
+			term = synTerm
+		}
+		headwords = append(headwords, side.headwords[entryIndex])
+	}
+}
+
+type synGroup struct {
+	term      string
+	headwords []string
+}
+
+// diffSynonyms merge-joins .syn files, reading each in a goroutine
+func diffSynonyms(oldSide *diffSide, newSide *diffSide, fn func(*DiffEntry) error) error {
+	stop := make(chan struct{})
+	defer close(stop)
+	stream := func(side *diffSide) *synStream {
+		groups := make(chan synGroup, 256)
+		errc := make(chan error, 1)
+		go func() {
+			defer close(groups)
+			errc <- readSynGroups(side, func(term string, headwords []string) error {
+				select {
+				case groups <- synGroup{term: term, headwords: headwords}:
+					return nil
+				case <-stop:
+					return errDiffStopped
+				}
+			})
+		}()
+		return &synStream{groups: groups, errc: errc}
+	}
+	oldStream := stream(oldSide)
+	newStream := stream(newSide)
+
+	emit := func(kind DiffKind, term string, headwords []string) error {
+		for _, headword := range headwords {
+			err := fn(&DiffEntry{Kind: kind, Headword: headword, Synonym: term})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	oldGroup, oldOK, err := oldStream.next()
+	if err != nil {
+		return err
+	}
+	newGroup, newOK, err := newStream.next()
+	if err != nil {
+		return err
+	}
+	for oldOK || newOK {
+		cmp := 0
+		switch {
+		case !oldOK:
+			cmp = 1
+		case !newOK:
+			cmp = -1
+		default:
+			cmp = CompareTerms(oldGroup.term, newGroup.term)
+		}
+		var err error
+		switch {
+		case cmp < 0:
+			err = emit(DiffSynonymRemoved, oldGroup.term, oldGroup.headwords)
+		case cmp > 0:
+			err = emit(DiffSynonymAdded, newGroup.term, newGroup.headwords)
+		default:
+			err = emit(DiffSynonymRemoved, oldGroup.term, sortedDifference(oldGroup.headwords, newGroup.headwords))
+			if err == nil {
+				err = emit(DiffSynonymAdded, newGroup.term, sortedDifference(newGroup.headwords, oldGroup.headwords))
+			}
+		}
+		if err != nil {
+			return err
+		}
+		if cmp <= 0 {
+			oldGroup, oldOK, err = oldStream.next()
+			if err != nil {
+				return err
+			}
+		}
+		if cmp >= 0 {
+			newGroup, newOK, err = newStream.next()
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// synStream is the synonym groups of a side read by a goroutine
+type synStream struct {
+	groups <-chan synGroup
+	errc   <-chan error
+}
+
+// next returns the next group, or false after last group. The error of
+// reader is checked once groups are finished, so a failed side is not
+// taken as a side without more synonyms.
+func (s *synStream) next() (synGroup, bool, error) {
+	group, ok := <-s.groups
+	if ok {
+		return group, true, nil
+	}
+	return group, false, <-s.errc
+}
+
+var errDiffStopped = errors.New("diff stopped")
+
+// sortedDifference returns items of sorted list a that are not in sorted list b
+func sortedDifference(a []string, b []string) []string {
+	var diff []string
+	j := 0
+	for _, item := range a {
+		for j < len(b) && b[j] < item {
+			j++
+		}
+		if j < len(b) && b[j] == item {
+			j++
+			continue
+		}
+		diff = append(diff, item)
+	}
+	return diff
+}
+
+// maxDiffCells limits the size of LCS table of diffLines, larger
+// articles are shown as fully replaced
+const maxDiffCells = 4_000_000
+
+// diffLines returns a line diff of a and b with the given number of
+// context lines around changes
+func diffLines(a []string, b []string, context int) []string {
+	// skip common prefix and suffix
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	midA, midB := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+
+	var ops []string
+	for _, line := range a[:prefix] {
+		ops = append(ops, " "+line)
+	}
+	if len(midA)*len(midB) > maxDiffCells {
+		for _, line := range midA {
+			ops = append(ops, "-"+line)
+		}
+		for _, line := range midB {
+			ops = append(ops, "+"+line)
+		}
+	} else {
+		ops = append(ops, lcsDiff(midA, midB)...)
+	}
+	for _, line := range a[len(a)-suffix:] {
+		ops = append(ops, " "+line)
+	}
+
+	// keep context lines near changes
+	keep := make([]bool, len(ops))
+	for i, op := range ops {
+		if op[0] == ' ' {
+			continue
+		}
+		for j := max(0, i-context); j <= min(len(ops)-1, i+context); j++ {
+			keep[j] = true
+		}
+	}
+	var lines []string
+	for i, op := range ops {
+		if !keep[i] {
+			continue
+		}
+		if len(lines) > 0 && i > 0 && !keep[i-1] {
+			lines = append(lines, "...")
+		}
+		lines = append(lines, op)
+	}
+	return lines
+}
+
+// lcsDiff returns the diff of a and b based on their longest common
+// subsequence
+func lcsDiff(a []string, b []string) []string {
+	n, m := len(a), len(b)
+	// lcs[i*(m+1)+j] is the LCS length of a[i:] and b[j:]
+	lcs := make([]int32, (n+1)*(m+1))
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i*(m+1)+j] = lcs[(i+1)*(m+1)+j+1] + 1
+			} else {
+				lcs[i*(m+1)+j] = max(lcs[(i+1)*(m+1)+j], lcs[i*(m+1)+j+1])
+			}
+		}
+	}
+	var ops []string
+	i, j := 0, 0
+	for i < n && j < m {
+		switch {
+		case a[i] == b[j]:
+			ops = append(ops, " "+a[i])
+			i++
+			j++
+		case lcs[(i+1)*(m+1)+j] >= lcs[i*(m+1)+j+1]:
+			ops = append(ops, "-"+a[i])
+			i++
+		default:
+			ops = append(ops, "+"+b[j])
+			j++
+		}
+	}
+	for ; i < n; i++ {
+		ops = append(ops, "-"+a[i])
+	}
+	for ; j < m; j++ {
+		ops = append(ops, "+"+b[j])
+	}
+	return ops
+}
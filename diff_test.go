@@ -0,0 +1,77 @@
+package stardict
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	dir := t.TempDir()
+	writeTestDict(t, dir, "old", testEntries)
+	writeTestDict(t, dir, "new", []testEntry{
+		{terms: []string{"apple", "pomme"}, defi: "a fruit"},
+		{terms: []string{"banana"}, defi: "a long\nyellow fruit"},
+		{terms: []string{"cherry"}, defi: "a red fruit"},
+	})
+	var entries []string
+	var lines []string
+	err := Diff(filepath.Join(dir, "old.ifo"), filepath.Join(dir, "new.ifo"), func(entry *DiffEntry) error {
+		entries = append(entries, entry.String())
+		if entry.Kind == DiffArticleChanged {
+			lines = entry.Lines
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		`info bookname: "old" -> "new"`,
+		`info idxfilesize: "49" -> "44"`,
+		`info synwordcount: "2" -> "1"`,
+		"changed banana",
+		"added cherry",
+		"removed hello world",
+		"synonym-removed apples -> apple",
+		"synonym-removed hi -> hello world",
+		"synonym-added pomme -> apple",
+	}
+	if !reflect.DeepEqual(entries, expected) {
+		t.Fatalf("unexpected diff:\n%#v", entries)
+	}
+	if !reflect.DeepEqual(lines, []string{" [m]", "-a yellow fruit", "+a long", "+yellow fruit"}) {
+		t.Fatalf("unexpected lines: %#v", lines)
+	}
+}
+
+func TestDiffCorruptSyn(t *testing.T) {
+	dir := t.TempDir()
+	writeTestDict(t, dir, "old", testEntries)
+	writeTestDict(t, dir, "new", testEntries)
+	// a truncated first record
+	if err := os.WriteFile(filepath.Join(dir, "old.syn"), []byte("apples\x00\x00"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	err := Diff(filepath.Join(dir, "old.ifo"), filepath.Join(dir, "new.ifo"), func(entry *DiffEntry) error {
+		if entry.Kind == DiffSynonymAdded || entry.Kind == DiffSynonymRemoved {
+			t.Errorf("unexpected entry of failed side: %v", entry)
+		}
+		return nil
+	})
+	if !errors.Is(err, ErrCorruptSyn) {
+		t.Fatalf("expected ErrCorruptSyn, got %v", err)
+	}
+}
+
+func TestDiffLines(t *testing.T) {
+	a := []string{"1", "2", "3", "4", "5", "6", "7", "8"}
+	b := []string{"1", "x", "3", "4", "5", "6", "7", "y"}
+	lines := diffLines(a, b, 1)
+	expected := []string{" 1", "-2", "+x", " 3", "...", " 7", "-8", "+y"}
+	if !reflect.DeepEqual(lines, expected) {
+		t.Fatalf("unexpected lines: %#v", lines)
+	}
+}
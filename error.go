@@ -1,7 +1,69 @@
 package stardict
 
-import "log/slog"
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+)
 
+// ErrorHandler is the default handler for errors that can not be returned
+// to the caller, for example from Items() of search results.
+// A dictionary opened by OpenWithReport with non-nil handler uses that
+// handler instead.
 var ErrorHandler = func(err error) {
 	slog.Error("error", "err", err)
 }
+
+var (
+	// ErrInvalidFormat is returned for malformed .ifo lines
+	ErrInvalidFormat = errors.New("invalid file format")
+	// ErrMissingVersion is returned if the second line of .ifo is not version
+	ErrMissingVersion = errors.New("version missing (should be on second line)")
+	// ErrUnsupportedVersion is returned for versions other than 2.4.2 and 3.0.0
+	ErrUnsupportedVersion = errors.New("stardict version should be either 2.4.2 or 3.0.0")
+	// ErrCorruptIdx is returned for truncated or malformed .idx files
+	ErrCorruptIdx = errors.New("index file is corrupted")
+	// ErrCorruptSyn is returned for truncated or malformed .syn files
+	ErrCorruptSyn = errors.New("synonym file is corrupted")
+	// ErrCorruptTdx is returned for truncated or malformed .tdx files
+	ErrCorruptTdx = errors.New("tree index file is corrupted")
+	// ErrDictClosed is returned when reading from a closed .dict file
+	ErrDictClosed = errors.New("dict file is closed")
+	// ErrArticleOutOfRange is returned for index entries whose article
+	// is not inside .dict file
+	ErrArticleOutOfRange = errors.New("article is out of range of dict file")
+	// ErrNotLoaded is returned when searching a dictionary that is not loaded
+	ErrNotLoaded = errors.New("dictionary is not loaded")
+	// ErrClosed is returned when using a dictionary after Close
+	ErrClosed = errors.New("dictionary is closed")
+)
+
+// FormatError is an error in the content of a dictionary file.
+// Use errors.Is to check for the underlying sentinel error,
+// for example ErrCorruptSyn.
+type FormatError struct {
+	File   string
+	Offset int64 // byte position in File, -1 if unknown
+	Err    error
+}
+
+func (e *FormatError) Error() string {
+	if e.Offset < 0 {
+		return fmt.Sprintf("%s: %v", e.File, e.Err)
+	}
+	return fmt.Sprintf("%s:%d: %v", e.File, e.Offset, e.Err)
+}
+
+func (e *FormatError) Unwrap() error {
+	return e.Err
+}
+
+// handleError passes err to the dictionary's error handler,
+// falling back to the global ErrorHandler
+func (d *dictionaryImp) handleError(err error) {
+	if d.errorHandler != nil {
+		d.errorHandler(err)
+		return
+	}
+	ErrorHandler(err)
+}
@@ -0,0 +1,92 @@
+package stardict
+
+import (
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFormatErrors(t *testing.T) {
+	dir := t.TempDir()
+	writeTestDict(t, dir, "test", testEntries)
+	ifoPath := filepath.Join(dir, "test.ifo")
+
+	_ = os.WriteFile(ifoPath, []byte("StarDict's dict ifo file\nversion=1.0.0\n"), 0o644)
+	_, err := ReadInfo(ifoPath)
+	var formatErr *FormatError
+	if !errors.Is(err, ErrUnsupportedVersion) || !errors.As(err, &formatErr) {
+		t.Fatalf("expected ErrUnsupportedVersion, got %v", err)
+	}
+	if formatErr.Offset != 25 {
+		t.Errorf("expected offset 25, got %d", formatErr.Offset)
+	}
+
+	writeTestDict(t, dir, "test", testEntries)
+	info, err := ReadInfo(ifoPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idxPath := filepath.Join(dir, "test.idx")
+	synPath := filepath.Join(dir, "test.syn")
+	idxData, _ := os.ReadFile(idxPath)
+	_ = os.WriteFile(idxPath, idxData[:len(idxData)-3], 0o644)
+	_, err = ReadIndex(idxPath, "", info)
+	if !errors.Is(err, ErrCorruptIdx) {
+		t.Fatalf("expected ErrCorruptIdx, got %v", err)
+	}
+
+	_ = os.WriteFile(idxPath, idxData, 0o644)
+	synData, _ := os.ReadFile(synPath)
+	_ = os.WriteFile(synPath, synData[:len(synData)-2], 0o644)
+	_, err = ReadIndex(idxPath, synPath, info)
+	if !errors.Is(err, ErrCorruptSyn) {
+		t.Fatalf("expected ErrCorruptSyn, got %v", err)
+	}
+}
+
+func TestOpenWithReport(t *testing.T) {
+	dir := t.TempDir()
+	writeTestDict(t, dir, "good", testEntries)
+	writeTestDict(t, dir, "bad", testEntries)
+	_ = os.WriteFile(filepath.Join(dir, "bad.syn"), []byte("abc"), 0o644)
+
+	var handled []error
+	dicList, report, err := OpenWithReport([]string{dir}, nil, func(err error) {
+		handled = append(handled, err)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dicList) != 2 || len(report.Dictionaries) != 2 {
+		t.Fatalf("expected 2 dictionaries, got %d and %d results", len(dicList), len(report.Dictionaries))
+	}
+	failed := report.Failed()
+	if len(failed) != 1 || failed[0].DictName != "bad" || !errors.Is(failed[0].Err, ErrCorruptSyn) {
+		t.Fatalf("unexpected failed results: %+v", failed)
+	}
+	if len(handled) != 1 {
+		t.Fatalf("expected 1 handled error, got %v", handled)
+	}
+}
+
+func TestReadSequenceOutOfRange(t *testing.T) {
+	dir := t.TempDir()
+	dictPath := filepath.Join(dir, "test.dict")
+	_ = os.WriteFile(dictPath, []byte("0123456789"), 0o644)
+	dict, err := ReadDict(dictPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dict.Close()
+	if data, err := dict.ReadSequence(6, 4); err != nil || string(data) != "6789" {
+		t.Fatalf("unexpected %#v, %v", string(data), err)
+	}
+	for _, r := range [][2]uint64{{6, 5}, {11, 0}, {1, math.MaxUint64}, {math.MaxUint64, 1}, {0, 1 << 32}} {
+		_, err := dict.ReadSequence(r[0], r[1])
+		if !errors.Is(err, ErrArticleOutOfRange) {
+			t.Fatalf("%v: expected ErrArticleOutOfRange, got %v", r, err)
+		}
+	}
+}
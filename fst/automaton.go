@@ -0,0 +1,98 @@
+package fst
+
+import (
+	"encoding/binary"
+	"unicode/utf8"
+)
+
+// Automaton is a deterministic automaton over bytes, used by FST.Search.
+// States are non-negative ints, negative states are dead.
+type Automaton interface {
+	// Start returns the initial state
+	Start() int
+	// IsMatch returns true if the input consumed so far is accepted
+	IsMatch(state int) bool
+	// CanMatch returns false if no input can lead to a match from state
+	CanMatch(state int) bool
+	// Accept returns the state after consuming b
+	Accept(state int, b byte) int
+}
+
+// dead is the state from which no match is possible
+const dead = -1
+
+type prefixAutomaton string
+
+// Prefix returns an Automaton that accepts all keys starting with prefix
+func Prefix(prefix string) Automaton {
+	return prefixAutomaton(prefix)
+}
+
+// states of prefixAutomaton are the number of matched prefix bytes
+
+func (p prefixAutomaton) Start() int {
+	return 0
+}
+
+func (p prefixAutomaton) IsMatch(state int) bool {
+	return state == len(p)
+}
+
+func (p prefixAutomaton) CanMatch(state int) bool {
+	return state >= 0
+}
+
+func (p prefixAutomaton) Accept(state int, b byte) int {
+	if state < 0 || state == len(p) {
+		return state
+	}
+	if p[state] != b {
+		return dead
+	}
+	return state + 1
+}
+
+// lazyDFA interns the states of an automaton that is determinized
+// while searching, states are identified by their encoded form
+type lazyDFA struct {
+	ids   map[string]int
+	trans map[uint64]int
+}
+
+func newLazyDFA() lazyDFA {
+	return lazyDFA{
+		ids:   map[string]int{},
+		trans: map[uint64]int{},
+	}
+}
+
+func (l *lazyDFA) intern(key []byte, add func() int) int {
+	if id, ok := l.ids[string(key)]; ok {
+		return id
+	}
+	id := add()
+	l.ids[string(key)] = id
+	return id
+}
+
+func transKey(state int, b byte) uint64 {
+	return uint64(state)<<8 | uint64(b)
+}
+
+// decodeRunes calls fn for each complete rune at the start of partial,
+// and returns the remaining incomplete bytes
+func decodeRunes(partial []byte, fn func(r rune)) []byte {
+	for len(partial) > 0 && utf8.FullRune(partial) {
+		r, size := utf8.DecodeRune(partial)
+		fn(r)
+		partial = partial[size:]
+	}
+	return partial
+}
+
+func appendInts(key []byte, list []int) []byte {
+	for _, n := range list {
+		key = binary.AppendUvarint(key, uint64(n))
+	}
+	return key
+}
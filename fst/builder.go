@@ -0,0 +1,143 @@
+package fst
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+type uncompiledArc struct {
+	label  byte
+	output uint64
+	target uint64
+}
+
+type uncompiledNode struct {
+	arcs        []uncompiledArc
+	final       bool
+	finalOutput uint64
+}
+
+// prependOutput adds output to all paths going through node
+func (n *uncompiledNode) prependOutput(output uint64) {
+	if output == 0 {
+		return
+	}
+	for i := range n.arcs {
+		n.arcs[i].output += output
+	}
+	if n.final {
+		n.finalOutput += output
+	}
+}
+
+// Builder builds a minimal FST from keys inserted in sorted order
+type Builder struct {
+	data []byte
+	// register maps encoded nodes to their address, for sharing
+	// identical suffixes
+	register map[string]uint64
+	// frontier holds the nodes on the path of last key
+	frontier []*uncompiledNode
+	last     []byte
+	count    int
+	scratch  []byte
+}
+
+// NewBuilder returns a new Builder
+func NewBuilder() *Builder {
+	return &Builder{
+		data:     append([]byte(nil), magic...),
+		register: map[string]uint64{},
+		frontier: []*uncompiledNode{{}},
+	}
+}
+
+// Insert adds key with value, keys must be inserted in sorted
+// (byte-wise) order without duplicates
+func (b *Builder) Insert(key string, value uint64) error {
+	if b.count > 0 && bytes.Compare([]byte(key), b.last) <= 0 {
+		return ErrOutOfOrder
+	}
+	prefixLen := 0
+	for prefixLen < len(key) && prefixLen < len(b.last) && key[prefixLen] == b.last[prefixLen] {
+		prefixLen++
+	}
+	b.freeze(prefixLen)
+	for i := prefixLen; i < len(key); i++ {
+		b.frontier[i].arcs = append(b.frontier[i].arcs, uncompiledArc{label: key[i]})
+		b.frontier = append(b.frontier, &uncompiledNode{})
+	}
+	b.frontier[len(key)].final = true
+
+	// push outputs of previous keys down where they conflict with value
+	output := value
+	for i := 1; i <= prefixLen; i++ {
+		parent := b.frontier[i-1]
+		lastArc := &parent.arcs[len(parent.arcs)-1]
+		if lastArc.output == 0 {
+			continue
+		}
+		common := min(lastArc.output, output)
+		b.frontier[i].prependOutput(lastArc.output - common)
+		lastArc.output = common
+		output -= common
+	}
+	if prefixLen < len(key) {
+		node := b.frontier[prefixLen]
+		node.arcs[len(node.arcs)-1].output = output
+	} else {
+		// only for empty key
+		b.frontier[len(key)].finalOutput = output
+	}
+
+	b.last = append(b.last[:0], key...)
+	b.count++
+	return nil
+}
+
+// freeze compiles the nodes of frontier deeper than depth
+func (b *Builder) freeze(depth int) {
+	for i := len(b.frontier) - 1; i > depth; i-- {
+		addr := b.compile(b.frontier[i])
+		parent := b.frontier[i-1]
+		parent.arcs[len(parent.arcs)-1].target = addr
+	}
+	b.frontier = b.frontier[:depth+1]
+}
+
+// compile writes node to data, or returns the address of
+// an identical node
+func (b *Builder) compile(n *uncompiledNode) uint64 {
+	enc := b.scratch[:0]
+	if n.final {
+		enc = append(enc, flagFinal)
+		enc = binary.AppendUvarint(enc, n.finalOutput)
+	} else {
+		enc = append(enc, 0)
+	}
+	enc = binary.AppendUvarint(enc, uint64(len(n.arcs)))
+	for _, a := range n.arcs {
+		enc = append(enc, a.label)
+		enc = binary.AppendUvarint(enc, a.output)
+		enc = binary.AppendUvarint(enc, a.target)
+	}
+	b.scratch = enc
+	if addr, ok := b.register[string(enc)]; ok {
+		return addr
+	}
+	addr := uint64(len(b.data))
+	b.data = append(b.data, enc...)
+	b.register[string(enc)] = addr
+	return addr
+}
+
+// Finish compiles the remaining nodes and returns the FST,
+// the Builder must not be used afterwards
+func (b *Builder) Finish() (*FST, error) {
+	b.freeze(0)
+	root := b.compile(b.frontier[0])
+	b.data = binary.LittleEndian.AppendUint64(b.data, root)
+	b.data = binary.LittleEndian.AppendUint64(b.data, uint64(b.count))
+	b.register = nil
+	return Load(b.data)
+}
@@ -0,0 +1,224 @@
+// Package fst implements a finite state transducer that maps sorted
+// byte-string keys to uint64 values. The FST is stored in a compact byte
+// slice, which can be saved and loaded without parsing.
+package fst
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+)
+
+var (
+	// ErrOutOfOrder is returned when keys are not inserted in sorted order,
+	// or a key is inserted twice
+	ErrOutOfOrder = errors.New("fst: keys must be inserted in sorted order")
+	// ErrInvalid is returned by Load for malformed data
+	ErrInvalid = errors.New("fst: invalid data")
+)
+
+var magic = []byte("GOSDFST\x00")
+
+// trailerSize is the size of root address and key count at the end of data
+const trailerSize = 16
+
+// Node layout (integers are uvarint):
+//
+//	flags (1 byte), finalOutput (only if final), arc count
+//	for each arc, sorted by label: label (1 byte), output, target address
+//
+// The output of a key is the sum of arc outputs on its path, plus the
+// final output of its last node.
+
+const flagFinal = 1
+
+// FST is an immutable finite state transducer, safe for concurrent use
+type FST struct {
+	data  []byte
+	root  uint64
+	count int
+}
+
+// Load returns the FST stored in data (as returned by Bytes),
+// data is used without copying and must not be modified
+func Load(data []byte) (*FST, error) {
+	if len(data) < len(magic)+trailerSize || !bytes.HasPrefix(data, magic) {
+		return nil, ErrInvalid
+	}
+	trailer := data[len(data)-trailerSize:]
+	root := binary.LittleEndian.Uint64(trailer)
+	count := binary.LittleEndian.Uint64(trailer[8:])
+	if root < uint64(len(magic)) || root >= uint64(len(data)-trailerSize) {
+		return nil, ErrInvalid
+	}
+	if count > uint64(len(data)) {
+		return nil, ErrInvalid
+	}
+	return &FST{
+		data:  data,
+		root:  root,
+		count: int(count),
+	}, nil
+}
+
+// Bytes returns the serialized FST
+func (f *FST) Bytes() []byte {
+	return f.data
+}
+
+// Len returns the number of keys
+func (f *FST) Len() int {
+	return f.count
+}
+
+// Size returns the size of serialized FST in bytes
+func (f *FST) Size() int {
+	return len(f.data)
+}
+
+type node struct {
+	final       bool
+	finalOutput uint64
+	arcCount    int
+	// arcsPos is the position of first arc in data
+	arcsPos int
+}
+
+type arc struct {
+	label  byte
+	output uint64
+	target uint64
+}
+
+// uvarint reads a number at pos, next is negative for invalid data
+func (f *FST) uvarint(pos int) (n uint64, next int) {
+	if pos < 0 || pos >= len(f.data) {
+		return 0, -1
+	}
+	n, size := binary.Uvarint(f.data[pos:])
+	if size <= 0 {
+		return 0, -1
+	}
+	return n, pos + size
+}
+
+func (f *FST) node(addr uint64) (node, bool) {
+	if addr >= uint64(len(f.data)-trailerSize) {
+		return node{}, false
+	}
+	pos := int(addr)
+	n := node{final: f.data[pos]&flagFinal != 0}
+	pos++
+	if n.final {
+		n.finalOutput, pos = f.uvarint(pos)
+	}
+	count, pos := f.uvarint(pos)
+	if pos < 0 || count > 256 {
+		return node{}, false
+	}
+	n.arcCount = int(count)
+	n.arcsPos = pos
+	return n, true
+}
+
+// arc reads the arc at pos, and returns the position of next arc
+func (f *FST) arc(pos int) (arc, int) {
+	if pos < 0 || pos >= len(f.data) {
+		return arc{}, -1
+	}
+	a := arc{label: f.data[pos]}
+	a.output, pos = f.uvarint(pos + 1)
+	a.target, pos = f.uvarint(pos)
+	return a, pos
+}
+
+// findArc returns the arc of node with the given label
+func (f *FST) findArc(n node, label byte) (arc, bool) {
+	pos := n.arcsPos
+	for range n.arcCount {
+		var a arc
+		a, pos = f.arc(pos)
+		if pos < 0 || a.label > label {
+			break
+		}
+		if a.label == label {
+			return a, true
+		}
+	}
+	return arc{}, false
+}
+
+// Get returns the value of key
+func (f *FST) Get(key string) (uint64, bool) {
+	addr := f.root
+	var output uint64
+	for i := 0; i < len(key); i++ {
+		n, ok := f.node(addr)
+		if !ok {
+			return 0, false
+		}
+		a, ok := f.findArc(n, key[i])
+		if !ok {
+			return 0, false
+		}
+		output += a.output
+		addr = a.target
+	}
+	n, ok := f.node(addr)
+	if !ok || !n.final {
+		return 0, false
+	}
+	return output + n.finalOutput, true
+}
+
+// Search calls fn for each key accepted by automaton, in sorted order,
+// until fn returns false. key is only valid during the call.
+func (f *FST) Search(automaton Automaton, fn func(key []byte, value uint64) bool) {
+	start := automaton.Start()
+	if !automaton.CanMatch(start) {
+		return
+	}
+	key := make([]byte, 0, 64)
+	f.search(f.root, start, 0, key, automaton, fn)
+}
+
+func (f *FST) search(
+	addr uint64,
+	state int,
+	output uint64,
+	key []byte,
+	automaton Automaton,
+	fn func(key []byte, value uint64) bool,
+) bool {
+	n, ok := f.node(addr)
+	if !ok {
+		return true
+	}
+	if n.final && automaton.IsMatch(state) {
+		if !fn(key, output+n.finalOutput) {
+			return false
+		}
+	}
+	pos := n.arcsPos
+	for range n.arcCount {
+		var a arc
+		a, pos = f.arc(pos)
+		if pos < 0 {
+			return true
+		}
+		next := automaton.Accept(state, a.label)
+		if !automaton.CanMatch(next) {
+			continue
+		}
+		if !f.search(a.target, next, output+a.output, append(key, a.label), automaton, fn) {
+			return false
+		}
+	}
+	return true
+}
+
+// WalkPrefix calls fn for each key starting with prefix, in sorted order,
+// until fn returns false
+func (f *FST) WalkPrefix(prefix string, fn func(key []byte, value uint64) bool) {
+	f.Search(Prefix(prefix), fn)
+}
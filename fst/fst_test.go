@@ -0,0 +1,176 @@
+package fst
+
+import (
+	"fmt"
+	"math/rand"
+	"regexp"
+	"sort"
+	"testing"
+)
+
+func buildTestFST(t *testing.T, keys []string) *FST {
+	t.Helper()
+	b := NewBuilder()
+	for i, key := range keys {
+		if err := b.Insert(key, uint64(i)*3); err != nil {
+			t.Fatal(err)
+		}
+	}
+	f, err := b.Finish()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func randomKeys(n int) []string {
+	rnd := rand.New(rand.NewSource(1))
+	letters := []rune("abcdeé ")
+	set := map[string]bool{}
+	for len(set) < n {
+		word := make([]rune, 1+rnd.Intn(8))
+		for i := range word {
+			word[i] = letters[rnd.Intn(len(letters))]
+		}
+		set[string(word)] = true
+	}
+	keys := make([]string, 0, n)
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func collect(f *FST, a Automaton) map[string]uint64 {
+	result := map[string]uint64{}
+	f.Search(a, func(key []byte, value uint64) bool {
+		result[string(key)] = value
+		return true
+	})
+	return result
+}
+
+func TestGet(t *testing.T) {
+	keys := randomKeys(2000)
+	f := buildTestFST(t, append([]string{""}, keys...))
+	if f.Len() != len(keys)+1 {
+		t.Fatalf("expected %d keys, got %d", len(keys)+1, f.Len())
+	}
+	f, err := Load(f.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value, ok := f.Get(""); !ok || value != 0 {
+		t.Fatalf("empty key: %v %v", value, ok)
+	}
+	for i, key := range keys {
+		value, ok := f.Get(key)
+		if !ok || value != uint64(i+1)*3 {
+			t.Fatalf("key %q: expected %d, got %d, %v", key, (i+1)*3, value, ok)
+		}
+		if _, ok := f.Get(key + "x"); ok {
+			t.Fatalf("unexpected key %q", key+"x")
+		}
+	}
+	var walked []string
+	f.WalkPrefix("", func(key []byte, value uint64) bool {
+		walked = append(walked, string(key))
+		return true
+	})
+	if len(walked) != len(keys)+1 || !sort.StringsAreSorted(walked) {
+		t.Fatalf("walk returned %d keys", len(walked))
+	}
+}
+
+func TestInsertOutOfOrder(t *testing.T) {
+	b := NewBuilder()
+	_ = b.Insert("b", 1)
+	if err := b.Insert("a", 2); err != ErrOutOfOrder {
+		t.Fatalf("expected ErrOutOfOrder, got %v", err)
+	}
+	if err := b.Insert("b", 2); err != ErrOutOfOrder {
+		t.Fatalf("expected ErrOutOfOrder for duplicate, got %v", err)
+	}
+}
+
+func levenshtein(a, b []rune) int {
+	row := make([]int, len(b)+1)
+	for j := range row {
+		row[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		prev := row[0]
+		row[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur := min(row[j]+1, row[j-1]+1, prev+cost)
+			prev = row[j]
+			row[j] = cur
+		}
+	}
+	return row[len(b)]
+}
+
+func TestAutomata(t *testing.T) {
+	keys := randomKeys(2000)
+	f := buildTestFST(t, keys)
+	tests := []struct {
+		name      string
+		automaton func() (Automaton, error)
+		match     func(key string) bool
+	}{
+		{
+			name:      "prefix",
+			automaton: func() (Automaton, error) { return Prefix("ab"), nil },
+			match:     func(key string) bool { return len(key) >= 2 && key[:2] == "ab" },
+		},
+		{
+			name:      "levenshtein",
+			automaton: func() (Automaton, error) { return NewLevenshtein("abéd", 1), nil },
+			match: func(key string) bool {
+				return levenshtein([]rune(key), []rune("abéd")) <= 1
+			},
+		},
+	}
+	for _, pattern := range []string{`a.c`, `(?i)AB.*`, `[^a]+é`, `^e|d$`, `(a|bc)*d?`} {
+		re := regexp.MustCompile("^(?:" + pattern + ")$")
+		tests = append(tests, struct {
+			name      string
+			automaton func() (Automaton, error)
+			match     func(key string) bool
+		}{
+			name:      fmt.Sprintf("regexp %s", pattern),
+			automaton: func() (Automaton, error) { return NewRegexp(pattern) },
+			match:     re.MatchString,
+		})
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			a, err := test.automaton()
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := collect(f, a)
+			count := 0
+			for _, key := range keys {
+				if !test.match(key) {
+					continue
+				}
+				count++
+				if _, ok := got[key]; !ok {
+					t.Fatalf("missing key %q", key)
+				}
+			}
+			if count != len(got) {
+				t.Fatalf("expected %d keys, got %d", count, len(got))
+			}
+		})
+	}
+	if _, err := NewRegexp(`\bab`); err != ErrUnsupported {
+		t.Fatalf("expected ErrUnsupported, got %v", err)
+	}
+}
@@ -0,0 +1,106 @@
+package fst
+
+// Levenshtein is an Automaton accepting keys within a maximum
+// Levenshtein distance (counted in runes) of a query. It is built lazily
+// while searching, so it is not safe for concurrent use.
+type Levenshtein struct {
+	query       []rune
+	maxDistance int
+	states      []levState
+	dfa         lazyDFA
+}
+
+type levState struct {
+	// row is the last row of edit distance matrix, capped at maxDistance+1
+	row []int
+	// partial is the incomplete UTF-8 sequence consumed so far
+	partial []byte
+}
+
+// NewLevenshtein returns an Automaton accepting keys whose Levenshtein
+// distance to query is at most maxDistance
+func NewLevenshtein(query string, maxDistance int) *Levenshtein {
+	l := &Levenshtein{
+		query:       []rune(query),
+		maxDistance: maxDistance,
+		dfa:         newLazyDFA(),
+	}
+	row := make([]int, len(l.query)+1)
+	for i := range row {
+		row[i] = min(i, maxDistance+1)
+	}
+	l.addState(row, nil)
+	return l
+}
+
+func (l *Levenshtein) addState(row []int, partial []byte) int {
+	key := appendInts(nil, row)
+	key = append(key, 0xff)
+	key = append(key, partial...)
+	return l.dfa.intern(key, func() int {
+		l.states = append(l.states, levState{
+			row:     row,
+			partial: append([]byte(nil), partial...),
+		})
+		return len(l.states) - 1
+	})
+}
+
+func (l *Levenshtein) step(row []int, r rune) []int {
+	next := make([]int, len(row))
+	next[0] = min(row[0]+1, l.maxDistance+1)
+	for i := 1; i < len(row); i++ {
+		cost := 1
+		if l.query[i-1] == r {
+			cost = 0
+		}
+		next[i] = min(row[i-1]+cost, row[i]+1, next[i-1]+1, l.maxDistance+1)
+	}
+	return next
+}
+
+func (l *Levenshtein) Start() int {
+	return 0
+}
+
+func (l *Levenshtein) IsMatch(state int) bool {
+	if state < 0 {
+		return false
+	}
+	s := l.states[state]
+	return len(s.partial) == 0 && s.row[len(s.row)-1] <= l.maxDistance
+}
+
+func (l *Levenshtein) CanMatch(state int) bool {
+	if state < 0 {
+		return false
+	}
+	for _, dist := range l.states[state].row {
+		if dist <= l.maxDistance {
+			return true
+		}
+	}
+	return false
+}
+
+func (l *Levenshtein) Accept(state int, b byte) int {
+	if state < 0 {
+		return dead
+	}
+	tk := transKey(state, b)
+	if next, ok := l.dfa.trans[tk]; ok {
+		return next
+	}
+	s := l.states[state]
+	row := s.row
+	partial := append(append([]byte(nil), s.partial...), b)
+	partial = decodeRunes(partial, func(r rune) {
+		row = l.step(row, r)
+	})
+	next := l.addState(row, partial)
+	if !l.CanMatch(next) {
+		next = dead
+	}
+	l.dfa.trans[tk] = next
+	return next
+}
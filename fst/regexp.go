@@ -0,0 +1,218 @@
+package fst
+
+import (
+	"errors"
+	"regexp/syntax"
+	"sort"
+)
+
+// ErrUnsupported is returned by NewRegexp for patterns that can not be
+// converted to an Automaton, such as word boundaries
+var ErrUnsupported = errors.New("fst: unsupported regular expression")
+
+const (
+	emptyBegin = syntax.EmptyBeginLine | syntax.EmptyBeginText
+	emptyEnd   = syntax.EmptyEndLine | syntax.EmptyEndText
+)
+
+// Regexp is an Automaton accepting keys that fully match a regular
+// expression (as if it was enclosed in ^ and $). It is built lazily
+// while searching, so it is not safe for concurrent use.
+type Regexp struct {
+	prog   *syntax.Prog
+	states []regState
+	dfa    lazyDFA
+}
+
+type regState struct {
+	// pcs are the sorted instructions waiting for next rune,
+	// and empty-width assertions that may match at the end
+	pcs     []int
+	partial []byte
+}
+
+// NewRegexp returns an Automaton for pattern, parsed with syntax.Perl
+// flags (like regexp.Compile)
+func NewRegexp(pattern string) (*Regexp, error) {
+	re, err := syntax.Parse(pattern, syntax.Perl)
+	if err != nil {
+		return nil, err
+	}
+	prog, err := syntax.Compile(re.Simplify())
+	if err != nil {
+		return nil, err
+	}
+	for _, inst := range prog.Inst {
+		if inst.Op == syntax.InstEmptyWidth && syntax.EmptyOp(inst.Arg)&^(emptyBegin|emptyEnd) != 0 {
+			return nil, ErrUnsupported
+		}
+	}
+	r := &Regexp{
+		prog: prog,
+		dfa:  newLazyDFA(),
+	}
+	set := map[int]bool{}
+	r.closure(set, prog.Start, true)
+	r.addState(set, nil, true)
+	return r, nil
+}
+
+// closure adds pc and the instructions reachable from it without
+// consuming input to set
+func (r *Regexp) closure(set map[int]bool, pc int, atStart bool) {
+	if set[pc] {
+		return
+	}
+	inst := &r.prog.Inst[pc]
+	switch inst.Op {
+	case syntax.InstFail:
+		return
+	case syntax.InstAlt, syntax.InstAltMatch:
+		set[pc] = true
+		r.closure(set, int(inst.Out), atStart)
+		r.closure(set, int(inst.Arg), atStart)
+	case syntax.InstCapture, syntax.InstNop:
+		set[pc] = true
+		r.closure(set, int(inst.Out), atStart)
+	case syntax.InstEmptyWidth:
+		set[pc] = true
+		if atStart && syntax.EmptyOp(inst.Arg)&^emptyBegin == 0 {
+			r.closure(set, int(inst.Out), atStart)
+		}
+	default:
+		set[pc] = true
+	}
+}
+
+// addState interns the state of set and partial, start state is kept
+// separate since begin assertions only match there
+func (r *Regexp) addState(set map[int]bool, partial []byte, start bool) int {
+	pcs := make([]int, 0, len(set))
+	for pc := range set {
+		switch r.prog.Inst[pc].Op {
+		case syntax.InstAlt, syntax.InstAltMatch, syntax.InstCapture, syntax.InstNop:
+			// already expanded
+			continue
+		}
+		pcs = append(pcs, pc)
+	}
+	sort.Ints(pcs)
+	if !start && len(pcs) == 0 && len(partial) == 0 {
+		return dead
+	}
+	var key []byte
+	if start {
+		key = append(key, 0xfe)
+	}
+	key = appendInts(key, pcs)
+	key = append(key, 0xff)
+	key = append(key, partial...)
+	return r.dfa.intern(key, func() int {
+		r.states = append(r.states, regState{
+			pcs:     pcs,
+			partial: append([]byte(nil), partial...),
+		})
+		return len(r.states) - 1
+	})
+}
+
+// matchesAtEnd returns true if pc reaches Match with only end
+// assertions (and begin assertions if nothing is consumed)
+func (r *Regexp) matchesAtEnd(pc int, allowed syntax.EmptyOp, seen map[int]bool) bool {
+	if seen[pc] {
+		return false
+	}
+	seen[pc] = true
+	inst := &r.prog.Inst[pc]
+	switch inst.Op {
+	case syntax.InstMatch:
+		return true
+	case syntax.InstAlt, syntax.InstAltMatch:
+		return r.matchesAtEnd(int(inst.Out), allowed, seen) || r.matchesAtEnd(int(inst.Arg), allowed, seen)
+	case syntax.InstCapture, syntax.InstNop:
+		return r.matchesAtEnd(int(inst.Out), allowed, seen)
+	case syntax.InstEmptyWidth:
+		if syntax.EmptyOp(inst.Arg)&^allowed != 0 {
+			return false
+		}
+		return r.matchesAtEnd(int(inst.Out), allowed, seen)
+	}
+	return false
+}
+
+func (r *Regexp) Start() int {
+	return 0
+}
+
+func (r *Regexp) IsMatch(state int) bool {
+	if state < 0 {
+		return false
+	}
+	s := r.states[state]
+	if len(s.partial) > 0 {
+		return false
+	}
+	allowed := emptyEnd
+	if state == 0 {
+		allowed |= emptyBegin
+	}
+	seen := map[int]bool{}
+	for _, pc := range s.pcs {
+		if r.matchesAtEnd(pc, allowed, seen) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Regexp) CanMatch(state int) bool {
+	return state >= 0
+}
+
+func (r *Regexp) step(pcs []int, c rune) []int {
+	set := map[int]bool{}
+	for _, pc := range pcs {
+		inst := &r.prog.Inst[pc]
+		switch inst.Op {
+		case syntax.InstRune, syntax.InstRune1, syntax.InstRuneAny:
+			if inst.Op == syntax.InstRuneAny || inst.MatchRune(c) {
+				r.closure(set, int(inst.Out), false)
+			}
+		case syntax.InstRuneAnyNotNL:
+			if c != '\n' {
+				r.closure(set, int(inst.Out), false)
+			}
+		}
+	}
+	next := make([]int, 0, len(set))
+	for pc := range set {
+		next = append(next, pc)
+	}
+	return next
+}
+
+func (r *Regexp) Accept(state int, b byte) int {
+	if state < 0 {
+		return dead
+	}
+	tk := transKey(state, b)
+	if next, ok := r.dfa.trans[tk]; ok {
+		return next
+	}
+	s := r.states[state]
+	pcs := s.pcs
+	partial := append(append([]byte(nil), s.partial...), b)
+	partial = decodeRunes(partial, func(c rune) {
+		pcs = r.step(pcs, c)
+	})
+	set := make(map[int]bool, len(pcs))
+	for _, pc := range pcs {
+		set[pc] = true
+	}
+	next := dead
+	if len(pcs) > 0 {
+		next = r.addState(set, partial, false)
+	}
+	r.dfa.trans[tk] = next
+	return next
+}
@@ -4,5 +4,9 @@ go 1.22
 
 require (
 	codeberg.org/ilius/go-dict-commons v0.7.0
+	github.com/fsnotify/fsnotify v1.8.0
 	github.com/ilius/glob v0.0.0-20250212111036-4c41f838a304
+	golang.org/x/text v0.22.0
 )
+
+require golang.org/x/sys v0.13.0 // indirect
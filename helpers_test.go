@@ -0,0 +1,76 @@
+package stardict
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	// do not write snapshots into user's cache directory
+	IndexCacheDir = ""
+	os.Exit(m.Run())
+}
+
+type testEntry struct {
+	terms []string
+	defi  string
+}
+
+// writeTestDict writes a minimal dictionary with sametypesequence=m,
+// entries must already be sorted
+func writeTestDict(t *testing.T, dir string, name string, entries []testEntry) {
+	t.Helper()
+	var dictBuf, idxBuf, synBuf bytes.Buffer
+	synCount := 0
+	for i, entry := range entries {
+		offset := dictBuf.Len()
+		dictBuf.WriteString(entry.defi)
+		idxBuf.WriteString(entry.terms[0])
+		idxBuf.WriteByte(0)
+		_ = binary.Write(&idxBuf, binary.BigEndian, uint32(offset))
+		_ = binary.Write(&idxBuf, binary.BigEndian, uint32(len(entry.defi)))
+		for _, alt := range entry.terms[1:] {
+			synBuf.WriteString(alt)
+			synBuf.WriteByte(0)
+			_ = binary.Write(&synBuf, binary.BigEndian, uint32(i))
+			synCount++
+		}
+	}
+	ifo := fmt.Sprintf(
+		"StarDict's dict ifo file\nversion=3.0.0\nbookname=%s\nwordcount=%d\nsynwordcount=%d\nidxfilesize=%d\nsametypesequence=m\n",
+		name, len(entries), synCount, idxBuf.Len(),
+	)
+	write := func(ext string, data []byte) {
+		err := os.WriteFile(filepath.Join(dir, name+ext), data, 0o644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(".ifo", []byte(ifo))
+	write(".idx", idxBuf.Bytes())
+	write(".dict", dictBuf.Bytes())
+	if synCount > 0 {
+		write(".syn", synBuf.Bytes())
+	}
+}
+
+var testEntries = []testEntry{
+	{terms: []string{"apple", "apples"}, defi: "a fruit"},
+	{terms: []string{"banana"}, defi: "a yellow fruit"},
+	{terms: []string{"hello world", "hi"}, defi: "a greeting"},
+}
+
+func openTestDict(t *testing.T, entries []testEntry) *dictionaryImp {
+	t.Helper()
+	dir := t.TempDir()
+	writeTestDict(t, dir, "test", entries)
+	d, err := NewDictionary(dir, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
@@ -0,0 +1,81 @@
+package hunspell
+
+import (
+	"fmt"
+	"strings"
+)
+
+// charClass matches one character of an affix condition
+type charClass struct {
+	chars  string
+	negate bool
+	any    bool
+}
+
+func (c charClass) match(r rune) bool {
+	if c.any {
+		return true
+	}
+	return strings.ContainsRune(c.chars, r) != c.negate
+}
+
+// condition is the simplified regular expression of an affix rule,
+// for example "[^aeiou]y", which is matched against the end of the stem
+// for suffixes and against the start of the stem for prefixes
+type condition struct {
+	classes []charClass
+	suffix  bool
+}
+
+func parseCondition(str string, suffix bool) (*condition, error) {
+	cond := &condition{suffix: suffix}
+	if str == "." {
+		return cond, nil
+	}
+	runes := []rune(str)
+	for i := 0; i < len(runes); i++ {
+		switch runes[i] {
+		case '.':
+			cond.classes = append(cond.classes, charClass{any: true})
+		case '[':
+			end := i + 1
+			for end < len(runes) && runes[end] != ']' {
+				end++
+			}
+			if end == len(runes) {
+				return nil, fmt.Errorf("unclosed bracket in condition %#v", str)
+			}
+			class := charClass{}
+			chars := runes[i+1 : end]
+			if len(chars) > 0 && chars[0] == '^' {
+				class.negate = true
+				chars = chars[1:]
+			}
+			class.chars = string(chars)
+			cond.classes = append(cond.classes, class)
+			i = end
+		default:
+			cond.classes = append(cond.classes, charClass{chars: string(runes[i])})
+		}
+	}
+	return cond, nil
+}
+
+func (cond *condition) match(stem string) bool {
+	if len(cond.classes) == 0 {
+		return true
+	}
+	runes := []rune(stem)
+	if len(runes) < len(cond.classes) {
+		return false
+	}
+	if cond.suffix {
+		runes = runes[len(runes)-len(cond.classes):]
+	}
+	for i, class := range cond.classes {
+		if !class.match(runes[i]) {
+			return false
+		}
+	}
+	return true
+}
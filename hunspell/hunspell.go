@@ -0,0 +1,388 @@
+/*
+Package hunspell reads Hunspell affix (.aff) and dictionary (.dic) files
+and generates candidate stems of inflected words.
+
+Only the parts of the format needed for stemming are supported:
+prefix and suffix rules (PFX/SFX) with conditions, cross product of
+one prefix and one suffix, and the FLAG, SET and NEEDAFFIX directives.
+Compounding and suggestion rules are ignored.
+*/
+package hunspell
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"golang.org/x/text/encoding/htmlindex"
+)
+
+type flagType uint8
+
+const (
+	flagChar flagType = iota
+	flagLong
+	flagNum
+	flagUTF8
+)
+
+type affix struct {
+	flag      string
+	strip     string
+	add       string
+	cond      *condition
+	crossProd bool
+}
+
+// Dictionary holds affix rules and stems of one Hunspell dictionary
+type Dictionary struct {
+	flagType  flagType
+	encoding  string
+	needAffix string
+
+	prefixes []*affix
+	suffixes []*affix
+
+	// words maps a stem (and its lowercase form) to its affix flags
+	words map[string]map[string]bool
+}
+
+// Open reads a pair of .aff and .dic files
+func Open(affPath string, dicPath string) (*Dictionary, error) {
+	d := &Dictionary{
+		words: map[string]map[string]bool{},
+	}
+	affData, err := os.ReadFile(affPath)
+	if err != nil {
+		return nil, err
+	}
+	// SET must be known before decoding the rest of .aff file
+	d.encoding = findEncoding(affData)
+	affReader, err := d.decode(affData)
+	if err != nil {
+		return nil, err
+	}
+	err = d.parseAff(affReader)
+	if err != nil {
+		return nil, err
+	}
+	dicData, err := os.ReadFile(dicPath)
+	if err != nil {
+		return nil, err
+	}
+	dicReader, err := d.decode(dicData)
+	if err != nil {
+		return nil, err
+	}
+	err = d.parseDic(dicReader)
+	if err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// OpenDir reads all .aff / .dic pairs with the same base name in dir
+func OpenDir(dir string) ([]*Dictionary, error) {
+	affPaths, err := filepath.Glob(filepath.Join(dir, "*.aff"))
+	if err != nil {
+		return nil, err
+	}
+	var list []*Dictionary
+	for _, affPath := range affPaths {
+		dicPath := strings.TrimSuffix(affPath, ".aff") + ".dic"
+		if _, err := os.Stat(dicPath); err != nil {
+			continue
+		}
+		d, err := Open(affPath, dicPath)
+		if err != nil {
+			return nil, fmt.Errorf("error reading %#v: %w", affPath, err)
+		}
+		list = append(list, d)
+	}
+	return list, nil
+}
+
+func findEncoding(affData []byte) string {
+	for _, line := range bytes.Split(affData, []byte{'\n'}) {
+		fields := strings.Fields(string(line))
+		if len(fields) > 1 && fields[0] == "SET" {
+			return fields[1]
+		}
+	}
+	return ""
+}
+
+func (d *Dictionary) decode(data []byte) (io.Reader, error) {
+	reader := bytes.NewReader(data)
+	if d.encoding == "" || strings.EqualFold(d.encoding, "UTF-8") {
+		return reader, nil
+	}
+	enc, err := htmlindex.Get(d.encoding)
+	if err != nil {
+		return nil, fmt.Errorf("unsupported encoding %#v: %w", d.encoding, err)
+	}
+	return enc.NewDecoder().Reader(reader), nil
+}
+
+func (d *Dictionary) parseAff(reader io.Reader) error {
+	scanner := bufio.NewScanner(reader)
+	lineNum := 0
+	// the number of remaining rule lines for the current PFX/SFX header
+	remaining := 0
+	var crossProd bool
+	for scanner.Scan() {
+		lineNum++
+		line := scanner.Text()
+		if lineNum == 1 {
+			line = strings.TrimPrefix(line, "\ufeff")
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		switch fields[0] {
+		case "FLAG":
+			if len(fields) > 1 {
+				switch fields[1] {
+				case "long":
+					d.flagType = flagLong
+				case "num":
+					d.flagType = flagNum
+				case "UTF-8":
+					d.flagType = flagUTF8
+				}
+			}
+		case "NEEDAFFIX":
+			if len(fields) > 1 {
+				d.needAffix = fields[1]
+			}
+		case "PFX", "SFX":
+			if remaining == 0 {
+				if len(fields) < 4 {
+					return fmt.Errorf("line %d: invalid affix header", lineNum)
+				}
+				count, err := strconv.Atoi(fields[3])
+				if err != nil {
+					return fmt.Errorf("line %d: invalid affix count: %w", lineNum, err)
+				}
+				crossProd = fields[2] == "Y"
+				remaining = count
+				continue
+			}
+			remaining--
+			if len(fields) < 4 {
+				return fmt.Errorf("line %d: invalid affix rule", lineNum)
+			}
+			a, err := d.parseAffix(fields, crossProd, fields[0] == "SFX")
+			if err != nil {
+				return fmt.Errorf("line %d: %w", lineNum, err)
+			}
+			if fields[0] == "SFX" {
+				d.suffixes = append(d.suffixes, a)
+			} else {
+				d.prefixes = append(d.prefixes, a)
+			}
+		}
+	}
+	return scanner.Err()
+}
+
+func (d *Dictionary) parseAffix(fields []string, crossProd bool, suffix bool) (*affix, error) {
+	a := &affix{
+		flag:      fields[1],
+		strip:     fields[2],
+		add:       fields[3],
+		crossProd: crossProd,
+	}
+	if a.strip == "0" {
+		a.strip = ""
+	}
+	// continuation classes (twofold affixes) are not supported
+	if i := strings.IndexByte(a.add, '/'); i >= 0 {
+		a.add = a.add[:i]
+	}
+	if a.add == "0" {
+		a.add = ""
+	}
+	condStr := "."
+	if len(fields) > 4 {
+		condStr = fields[4]
+	}
+	cond, err := parseCondition(condStr, suffix)
+	if err != nil {
+		return nil, err
+	}
+	a.cond = cond
+	return a, nil
+}
+
+func (d *Dictionary) parseDic(reader io.Reader) error {
+	scanner := bufio.NewScanner(reader)
+	first := true
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if first {
+			first = false
+			// first line is the approximate word count
+			if _, err := strconv.Atoi(strings.TrimPrefix(line, "\ufeff")); err == nil {
+				continue
+			}
+		}
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		// morphological fields are separated by whitespace
+		if i := strings.IndexAny(line, " \t"); i >= 0 {
+			line = line[:i]
+		}
+		word, flagStr := splitDicLine(line)
+		flags := d.parseFlags(flagStr)
+		d.addWord(word, flags)
+		if lower := strings.ToLower(word); lower != word {
+			d.addWord(lower, flags)
+		}
+	}
+	return scanner.Err()
+}
+
+// splitDicLine splits "word/flags", where slash in word can be escaped
+func splitDicLine(line string) (string, string) {
+	for i := 0; i < len(line); i++ {
+		switch line[i] {
+		case '\\':
+			i++
+		case '/':
+			return strings.ReplaceAll(line[:i], `\/`, "/"), line[i+1:]
+		}
+	}
+	return strings.ReplaceAll(line, `\/`, "/"), ""
+}
+
+func (d *Dictionary) addWord(word string, flags []string) {
+	m, ok := d.words[word]
+	if !ok {
+		m = map[string]bool{}
+		d.words[word] = m
+	}
+	for _, flag := range flags {
+		m[flag] = true
+	}
+}
+
+func (d *Dictionary) parseFlags(str string) []string {
+	if str == "" {
+		return nil
+	}
+	switch d.flagType {
+	case flagLong:
+		var flags []string
+		for i := 0; i+1 < len(str); i += 2 {
+			flags = append(flags, str[i:i+2])
+		}
+		return flags
+	case flagNum:
+		return strings.Split(str, ",")
+	case flagUTF8:
+		var flags []string
+		for _, r := range str {
+			flags = append(flags, string(r))
+		}
+		return flags
+	}
+	flags := make([]string, len(str))
+	for i := range len(str) {
+		flags[i] = str[i : i+1]
+	}
+	return flags
+}
+
+// hasFlag returns true if stem exists and allows the affix with given flag
+func (d *Dictionary) hasFlag(stem string, flag string) bool {
+	flags, ok := d.words[stem]
+	if !ok {
+		return false
+	}
+	return flags[flag]
+}
+
+// IsWord returns true if word is a stem in the dictionary which may
+// appear without affixes
+func (d *Dictionary) IsWord(word string) bool {
+	flags, ok := d.words[word]
+	if !ok {
+		return false
+	}
+	return d.needAffix == "" || !flags[d.needAffix]
+}
+
+// Stems returns the stems that word can be derived from by removing
+// one prefix, one suffix, or both. word itself is not included.
+func (d *Dictionary) Stems(word string) []string {
+	var stems []string
+	seen := map[string]bool{word: true}
+	add := func(stem string) {
+		if seen[stem] {
+			return
+		}
+		seen[stem] = true
+		stems = append(stems, stem)
+	}
+	for _, sfx := range d.suffixes {
+		stem, ok := sfx.removeSuffix(word)
+		if !ok {
+			continue
+		}
+		if d.hasFlag(stem, sfx.flag) {
+			add(stem)
+		}
+		if !sfx.crossProd {
+			continue
+		}
+		for _, pfx := range d.prefixes {
+			if !pfx.crossProd {
+				continue
+			}
+			stem2, ok := pfx.removePrefix(stem)
+			if !ok {
+				continue
+			}
+			if d.hasFlag(stem2, sfx.flag) && d.hasFlag(stem2, pfx.flag) {
+				add(stem2)
+			}
+		}
+	}
+	for _, pfx := range d.prefixes {
+		stem, ok := pfx.removePrefix(word)
+		if ok && d.hasFlag(stem, pfx.flag) {
+			add(stem)
+		}
+	}
+	return stems
+}
+
+func (a *affix) removeSuffix(word string) (string, bool) {
+	if !strings.HasSuffix(word, a.add) || len(word) == len(a.add) && a.strip == "" {
+		return "", false
+	}
+	stem := word[:len(word)-len(a.add)] + a.strip
+	if !a.cond.match(stem) {
+		return "", false
+	}
+	return stem, true
+}
+
+func (a *affix) removePrefix(word string) (string, bool) {
+	if !strings.HasPrefix(word, a.add) || len(word) == len(a.add) && a.strip == "" {
+		return "", false
+	}
+	stem := a.strip + word[len(a.add):]
+	if !a.cond.match(stem) {
+		return "", false
+	}
+	return stem, true
+}
@@ -0,0 +1,42 @@
+package hunspell
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestStems(t *testing.T) {
+	d, err := Open("testdata/en.aff", "testdata/en.dic")
+	if err != nil {
+		t.Fatal(err)
+	}
+	test := func(word string, expected ...string) {
+		t.Helper()
+		stems := d.Stems(word)
+		if !reflect.DeepEqual(stems, expected) {
+			t.Errorf("Stems(%#v): expected %#v, got %#v", word, expected, stems)
+		}
+	}
+	test("flies", "fly")
+	test("boxes", "box")
+	test("making", "make")
+	test("unmaking", "make")
+	test("running", "run")
+	test("undo", "do")
+	test("run")
+	test("flys")
+	test("undoing")
+	if !d.IsWord("paris") {
+		t.Error("expected lowercase form of Paris to be a word")
+	}
+}
+
+func TestOpenDir(t *testing.T) {
+	list, err := OpenDir("testdata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 {
+		t.Fatalf("expected 1 dictionary, got %d", len(list))
+	}
+}
@@ -2,19 +2,100 @@ package stardict
 
 import (
 	"encoding/binary"
+	"fmt"
+	"log/slog"
 	"os"
+	"strings"
 )
 
 type IdxEntry struct {
-	terms  []string
+	terms []string
+	// keys are normalized terms (same order as terms),
+	// only set if index is built with a Normalizer
+	keys   []string
 	offset uint64
 	size   uint64
 }
 
+// matchTerms returns the terms to be used for matching a normalized query
+func (e *IdxEntry) matchTerms() []string {
+	if e.keys != nil {
+		return e.keys
+	}
+	return e.terms
+}
+
+// hasKey returns true if one of terms matches the normalized query exactly
+func (e *IdxEntry) hasKey(query string) bool {
+	if e.keys != nil {
+		for _, key := range e.keys {
+			if key == query {
+				return true
+			}
+		}
+		return false
+	}
+	for _, term := range e.terms {
+		if strings.ToLower(term) == query {
+			return true
+		}
+	}
+	return false
+}
+
 // Idx implements an in-memory index for a dictionary
 type Idx struct {
 	byWordPrefix map[rune][]int
 	entries      []*IdxEntry
+	normalizer   *Normalizer
+	errorHandler func(error)
+}
+
+// memorySize returns an estimate of memory used by idx in bytes
+func (idx *Idx) memorySize() int64 {
+	const (
+		pointerSize  = 8
+		stringHeader = 16
+		sliceHeader  = 24
+		entrySize    = 2*sliceHeader + 16
+		// approximate cost of a map item, without the slice data
+		mapItemSize = 4 + sliceHeader + 16
+	)
+	size := int64(len(idx.entries)) * (pointerSize + entrySize)
+	for _, entry := range idx.entries {
+		for _, term := range entry.terms {
+			size += stringHeader + int64(len(term))
+		}
+		for _, key := range entry.keys {
+			size += stringHeader + int64(len(key))
+		}
+	}
+	for _, indexes := range idx.byWordPrefix {
+		size += mapItemSize + int64(cap(indexes))*8
+	}
+	return size
+}
+
+// indexOptions are the settings used for building Idx
+type indexOptions struct {
+	normalizer   *Normalizer
+	errorHandler func(error)
+	logger       *slog.Logger
+}
+
+func (opts indexOptions) log() *slog.Logger {
+	if opts.logger != nil {
+		return opts.logger
+	}
+	return slog.Default()
+}
+
+func (idx *Idx) handleError(err error) {
+	if idx.errorHandler != nil {
+		idx.errorHandler(err)
+		return
+	}
+	ErrorHandler(err)
 }
 
 // newIdx initializes idx struct
@@ -41,6 +122,17 @@ func (idx *Idx) Add(term string, offset uint64, size uint64) int {
 	return termIndex
 }
 
+// addKey adds term of entry to wordPrefixMap, normalizing it if needed
+func (idx *Idx) addKey(wordPrefixMap WordPrefixMap, entry *IdxEntry, term string, termIndex int) {
+	if idx.normalizer == nil {
+		wordPrefixMap.addKey(strings.ToLower(term), term, termIndex, idx.handleError)
+		return
+	}
+	key := idx.normalizer.Normalize(term)
+	entry.keys = append(entry.keys, key)
+	wordPrefixMap.addKey(key, term, termIndex, idx.handleError)
+}
+
 type t_state uint8
 
 const (
@@ -53,6 +145,10 @@ const MAX_TERM_LENGTH = 1024
 
 // ReadIndex reads dictionary index into a memory and returns in-memory index structure
 func ReadIndex(filename string, synPath string, info *Info) (*Idx, error) {
+	return readIndex(filename, synPath, info, indexOptions{})
+}
+
+func readIndex(filename string, synPath string, info *Info, opts indexOptions) (*Idx, error) {
 	data, err := os.ReadFile(filename)
 	// unable to read index
 	if err != nil {
@@ -64,19 +160,33 @@ func ReadIndex(filename string, synPath string, info *Info) (*Idx, error) {
 		return nil, err
 	}
 	idx := newIdx(entryCount)
+	idx.normalizer = opts.normalizer
+	idx.errorHandler = opts.errorHandler
 
 	wordPrefixMap := WordPrefixMap{}
 
 	var buf [MAX_TERM_LENGTH]byte // temporary buffer
-	var bufPos uint8
+	var bufPos int
 	state := termState
 
 	var term string
 	var dataOffset uint64
+	// recordPos is the position of current record, used for errors
+	var recordPos int
 
-	maxIntBytes := uint8(info.MaxIdxBytes())
+	maxIntBytes := info.MaxIdxBytes()
 
-	for _, b := range data {
+	for pos, b := range data {
+		if state == termState && bufPos == 0 {
+			recordPos = pos
+		}
+		if bufPos == MAX_TERM_LENGTH {
+			return nil, &FormatError{
+				File:   filename,
+				Offset: int64(recordPos),
+				Err:    fmt.Errorf("%w: term longer than %d bytes", ErrCorruptIdx, MAX_TERM_LENGTH),
+			}
+		}
 		buf[bufPos] = b
 		if state == termState {
 			if b > 0 {
@@ -108,7 +218,14 @@ func ReadIndex(filename string, synPath string, info *Info) (*Idx, error) {
 		bufPos = 0
 		state = termState
 		termIndex := idx.Add(term, dataOffset, num)
-		wordPrefixMap.Add(term, termIndex)
+		idx.addKey(wordPrefixMap, idx.entries[termIndex], term, termIndex)
+	}
+	if state != termState || bufPos > 0 {
+		return nil, &FormatError{
+			File:   filename,
+			Offset: int64(recordPos),
+			Err:    fmt.Errorf("%w: truncated record", ErrCorruptIdx),
+		}
 	}
 	if synPath != "" {
 		err := readSyn(idx, synPath, wordPrefixMap)
@@ -116,6 +233,13 @@ func ReadIndex(filename string, synPath string, info *Info) (*Idx, error) {
 			return nil, err
 		}
 	}
+	idx.setWordPrefixMap(wordPrefixMap)
+
+	return idx, err
+}
+
+// setWordPrefixMap sets byWordPrefix from the collected wordPrefixMap
+func (idx *Idx) setWordPrefixMap(wordPrefixMap WordPrefixMap) {
 	for prefix, indexMap := range wordPrefixMap {
 		indexList := make([]int, 0, len(indexMap))
 		for i := range indexMap {
@@ -123,6 +247,4 @@ func ReadIndex(filename string, synPath string, info *Info) (*Idx, error) {
 		}
 		idx.byWordPrefix[prefix] = indexList
 	}
-
-	return idx, err
 }
@@ -6,16 +6,33 @@ import (
 	"unicode/utf8"
 )
 
+// WordPrefixMap maps first letter of each word of normalized terms
+// to indexes of terms
 type WordPrefixMap map[rune]map[int]struct{}
 
+// Add adds words of term, lowercased.
+//
+// Deprecated: use AddNormalized with the Normalizer of dictionary,
+// so keys match the ones of dictionary.
 func (wpm WordPrefixMap) Add(term string, termIndex int) {
-	for _, word := range strings.Split(strings.ToLower(term), " ") {
+	wpm.AddNormalized(term, termIndex, nil)
+}
+
+// AddNormalized adds words of term normalized by normalizer,
+// nil normalizer only lowercases term
+func (wpm WordPrefixMap) AddNormalized(term string, termIndex int, normalizer *Normalizer) {
+	wpm.addKey(normalizer.Normalize(term), term, termIndex, ErrorHandler)
+}
+
+// addKey adds words of an already normalized term
+func (wpm WordPrefixMap) addKey(key string, term string, termIndex int, handleError func(error)) {
+	for _, word := range strings.Split(key, " ") {
 		if word == "" {
 			continue
 		}
 		prefix, _ := utf8.DecodeRuneInString(word)
 		if prefix == utf8.RuneError {
-			ErrorHandler(fmt.Errorf(
+			handleError(fmt.Errorf(
 				"RuneError from DecodeRuneInString for word %#v in term %#v",
 				word,
 				term,
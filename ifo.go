@@ -1,52 +1,154 @@
 package stardict
 
 import (
-	"bufio"
-	"errors"
-	"io"
+	"bytes"
+	"fmt"
 	"os"
+	"slices"
+	"sort"
 	"strconv"
 	"strings"
 )
 
 const (
-	I_bookname    = "bookname"
-	I_wordcount   = "wordcount"
-	I_description = "description"
-	I_idxfilesize = "idxfilesize"
+	I_bookname     = "bookname"
+	I_wordcount    = "wordcount"
+	I_synwordcount = "synwordcount"
+	I_description  = "description"
+	I_idxfilesize  = "idxfilesize"
+	// I_tdxfilesize is the size of .tdx file of tree dictionaries
+	I_tdxfilesize = "tdxfilesize"
 
 	I_sametypesequence = "sametypesequence"
 	I_idxoffsetbits    = "idxoffsetbits"
+
+	I_author   = "author"
+	I_email    = "email"
+	I_website  = "website"
+	I_date     = "date"
+	I_dicttype = "dicttype"
+
+	// I_lang is the language of dictionary, either a single BCP 47 tag
+	// or "source-target" like "en-de"
+	I_lang = "lang"
+	// I_targetlang is the language of definitions
+	I_targetlang = "targetlang"
 )
 
-// Info contains dictionary options
+// ifoKeyOrder is the order of known options in new .ifo files,
+// other options are written after them in alphabetical order
+var ifoKeyOrder = []string{
+	I_bookname,
+	I_wordcount,
+	I_synwordcount,
+	I_idxfilesize,
+	I_tdxfilesize,
+	I_idxoffsetbits,
+	I_author,
+	I_email,
+	I_website,
+	I_description,
+	I_date,
+	I_sametypesequence,
+	I_dicttype,
+	I_lang,
+	I_targetlang,
+}
+
+// Info contains dictionary options. Known options have typed fields,
+// Options has all options including unknown ones. Set and Get keep both
+// in sync, and WriteInfo writes typed fields of known options.
 type Info struct {
-	Options  map[string]string
-	Version  string
-	Is64     bool
-	disabled bool
+	// Options are values of all options by key as written in file,
+	// so description has "<br>" instead of newlines (see Desc)
+	Options map[string]string
+	Version string
+	// Is64 is true for idxoffsetbits=64
+	Is64 bool
+
+	BookName     string
+	WordCount    int
+	SynWordCount int
+	IdxFileSize  uint64
+	TdxFileSize  uint64
+	Author       string
+	Email        string
+	Website      string
+	// Desc is the description, with newlines instead of "<br>"
+	// (Description is the method of common.Dictionary)
+	Desc             string
+	Date             string
+	SameTypeSequence string
+	// DictType is the type of special dictionaries, like "wordnet"
+	DictType   string
+	Lang       string
+	TargetLang string
+
+	// format is the layout of the file read by ReadInfo
+	format *infoFormat
+}
+
+// infoFormat is the layout of .ifo file, to write unmodified lines
+// byte by byte
+type infoFormat struct {
+	bom     bool
+	crlf    bool
+	noFinal bool
+	magic   infoRawLine
+	version infoRawLine
+	// lines are the lines after version, key is empty for blank lines
+	lines []infoRawLine
+}
+
+// infoRawLine is a line of .ifo file, newline is its line ending
+// (or the first line ending for the last line if it has none)
+type infoRawLine struct {
+	key     string
+	value   string
+	newline string
+}
+
+// lineEnding returns the line ending of line, or fallback if it has none
+func lineEnding(line string, fallback string) string {
+	switch {
+	case strings.HasSuffix(line, "\r\n"):
+		return "\r\n"
+	case strings.HasSuffix(line, "\n"):
+		return "\n"
+	}
+	return fallback
+}
+
+// newInfo returns Info of a new .ifo file with the given options
+func newInfo(options map[string]string) *Info {
+	info := &Info{Version: "3.0.0"}
+	for key, value := range options {
+		info.Set(key, value)
+	}
+	return info
 }
 
 func (info Info) DictName() string {
-	return info.Options[I_bookname]
+	return info.BookName
 }
 
 // EntryCount returns number of words in the dictionary
 func (info Info) EntryCount() (int, error) {
-	num, err := strconv.ParseUint(info.Options[I_wordcount], 10, 64)
-	if err != nil {
-		return 0, err
+	if info.WordCount == 0 {
+		_, err := strconv.ParseUint(info.Options[I_wordcount], 10, 64)
+		if err != nil {
+			return 0, err
+		}
 	}
-	return int(num), nil
+	return info.WordCount, nil
 }
 
 func (info Info) Description() string {
-	return info.Options[I_description]
+	return info.Desc
 }
 
 func (info Info) IndexFileSize() uint64 {
-	num, _ := strconv.ParseUint(info.Options[I_idxfilesize], 10, 64)
-	return num
+	return info.IdxFileSize
 }
 
 func (info Info) MaxIdxBytes() int {
@@ -56,88 +158,299 @@ func (info Info) MaxIdxBytes() int {
 	return 4
 }
 
-func decodeOption(str string) (key string, value string, err error) {
-	a := strings.Split(str, "=")
+// Set sets an option, and its typed field for known options.
+// Invalid numbers are kept in Options, while the typed field is zero.
+func (info *Info) Set(key string, value string) {
+	if info.Options == nil {
+		info.Options = map[string]string{}
+	}
+	info.Options[key] = value
+	parseInt := func() int {
+		num, _ := strconv.ParseUint(value, 10, 63)
+		return int(num)
+	}
+	switch key {
+	case I_bookname:
+		info.BookName = value
+	case I_wordcount:
+		info.WordCount = parseInt()
+	case I_synwordcount:
+		info.SynWordCount = parseInt()
+	case I_idxfilesize:
+		info.IdxFileSize, _ = strconv.ParseUint(value, 10, 64)
+	case I_tdxfilesize:
+		info.TdxFileSize, _ = strconv.ParseUint(value, 10, 64)
+	case I_idxoffsetbits:
+		info.Is64 = value == "64"
+	case I_author:
+		info.Author = value
+	case I_email:
+		info.Email = value
+	case I_website:
+		info.Website = value
+	case I_description:
+		info.Desc = value
+		info.Options[key] = encodeOptionValue(value)
+	case I_date:
+		info.Date = value
+	case I_sametypesequence:
+		info.SameTypeSequence = value
+	case I_dicttype:
+		info.DictType = value
+	case I_lang:
+		info.Lang = value
+	case I_targetlang:
+		info.TargetLang = value
+	}
+}
 
-	if len(a) < 2 {
-		return "", "", errors.New("Invalid file format: " + str)
+// Get returns the value of an option, from its typed field for known
+// options, or empty string if it is not set
+func (info *Info) Get(key string) string {
+	switch key {
+	case I_bookname:
+		return info.BookName
+	case I_wordcount:
+		return info.numberOption(key, uint64(info.WordCount))
+	case I_synwordcount:
+		return info.numberOption(key, uint64(info.SynWordCount))
+	case I_idxfilesize:
+		return info.numberOption(key, info.IdxFileSize)
+	case I_tdxfilesize:
+		return info.numberOption(key, info.TdxFileSize)
+	case I_idxoffsetbits:
+		if info.Is64 {
+			return "64"
+		}
+		if value := info.Options[key]; value != "64" {
+			return value
+		}
+		return ""
+	case I_author:
+		return info.Author
+	case I_email:
+		return info.Email
+	case I_website:
+		return info.Website
+	case I_description:
+		return info.Desc
+	case I_date:
+		return info.Date
+	case I_sametypesequence:
+		return info.SameTypeSequence
+	case I_dicttype:
+		return info.DictType
+	case I_lang:
+		return info.Lang
+	case I_targetlang:
+		return info.TargetLang
 	}
+	return info.Options[key]
+}
 
-	return a[0], a[1], nil
+// numberOption returns the value of a numeric option, keeping its text
+// in Options if it is the same number (or invalid, for zero)
+func (info *Info) numberOption(key string, num uint64) string {
+	value := info.Options[key]
+	parsed, err := strconv.ParseUint(value, 10, 64)
+	if err == nil && parsed == num || err != nil && num == 0 {
+		return value
+	}
+	return strconv.FormatUint(num, 10)
 }
 
-// ReadInfo reads ifo file and collects dictionary options
-func ReadInfo(filename string) (info *Info, err error) {
-	reader, err := os.Open(filename)
-	if err != nil {
-		return
+// decodeOptionValue decodes "<br>" in description
+func decodeOptionValue(key string, value string) string {
+	if key == I_description {
+		return strings.ReplaceAll(value, "<br>", "\n")
 	}
+	return value
+}
 
-	defer closeCloser(reader)
+// encodeOptionValue writes newlines as "<br>", since each option is a line
+func encodeOptionValue(value string) string {
+	value = strings.ReplaceAll(value, "\r\n", "\n")
+	return strings.ReplaceAll(value, "\n", "<br>")
+}
 
-	r := bufio.NewReader(reader)
+func decodeOption(str string) (key string, value string, err error) {
+	key, value, ok := strings.Cut(str, "=")
+	if !ok {
+		return "", "", fmt.Errorf("%w: %#v", ErrInvalidFormat, str)
+	}
+	return key, value, nil
+}
 
-	_, err = r.ReadString('\n')
+// ReadInfo reads ifo file and collects dictionary options.
+// Files with BOM, CRLF line endings or no final newline are accepted.
+func ReadInfo(filename string) (*Info, error) {
+	data, err := os.ReadFile(filename)
 	if err != nil {
-		return
+		return nil, err
 	}
+	return parseInfo(data, filename)
+}
 
-	version, err := r.ReadString('\n')
-	if err != nil {
-		return
+func parseInfo(data []byte, filename string) (*Info, error) {
+	format := &infoFormat{}
+	pos := 0
+	if bytes.HasPrefix(data, []byte("\ufeff")) {
+		format.bom = true
+		pos = 3
+	}
+	text := string(data[pos:])
+	if text != "" && !strings.HasSuffix(text, "\n") {
+		format.noFinal = true
+	}
+	lines := strings.SplitAfter(text, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
 	}
 
-	key, value, err := decodeOption(version[:len(version)-1])
-	if err != nil {
-		return
+	formatError := func(offset int, err error) error {
+		return &FormatError{File: filename, Offset: int64(offset), Err: err}
 	}
 
-	if key != "version" {
-		err = errors.New("version missing (should be on second line)")
-		return
+	if len(lines) == 0 {
+		return nil, formatError(pos, ErrMissingVersion)
+	}
+	format.crlf = strings.HasSuffix(lines[0], "\r\n")
+	defaultNewline := lineEnding(lines[0], "\n")
+	format.magic = infoRawLine{
+		value:   strings.TrimRight(lines[0], "\r\n"),
+		newline: defaultNewline,
+	}
+	pos += len(lines[0])
+	if len(lines) == 1 {
+		return nil, formatError(pos, ErrMissingVersion)
 	}
 
+	key, value, err := decodeOption(strings.TrimRight(lines[1], "\r\n"))
+	if err != nil {
+		return nil, formatError(pos, err)
+	}
+	if key != "version" {
+		return nil, formatError(pos, ErrMissingVersion)
+	}
 	if value != "2.4.2" && value != "3.0.0" {
-		err = errors.New("stardict version should be either 2.4.2 or 3.0.0")
-		return
+		return nil, formatError(pos, fmt.Errorf("%w: %#v", ErrUnsupportedVersion, value))
 	}
+	format.version = infoRawLine{
+		key:     key,
+		value:   value,
+		newline: lineEnding(lines[1], defaultNewline),
+	}
+	pos += len(lines[1])
 
-	info = &Info{}
-
-	info.Version = value
-
-	info.Options = make(map[string]string)
-
-	for {
-		option, err := r.ReadString('\n')
-
-		if err != nil && err != io.EOF {
-			return info, err
-		}
-
-		if err == io.EOF && len(option) == 0 {
-			break
+	info := &Info{
+		Version: value,
+		Options: map[string]string{},
+		format:  format,
+	}
+	for _, line := range lines[2:] {
+		option := strings.TrimRight(line, "\r\n")
+		newline := lineEnding(line, defaultNewline)
+		if option == "" {
+			format.lines = append(format.lines, infoRawLine{newline: newline})
+			pos += len(line)
+			continue
 		}
-
-		key, value, err = decodeOption(option[:len(option)-1])
+		key, value, err := decodeOption(option)
 		if err != nil {
-			return info, err
+			return info, formatError(pos, err)
 		}
+		pos += len(line)
+		format.lines = append(format.lines, infoRawLine{key: key, value: value, newline: newline})
+		info.Set(key, decodeOptionValue(key, value))
+	}
+	return info, nil
+}
 
-		info.Options[key] = value
+// Encode returns the content of .ifo file. Lines of options that are not
+// modified since ReadInfo are kept as they were (including repeated
+// options and line endings), a modified option replaces its first line
+// and new options are appended.
+func (info *Info) Encode() []byte {
+	format := info.format
+	if format == nil {
+		format = &infoFormat{}
+	}
+	newline := "\n"
+	if format.crlf {
+		newline = "\r\n"
+	}
+	var sb strings.Builder
+	if format.bom {
+		sb.WriteString("\ufeff")
+	}
+	if format.magic.value != "" {
+		sb.WriteString(format.magic.value + format.magic.newline)
+	} else {
+		sb.WriteString(ifoMagic + newline)
+	}
+	version := info.Version
+	if version == "" {
+		version = "3.0.0"
+	}
+	if format.version.value == version {
+		sb.WriteString("version=" + version + format.version.newline)
+	} else {
+		sb.WriteString("version=" + version + newline)
+	}
 
-		if err == io.EOF {
-			break
+	// the last line of a repeated option is its value in Options
+	lastValue := map[string]string{}
+	for _, line := range format.lines {
+		if line.key != "" {
+			lastValue[line.key] = line.value
+		}
+	}
+	done := map[string]bool{}
+	for _, line := range format.lines {
+		if line.key == "" {
+			sb.WriteString(line.newline)
+			continue
+		}
+		value := info.Get(line.key)
+		if decodeOptionValue(line.key, lastValue[line.key]) == value {
+			done[line.key] = true
+			sb.WriteString(line.key + "=" + line.value + line.newline)
+			continue
+		}
+		if done[line.key] {
+			continue
+		}
+		done[line.key] = true
+		if value != "" {
+			sb.WriteString(line.key + "=" + encodeOptionValue(value) + line.newline)
 		}
 	}
 
-	if bits, ok := info.Options[I_idxoffsetbits]; ok {
-		if bits == "64" {
-			info.Is64 = true
+	keys := make([]string, 0, len(info.Options))
+	for key := range info.Options {
+		if !slices.Contains(ifoKeyOrder, key) {
+			keys = append(keys, key)
 		}
-	} else {
-		info.Is64 = false
 	}
+	sort.Strings(keys)
+	for _, key := range append(slices.Clone(ifoKeyOrder), keys...) {
+		if done[key] {
+			continue
+		}
+		done[key] = true
+		if value := info.Get(key); value != "" {
+			sb.WriteString(key + "=" + encodeOptionValue(value) + newline)
+		}
+	}
+
+	out := sb.String()
+	if format.noFinal {
+		out = strings.TrimSuffix(out, lineEnding(out, ""))
+	}
+	return []byte(out)
+}
 
-	return
+// WriteInfo writes info into .ifo file, see Info.Encode
+func WriteInfo(filename string, info *Info) error {
+	return writeFileAtomic(filename, info.Encode())
 }
@@ -0,0 +1,84 @@
+package stardict
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestInfoRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	ifoPath := filepath.Join(dir, "test.ifo")
+	inputs := []string{
+		"StarDict's dict ifo file\nversion=3.0.0\nbookname=Test\nwordcount=3\nidxfilesize=49\n",
+		"\ufeffStarDict's dict ifo file\r\nversion=2.4.2\r\nbookname=Test\r\nwordcount=3\r\n\r\nidxfilesize=49",
+		// repeated options and mixed line endings
+		"StarDict's dict ifo file\r\nversion=3.0.0\nbookname=Old\r\nwordcount=3\nbookname=Test\n\r\nx-custom=1\r\n",
+		"StarDict's dict ifo file\nversion=3.0.0\nwebsite=https://example.com/?a=1&b=2\nwordcount=003\nx-custom=1\nbookname=Test\ndescription=a<br>b\n",
+	}
+	for _, input := range inputs {
+		_ = os.WriteFile(ifoPath, []byte(input), 0o644)
+		info, err := ReadInfo(ifoPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.BookName != "Test" || info.WordCount != 3 {
+			t.Fatalf("unexpected info: %+v", info)
+		}
+		if err := WriteInfo(ifoPath, info); err != nil {
+			t.Fatal(err)
+		}
+		if output, _ := os.ReadFile(ifoPath); string(output) != input {
+			t.Fatalf("expected %#v, got %#v", input, string(output))
+		}
+	}
+
+	info, err := ReadInfo(ifoPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Website != "https://example.com/?a=1&b=2" || info.Desc != "a\nb" || info.Options["x-custom"] != "1" {
+		t.Fatalf("unexpected info: %+v", info)
+	}
+	if info.Options[I_description] != "a<br>b" {
+		t.Fatalf("unexpected info: %+v", info)
+	}
+	info.Desc = "c\nd"
+	info.WordCount = 4
+	info.Set(I_author, "Someone")
+	expected := "StarDict's dict ifo file\nversion=3.0.0\nwebsite=https://example.com/?a=1&b=2\nwordcount=4\nx-custom=1\nbookname=Test\ndescription=c<br>d\nauthor=Someone\n"
+	if output := string(info.Encode()); output != expected {
+		t.Fatalf("expected %#v, got %#v", expected, output)
+	}
+}
+
+func TestInfoEncodeRepeated(t *testing.T) {
+	input := "StarDict's dict ifo file\r\nversion=3.0.0\nbookname=Old\r\nwordcount=3\nbookname=Test\r\nx-custom=1"
+	info, err := parseInfo([]byte(input), "test.ifo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.BookName != "Test" {
+		t.Fatalf("unexpected bookname %#v", info.BookName)
+	}
+	// a modified option replaces its first line, other lines keep endings
+	info.Set(I_bookname, "New")
+	info.Set(I_author, "Someone")
+	expected := "StarDict's dict ifo file\r\nversion=3.0.0\nbookname=New\r\nwordcount=3\nx-custom=1\r\nauthor=Someone"
+	if output := string(info.Encode()); output != expected {
+		t.Fatalf("expected %#v, got %#v", expected, output)
+	}
+}
+
+func TestNewInfo(t *testing.T) {
+	info := newInfo(map[string]string{
+		"x-custom":         "1",
+		I_sametypesequence: "m",
+		I_wordcount:        "0",
+		I_bookname:         "New",
+	})
+	expected := "StarDict's dict ifo file\nversion=3.0.0\nbookname=New\nwordcount=0\nsametypesequence=m\nx-custom=1\n"
+	if output := string(info.Encode()); output != expected {
+		t.Fatalf("expected %#v, got %#v", expected, output)
+	}
+}
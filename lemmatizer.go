@@ -0,0 +1,130 @@
+package stardict
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	common "codeberg.org/ilius/go-dict-commons"
+	"github.com/ilius/go-stardict/v2/hunspell"
+)
+
+// stemScore is the score of results found by a stem of the query,
+// lower than 200 which is given to direct hits
+const stemScore = uint8(180)
+
+// Lemmatizer returns candidate base forms (stems) of a word,
+// which SearchExact looks up when the dictionary has one set,
+// for example to find "running" under "run"
+type Lemmatizer interface {
+	Stems(word string) []string
+}
+
+// DefaultLemmatizer is used by dictionaries created with NewDictionary
+var DefaultLemmatizer Lemmatizer
+
+// StemResult is a search result that was found by looking up a stem
+// of the query instead of the query itself
+type StemResult struct {
+	*common.SearchResultLow
+	Stem string
+}
+
+// lemmatizerList combines stems of multiple lemmatizers
+type lemmatizerList []Lemmatizer
+
+func (list lemmatizerList) Stems(word string) []string {
+	var stems []string
+	seen := map[string]bool{}
+	for _, lem := range list {
+		for _, stem := range lem.Stems(word) {
+			if seen[stem] {
+				continue
+			}
+			seen[stem] = true
+			stems = append(stems, stem)
+		}
+	}
+	return stems
+}
+
+// LoadHunspellDir returns a Lemmatizer that uses all Hunspell
+// .aff / .dic pairs in the given directory
+func LoadHunspellDir(dir string) (Lemmatizer, error) {
+	dics, err := hunspell.OpenDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	if len(dics) == 0 {
+		return nil, fmt.Errorf("no hunspell dictionary found in %#v", dir)
+	}
+	list := make(lemmatizerList, len(dics))
+	for i, dic := range dics {
+		list[i] = dic
+	}
+	return list, nil
+}
+
+// SetLemmatizer sets the Lemmatizer used by SearchExact, nil disables it
+func (d *dictionaryImp) SetLemmatizer(lemmatizer Lemmatizer) {
+	d.lemmatizer = lemmatizer
+}
+
+// SearchStems looks up the stems of query (but not query itself)
+// and returns the results annotated with the stem that matched
+func (d *dictionaryImp) SearchStems(
+	query string,
+	workerCount int,
+	timeout time.Duration,
+) []*StemResult {
+	if d.lemmatizer == nil {
+		return nil
+	}
+	idx, release, err := d.acquire()
+	if err != nil {
+		d.handleError(err)
+		return nil
+	}
+	defer release()
+	return d.searchStems(idx, query, d.normalizeQuery(query), workerCount, timeout)
+}
+
+// searchStems looks up stems of both raw query and its normalized key,
+// since normalization (like case folding) may hide forms the lemmatizer knows
+func (d *dictionaryImp) searchStems(
+	idx *Idx,
+	query string,
+	key string,
+	workerCount int,
+	timeout time.Duration,
+) []*StemResult {
+	query = strings.TrimSpace(query)
+	stems := d.lemmatizer.Stems(query)
+	if key != query {
+		for _, stem := range d.lemmatizer.Stems(key) {
+			if !slices.Contains(stems, stem) {
+				stems = append(stems, stem)
+			}
+		}
+	}
+	var results []*StemResult
+	found := map[uint64]bool{}
+	for _, stem := range stems {
+		stemKey := d.normalizer.Normalize(stem)
+		if stemKey == key {
+			continue
+		}
+		for _, res := range d.searchExact(idx, stemKey, stemScore, workerCount, timeout) {
+			if found[res.F_EntryIndex] {
+				continue
+			}
+			found[res.F_EntryIndex] = true
+			results = append(results, &StemResult{
+				SearchResultLow: res,
+				Stem:            stem,
+			})
+		}
+	}
+	return results
+}
@@ -0,0 +1,63 @@
+package stardict
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSearchExactStems(t *testing.T) {
+	lem, err := LoadHunspellDir("hunspell/testdata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := openTestDict(t, []testEntry{
+		{terms: []string{"run"}, defi: "to move fast"},
+		{terms: []string{"running"}, defi: "the act of moving fast"},
+	})
+	d.SetLemmatizer(lem)
+	if err := d.Load(); err != nil {
+		t.Fatal(err)
+	}
+	results := d.SearchExact("running", 1, time.Second)
+	if len(results) != 2 {
+		t.Fatalf("expected 2 results, got %d", len(results))
+	}
+	if results[0].Terms()[0] != "running" || results[0].Score() != 200 {
+		t.Errorf("unexpected direct hit: %v %v", results[0].Terms(), results[0].Score())
+	}
+	if results[1].Terms()[0] != "run" || results[1].Score() != stemScore {
+		t.Errorf("unexpected stem hit: %v %v", results[1].Terms(), results[1].Score())
+	}
+	stemResults := d.SearchStems("Running", 1, time.Second)
+	if len(stemResults) != 1 || stemResults[0].Stem != "run" {
+		t.Errorf("unexpected stem results: %v", stemResults)
+	}
+	stemResults = d.SearchExactStems("running", 1, time.Second)
+	if len(stemResults) != 2 || stemResults[0].Stem != "" || stemResults[1].Stem != "run" {
+		t.Errorf("unexpected exact stem results: %v", stemResults)
+	}
+}
+
+// mapLemmatizer only knows the exact (not normalized) forms in the map
+type mapLemmatizer map[string][]string
+
+func (lem mapLemmatizer) Stems(word string) []string {
+	return lem[word]
+}
+
+func TestSearchExactStemsRawQuery(t *testing.T) {
+	d := openTestDict(t, []testEntry{
+		{terms: []string{"mouse"}, defi: "a small rodent"},
+	})
+	d.SetLemmatizer(mapLemmatizer{"Mice": {"mouse"}})
+	if err := d.Load(); err != nil {
+		t.Fatal(err)
+	}
+	results := d.SearchExactStems(" Mice ", 1, time.Second)
+	if len(results) != 1 || results[0].Stem != "mouse" || results[0].Terms()[0] != "mouse" {
+		t.Errorf("unexpected results: %v", results)
+	}
+	if len(d.SearchExact("mice", 1, time.Second)) != 0 {
+		t.Error("unexpected results for lowercase query")
+	}
+}
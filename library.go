@@ -0,0 +1,308 @@
+package stardict
+
+import (
+	"fmt"
+	"regexp"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+
+	common "codeberg.org/ilius/go-dict-commons"
+)
+
+// SearchMode selects the search method used by Library.Search
+type SearchMode uint8
+
+const (
+	SearchModeFuzzy SearchMode = iota
+	SearchModeStartWith
+	SearchModeExact
+	SearchModeWordMatch
+	SearchModeRegex
+	SearchModeGlob
+)
+
+func (m SearchMode) String() string {
+	switch m {
+	case SearchModeFuzzy:
+		return "fuzzy"
+	case SearchModeStartWith:
+		return "startwith"
+	case SearchModeExact:
+		return "exact"
+	case SearchModeWordMatch:
+		return "wordmatch"
+	case SearchModeRegex:
+		return "regex"
+	case SearchModeGlob:
+		return "glob"
+	}
+	return fmt.Sprintf("SearchMode(%d)", m)
+}
+
+// SearchOptions are the options of Library.Search
+type SearchOptions struct {
+	Mode SearchMode
+
+	// WorkerCount is the total number of workers shared by all
+	// dictionaries, 0 means runtime.NumCPU()
+	WorkerCount int
+
+	// Timeout is the deadline of the whole search, 0 means no deadline.
+	// Dictionaries that are not searched before the deadline are skipped.
+	Timeout time.Duration
+
+	// Limit is the maximum number of results (or groups), 0 means no limit
+	Limit int
+
+	// GroupByTerm groups results with identical headwords from
+	// different dictionaries, see LibrarySearchResult.Groups
+	GroupByTerm bool
+}
+
+// LibraryResult is a search result with its dictionary
+type LibraryResult struct {
+	*common.SearchResultLow
+	Dictionary common.Dictionary
+}
+
+// ResultGroup is a list of results with identical headword
+// from different dictionaries, sorted like LibrarySearchResult.Results
+type ResultGroup struct {
+	Term    string
+	Score   uint8
+	Results []*LibraryResult
+}
+
+// DictSearchTiming is the search time and status of one dictionary
+type DictSearchTiming struct {
+	DictName    string
+	Duration    time.Duration
+	ResultCount int
+	// Skipped is true if the deadline passed before searching
+	Skipped bool
+	Err     error
+}
+
+// LibrarySearchResult is the result of Library.Search
+type LibrarySearchResult struct {
+	// Results are sorted by score, then by dictionary order
+	Results []*LibraryResult
+	// Groups is only set with SearchOptions.GroupByTerm
+	Groups   []*ResultGroup
+	Timings  []*DictSearchTiming
+	Duration time.Duration
+}
+
+// Library runs searches over a list of dictionaries
+type Library struct {
+	dictionaries func() []common.Dictionary
+	order        map[string]int
+}
+
+// NewLibrary returns a Library of the given dictionaries. order is the
+// same map given to Open: dictionaries with negative order are skipped,
+// and lower order comes first among results with equal score.
+func NewLibrary(dicList []common.Dictionary, order map[string]int) *Library {
+	return &Library{
+		dictionaries: func() []common.Dictionary { return dicList },
+		order:        order,
+	}
+}
+
+// Library returns a Library that always searches the current
+// dictionaries of watcher
+func (w *Watcher) Library() *Library {
+	return &Library{
+		dictionaries: w.Dictionaries,
+		order:        w.opts.Order,
+	}
+}
+
+// Dictionaries returns the dictionaries of library
+func (lib *Library) Dictionaries() []common.Dictionary {
+	return lib.dictionaries()
+}
+
+// rankedDictionaries returns enabled dictionaries sorted by order,
+// dictionaries missing from order keep their position after ordered ones
+func (lib *Library) rankedDictionaries() []common.Dictionary {
+	var dicList []common.Dictionary
+	for _, dic := range lib.dictionaries() {
+		if dic.Disabled() || lib.order[dic.DictName()] < 0 {
+			continue
+		}
+		dicList = append(dicList, dic)
+	}
+	sort.SliceStable(dicList, func(i, j int) bool {
+		oi, iok := lib.order[dicList[i].DictName()]
+		oj, jok := lib.order[dicList[j].DictName()]
+		if iok != jok {
+			return iok
+		}
+		return oi < oj
+	})
+	return dicList
+}
+
+// checkQuery returns the error of invalid regex or glob pattern,
+// instead of getting the same error from every dictionary
+func checkQuery(query string, mode SearchMode) error {
+	switch mode {
+	case SearchModeRegex:
+		_, err := regexp.Compile("^(?:" + query + ")$")
+		return err
+	case SearchModeGlob:
+		_, err := compileGlob(query, GlobStandard)
+		return err
+	}
+	return nil
+}
+
+func searchDictionary(
+	dic common.Dictionary,
+	query string,
+	mode SearchMode,
+	workerCount int,
+	timeout time.Duration,
+) ([]*common.SearchResultLow, error) {
+	switch mode {
+	case SearchModeFuzzy:
+		return dic.SearchFuzzy(query, workerCount, timeout), nil
+	case SearchModeStartWith:
+		return dic.SearchStartWith(query, workerCount, timeout), nil
+	case SearchModeExact:
+		return dic.SearchExact(query, workerCount, timeout), nil
+	case SearchModeWordMatch:
+		return dic.SearchWordMatch(query, workerCount, timeout), nil
+	case SearchModeRegex:
+		return dic.SearchRegex(query, workerCount, timeout)
+	case SearchModeGlob:
+		return dic.SearchGlob(query, workerCount, timeout)
+	}
+	return nil, fmt.Errorf("invalid search mode %v", mode)
+}
+
+// Search runs query on all enabled dictionaries in parallel, and merges
+// the results. An error is returned only for invalid queries, errors of
+// each dictionary are in Timings.
+func (lib *Library) Search(query string, opts *SearchOptions) (*LibrarySearchResult, error) {
+	if opts == nil {
+		opts = &SearchOptions{}
+	}
+	if err := checkQuery(query, opts.Mode); err != nil {
+		return nil, err
+	}
+	t0 := time.Now()
+	var deadline time.Time
+	if opts.Timeout > 0 {
+		deadline = t0.Add(opts.Timeout)
+	}
+	dicList := lib.rankedDictionaries()
+
+	// with fewer dictionaries than workers, each dictionary gets a share
+	// of workers, otherwise each search uses one worker
+	workerCount := opts.WorkerCount
+	if workerCount <= 0 {
+		workerCount = runtime.NumCPU()
+	}
+	parallel := min(workerCount, len(dicList))
+	workersPerDict := 1
+	if parallel > 0 {
+		workersPerDict = max(1, workerCount/parallel)
+	}
+
+	resultsByDict := make([][]*common.SearchResultLow, len(dicList))
+	timings := make([]*DictSearchTiming, len(dicList))
+	jobs := make(chan int, len(dicList))
+	for i := range dicList {
+		jobs <- i
+	}
+	close(jobs)
+	var wg sync.WaitGroup
+	for w := 0; w < parallel; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				dic := dicList[i]
+				timing := &DictSearchTiming{DictName: dic.DictName()}
+				timings[i] = timing
+				timeout := opts.Timeout
+				if !deadline.IsZero() {
+					timeout = time.Until(deadline)
+					if timeout <= 0 {
+						timing.Skipped = true
+						continue
+					}
+				}
+				t1 := time.Now()
+				results, err := searchDictionary(dic, query, opts.Mode, workersPerDict, timeout)
+				timing.Duration = time.Since(t1)
+				timing.ResultCount = len(results)
+				timing.Err = err
+				resultsByDict[i] = results
+			}
+		}()
+	}
+	wg.Wait()
+
+	var merged []*LibraryResult
+	for i, results := range resultsByDict {
+		for _, res := range results {
+			merged = append(merged, &LibraryResult{
+				SearchResultLow: res,
+				Dictionary:      dicList[i],
+			})
+		}
+	}
+	// merged is in dictionary order, so a stable sort keeps it as tie-breaker
+	sort.SliceStable(merged, func(i, j int) bool {
+		return merged[i].F_Score > merged[j].F_Score
+	})
+
+	result := &LibrarySearchResult{
+		Results: merged,
+		Timings: timings,
+	}
+	if opts.GroupByTerm {
+		result.Groups = groupResults(merged)
+		if opts.Limit > 0 && len(result.Groups) > opts.Limit {
+			result.Groups = result.Groups[:opts.Limit]
+		}
+	}
+	if opts.Limit > 0 && len(result.Results) > opts.Limit {
+		result.Results = result.Results[:opts.Limit]
+	}
+	result.Duration = time.Since(t0)
+	return result, nil
+}
+
+// groupResults groups sorted results by their headword, normalized by
+// the Normalizer of their dictionary, groups are sorted by their best result
+func groupResults(results []*LibraryResult) []*ResultGroup {
+	var groups []*ResultGroup
+	byKey := map[string]*ResultGroup{}
+	for _, res := range results {
+		if len(res.F_Terms) == 0 {
+			continue
+		}
+		normalizer := DefaultNormalizer
+		if d, ok := res.Dictionary.(*dictionaryImp); ok {
+			normalizer = d.normalizer
+		}
+		key := normalizer.Normalize(res.F_Terms[0])
+		group := byKey[key]
+		if group == nil {
+			group = &ResultGroup{
+				Term:  res.F_Terms[0],
+				Score: res.F_Score,
+			}
+			byKey[key] = group
+			groups = append(groups, group)
+		}
+		group.Results = append(group.Results, res)
+	}
+	return groups
+}
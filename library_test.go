@@ -0,0 +1,91 @@
+package stardict
+
+import (
+	"testing"
+)
+
+func TestLibrarySearch(t *testing.T) {
+	dir := t.TempDir()
+	writeTestDict(t, dir, "first", testEntries)
+	writeTestDict(t, dir, "second", []testEntry{
+		{terms: []string{"Apple"}, defi: "a company"},
+		{terms: []string{"applet"}, defi: "a small application"},
+	})
+	writeTestDict(t, dir, "disabled", testEntries)
+	order := map[string]int{"second": 1, "first": 2, "disabled": -1}
+	dicList, _, err := OpenWithOptions(&OpenOptions{Dirs: []string{dir}, Order: order})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lib := NewLibrary(dicList, order)
+
+	res, err := lib.Search("apple", &SearchOptions{
+		Mode:        SearchModeExact,
+		WorkerCount: 2,
+		GroupByTerm: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Timings) != 2 {
+		t.Fatalf("expected timings of 2 dictionaries, got %d", len(res.Timings))
+	}
+	if len(res.Results) != 2 {
+		t.Fatalf("expected 2 results, got %d", len(res.Results))
+	}
+	// equal scores are sorted by order
+	if name := res.Results[0].Dictionary.DictName(); name != "second" {
+		t.Fatalf("expected first result from second, got %s", name)
+	}
+	if len(res.Groups) != 1 || len(res.Groups[0].Results) != 2 {
+		t.Fatalf("expected 1 group of 2 results, got %+v", res.Groups)
+	}
+
+	res, err = lib.Search("appl*", &SearchOptions{Mode: SearchModeGlob, Limit: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Results) != 2 {
+		t.Fatalf("expected 2 results with limit, got %d", len(res.Results))
+	}
+	for i := 1; i < len(res.Results); i++ {
+		if res.Results[i].F_Score > res.Results[i-1].F_Score {
+			t.Fatal("results are not sorted by score")
+		}
+	}
+
+	if _, err := lib.Search("(", &SearchOptions{Mode: SearchModeRegex}); err == nil {
+		t.Fatal("expected error for invalid regex")
+	}
+}
+
+func TestLibraryGroupByNormalizer(t *testing.T) {
+	dir := t.TempDir()
+	writeTestDict(t, dir, "first", []testEntry{
+		{terms: []string{"IŞIK"}, defi: "light"},
+	})
+	writeTestDict(t, dir, "second", []testEntry{
+		{terms: []string{"ışık"}, defi: "light"},
+	})
+	dicList, _, err := OpenWithOptions(&OpenOptions{
+		Dirs:       []string{dir},
+		Normalizer: &Normalizer{Language: "tr"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lib := NewLibrary(dicList, nil)
+	res, err := lib.Search("ışık", &SearchOptions{
+		Mode:        SearchModeExact,
+		GroupByTerm: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Results) != 2 {
+		t.Fatalf("expected 2 results, got %d", len(res.Results))
+	}
+	if len(res.Groups) != 1 {
+		t.Fatalf("expected 1 group, got %+v", res.Groups)
+	}
+}
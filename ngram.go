@@ -0,0 +1,92 @@
+package stardict
+
+import (
+	"sort"
+	"strings"
+)
+
+const ngramSize = 3
+
+// ngramPad marks start and end of words, so n-grams at word boundaries
+// are distinct from the ones in the middle
+const ngramPad = '\x00'
+
+// ngramIndex maps each n-gram of each word of terms to sorted indexes
+// of entries that contain it
+type ngramIndex struct {
+	postings map[string][]int32
+}
+
+// wordNgrams calls f for every n-gram of padded word, possibly repeated
+func wordNgrams(word []rune, f func(gram string)) {
+	padded := make([]rune, 0, len(word)+2)
+	padded = append(padded, ngramPad)
+	padded = append(padded, word...)
+	padded = append(padded, ngramPad)
+	for i := 0; i+ngramSize <= len(padded); i++ {
+		f(string(padded[i : i+ngramSize]))
+	}
+}
+
+func buildNgramIndex(idx *Idx) *ngramIndex {
+	postings := map[string][]int32{}
+	for entryIndex, entry := range idx.entries {
+		entryIndex32 := int32(entryIndex)
+		add := func(gram string) {
+			list := postings[gram]
+			// entries are visited in order, so checking the last item is enough
+			if len(list) > 0 && list[len(list)-1] == entryIndex32 {
+				return
+			}
+			postings[gram] = append(list, entryIndex32)
+		}
+		for termI, term := range entry.terms {
+			key := strings.ToLower(term)
+			if entry.keys != nil {
+				key = entry.keys[termI]
+			}
+			for _, word := range strings.Split(key, " ") {
+				if word == "" {
+					continue
+				}
+				wordNgrams([]rune(word), add)
+			}
+		}
+	}
+	return &ngramIndex{postings: postings}
+}
+
+// candidates returns sorted indexes of entries that share enough n-grams
+// with word to possibly get a fuzzy score above the minimum
+func (ngi *ngramIndex) candidates(word []rune) []int {
+	grams := map[string]bool{}
+	wordNgrams(word, func(gram string) {
+		grams[gram] = true
+	})
+	// q-gram lemma: every edit removes at most ngramSize n-grams of word,
+	// so a word within maxDist edits (same as FST index) shares the rest
+	minShared := max(len(grams)-ngramSize*fuzzyMaxDistance(len(word)), 1)
+	counts := map[int32]int{}
+	for gram := range grams {
+		for _, entryIndex := range ngi.postings[gram] {
+			counts[entryIndex]++
+		}
+	}
+	entryIndexes := make([]int, 0, len(counts))
+	for entryIndex, count := range counts {
+		if count >= minShared {
+			entryIndexes = append(entryIndexes, int(entryIndex))
+		}
+	}
+	sort.Ints(entryIndexes)
+	return entryIndexes
+}
+
+// fuzzyCandidates returns indexes of entries to be scored by SearchFuzzy
+// for the given main word of query, the n-gram index is built on first call
+func (d *dictionaryImp) fuzzyCandidates(idx *Idx, mainWord []rune) []int {
+	d.ngramOnce.Do(func() {
+		d.ngramIndex = buildNgramIndex(idx)
+	})
+	return d.ngramIndex.candidates(mainWord)
+}
@@ -0,0 +1,44 @@
+package stardict
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSearchFuzzyFirstLetterTypo(t *testing.T) {
+	d := openTestDict(t, []testEntry{
+		{terms: []string{"chemistry"}, defi: "-"},
+		{terms: []string{"physics"}, defi: "-"},
+		{terms: []string{"quantum physics"}, defi: "-"},
+	})
+	if err := d.Load(); err != nil {
+		t.Fatal(err)
+	}
+	results := d.SearchFuzzy("fhysics", 1, time.Second)
+	terms := map[string]uint64{}
+	for _, res := range results {
+		terms[res.Terms()[0]] = res.EntryIndex()
+	}
+	if len(terms) != 2 || terms["physics"] != 1 || terms["quantum physics"] != 2 {
+		t.Fatalf("unexpected results: %v", terms)
+	}
+}
+
+func TestNgramCandidates(t *testing.T) {
+	idx := newIdx(3)
+	for _, term := range []string{"absolutely", "resolute", "tell"} {
+		idx.Add(term, 0, 1)
+	}
+	ngi := buildNgramIndex(idx)
+	// 10 distinct n-grams and at most 2 edits, so 4 shared n-grams are
+	// needed: "resolute" shares 4, "tell" shares only "tel"
+	got := ngi.candidates([]rune("absolutely"))
+	if len(got) != 2 || got[0] != 0 || got[1] != 1 {
+		t.Fatalf("unexpected candidates: %v", got)
+	}
+	// short words keep every entry with a shared n-gram
+	got = ngi.candidates([]rune("tel"))
+	if len(got) != 2 || got[0] != 0 || got[1] != 2 {
+		t.Fatalf("unexpected candidates: %v", got)
+	}
+}
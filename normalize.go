@@ -0,0 +1,142 @@
+package stardict
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"unicode"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/language"
+	"golang.org/x/text/unicode/norm"
+)
+
+// NormForm is the Unicode normalization form applied to terms and queries
+type NormForm uint8
+
+const (
+	// NormNone applies no Unicode normalization
+	NormNone NormForm = iota
+	// NormNFC applies canonical composition, so decomposed (NFD) input
+	// matches composed headwords
+	NormNFC
+	// NormNFKC applies compatibility composition, which also maps
+	// ligatures, full-width forms etc. to their plain equivalents
+	NormNFKC
+)
+
+// DefaultNormalizer is used by dictionaries created with NewDictionary.
+// nil means terms and queries are only lowercased.
+var DefaultNormalizer *Normalizer
+
+// Normalizer converts terms and queries into the form used for matching.
+// The same Normalizer must be used for building the index and for queries,
+// so it should not be modified after it is passed to a dictionary.
+type Normalizer struct {
+	// Form is the Unicode normalization form
+	Form NormForm
+
+	// CaseFold applies full Unicode case folding instead of lowercasing,
+	// for example "ß" matches "ss"
+	CaseFold bool
+
+	// Language is a BCP 47 language tag for language-specific case mapping,
+	// for example "tr" maps "I" to "ı" (dotless i) and "İ" to "i"
+	Language string
+
+	// StripAccents removes diacritics, so "cafe" matches "café"
+	StripAccents bool
+
+	initOnce sync.Once
+	lang     language.Tag
+	casers   sync.Pool
+}
+
+type normCasers struct {
+	lower cases.Caser
+	fold  cases.Caser
+}
+
+func (n *Normalizer) init() {
+	n.lang = language.Und
+	if n.Language != "" {
+		tag, err := language.Parse(n.Language)
+		if err != nil {
+			ErrorHandler(fmt.Errorf("invalid normalizer language %#v: %w", n.Language, err))
+		} else {
+			n.lang = tag
+		}
+	}
+	// cases.Caser is stateful and must not be shared between goroutines
+	n.casers.New = func() any {
+		return &normCasers{
+			lower: cases.Lower(n.lang),
+			fold:  cases.Fold(),
+		}
+	}
+}
+
+// Key returns a string that identifies the normalizer settings,
+// used to invalidate cached indexes
+func (n *Normalizer) Key() string {
+	if n == nil {
+		return ""
+	}
+	return fmt.Sprintf(
+		"form=%d,fold=%v,lang=%s,strip=%v",
+		n.Form, n.CaseFold, n.Language, n.StripAccents,
+	)
+}
+
+// Normalize returns normalized form of str.
+// A nil Normalizer only lowercases str.
+func (n *Normalizer) Normalize(str string) string {
+	if n == nil {
+		return strings.ToLower(str)
+	}
+	n.initOnce.Do(n.init)
+	switch n.Form {
+	case NormNFC:
+		str = norm.NFC.String(str)
+	case NormNFKC:
+		str = norm.NFKC.String(str)
+	}
+	if n.lang == language.Und && !n.CaseFold {
+		str = strings.ToLower(str)
+	} else {
+		c := n.casers.Get().(*normCasers)
+		str = c.lower.String(str)
+		if n.CaseFold {
+			str = c.fold.String(str)
+		}
+		n.casers.Put(c)
+	}
+	if n.StripAccents {
+		str = stripAccents(str)
+	}
+	return str
+}
+
+// letters that have no canonical decomposition, but are commonly
+// typed without their stroke
+var accentFreeLetters = map[rune]rune{
+	'ł': 'l',
+	'ø': 'o',
+	'đ': 'd',
+	'ħ': 'h',
+	'ŧ': 't',
+}
+
+func stripAccents(str string) string {
+	str = norm.NFD.String(str)
+	str = strings.Map(func(r rune) rune {
+		if unicode.Is(unicode.Mn, r) {
+			return -1
+		}
+		if r2, ok := accentFreeLetters[r]; ok {
+			return r2
+		}
+		return r
+	}, str)
+	return norm.NFC.String(str)
+}
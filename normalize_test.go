@@ -0,0 +1,60 @@
+package stardict
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNormalizer(t *testing.T) {
+	test := func(n *Normalizer, input string, expected string) {
+		t.Helper()
+		actual := n.Normalize(input)
+		if actual != expected {
+			t.Errorf("Normalize(%#v): expected %#v, got %#v", input, expected, actual)
+		}
+	}
+	test(nil, "Café", "café")
+	test(&Normalizer{Form: NormNFC}, "Café", "café")
+	test(&Normalizer{Form: NormNFKC}, "ﬁne", "fine")
+	test(&Normalizer{StripAccents: true}, "Café Łódź", "cafe lodz")
+	test(&Normalizer{CaseFold: true}, "Straße", "strasse")
+	test(&Normalizer{Language: "tr"}, "DİYARBAKIR", "diyarbakır")
+}
+
+func TestSearchNormalized(t *testing.T) {
+	d := openTestDict(t, []testEntry{
+		{terms: []string{"café"}, defi: "coffee"},
+		{terms: []string{"Straße"}, defi: "street"},
+	})
+	d.SetNormalizer(&Normalizer{
+		Form:         NormNFC,
+		CaseFold:     true,
+		StripAccents: true,
+	})
+	if err := d.Load(); err != nil {
+		t.Fatal(err)
+	}
+	for _, query := range []string{"cafe", "CAFÉ", "strasse"} {
+		results := d.SearchExact(query, 1, time.Second)
+		if len(results) != 1 {
+			t.Errorf("query %#v: expected 1 result, got %d", query, len(results))
+		}
+	}
+	results := d.SearchStartWith("caf", 1, time.Second)
+	if len(results) != 1 || results[0].Terms()[0] != "café" {
+		t.Errorf("unexpected results for prefix query: %v", results)
+	}
+}
+
+func TestWordPrefixMapNormalized(t *testing.T) {
+	wpm := WordPrefixMap{}
+	wpm.AddNormalized("Éclair Ünit", 3, &Normalizer{StripAccents: true})
+	for _, prefix := range []rune{'e', 'u'} {
+		if _, ok := wpm[prefix][3]; !ok {
+			t.Fatalf("missing prefix %q: %v", prefix, wpm)
+		}
+	}
+	if len(wpm) != 2 {
+		t.Fatalf("unexpected prefixes: %v", wpm)
+	}
+}
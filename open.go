@@ -1,6 +1,7 @@
 package stardict
 
 import (
+	"errors"
 	"fmt"
 	"io/fs"
 	"log/slog"
@@ -16,65 +17,511 @@ import (
 
 const ifoExt = ".ifo"
 
-// Open open directories
+// LoadStatus is the state of a dictionary after OpenWithReport
+type LoadStatus uint8
+
+const (
+	// LoadStatusLoaded means the dictionary is loaded successfully
+	LoadStatusLoaded LoadStatus = iota
+	// LoadStatusDisabled means the dictionary is disabled by order,
+	// and is not loaded
+	LoadStatusDisabled
+	// LoadStatusFailed means the dictionary could not be initialized
+	// or loaded, see Err
+	LoadStatusFailed
+)
+
+func (s LoadStatus) String() string {
+	switch s {
+	case LoadStatusLoaded:
+		return "loaded"
+	case LoadStatusDisabled:
+		return "disabled"
+	}
+	return "failed"
+}
+
+// DictLoadResult is the load status of one dictionary
+type DictLoadResult struct {
+	// Path is the .ifo file, or the file or directory that failed
+	// before the dictionary could be initialized
+	Path     string
+	DictName string
+	// Dictionary is nil if the dictionary could not be initialized
+	Dictionary common.Dictionary
+	Status     LoadStatus
+	// Duration is the time spent in Load
+	Duration time.Duration
+	Err      error
+}
+
+// LoadReport lists the load status of every dictionary found by OpenWithReport
+type LoadReport struct {
+	Dictionaries []*DictLoadResult
+	Duration     time.Duration
+}
+
+// Failed returns the results with LoadStatusFailed
+func (r *LoadReport) Failed() []*DictLoadResult {
+	var list []*DictLoadResult
+	for _, res := range r.Dictionaries {
+		if res.Status == LoadStatusFailed {
+			list = append(list, res)
+		}
+	}
+	return list
+}
+
+// SymlinkPolicy decides how symbolic links are handled while scanning
+// directories in OpenWithOptions
+type SymlinkPolicy uint8
+
+const (
+	// SymlinkFollow follows links to files and directories,
+	// directories that were already scanned are skipped
+	SymlinkFollow SymlinkPolicy = iota
+	// SymlinkFiles follows links to files, but not to directories
+	SymlinkFiles
+	// SymlinkIgnore ignores all symbolic links
+	SymlinkIgnore
+)
+
+// ProgressStage is the stage of a ProgressEvent
+type ProgressStage uint8
+
+const (
+	// ProgressFound is sent when a dictionary is found and initialized
+	ProgressFound ProgressStage = iota
+	// ProgressLoaded is sent when a dictionary is loaded
+	ProgressLoaded
+	// ProgressFailed is sent when a dictionary fails to initialize or load
+	ProgressFailed
+)
+
+// ProgressEvent is passed to OpenOptions.Progress
+type ProgressEvent struct {
+	Stage    ProgressStage
+	Path     string
+	DictName string
+	// Done is the number of dictionaries that are loaded or failed so far,
+	// Total is the number of dictionaries to load (known after scanning)
+	Done  int
+	Total int
+	Err   error
+}
+
+// OpenOptions are the options of OpenWithOptions
+type OpenOptions struct {
+	// Dirs is the list of directories to scan
+	Dirs []string
+
+	// BaseDir is used to resolve relative paths in Dirs,
+	// empty means the current directory
+	BaseDir string
+
+	// Order maps dictionary names to their order,
+	// dictionaries with negative order are disabled and not loaded
+	Order map[string]int
+
+	// MaxDepth is the maximum depth of sub-directories to scan,
+	// 0 means 1 (like Open) and -1 means unlimited
+	MaxDepth int
+
+	// Symlinks is the policy for symbolic links
+	Symlinks SymlinkPolicy
+
+	// MaxConcurrentLoads limits the number of dictionaries that are
+	// loaded at the same time, 0 means runtime.NumCPU()
+	MaxConcurrentLoads int
+
+	// Lazy defers loading each dictionary until its first search
+	Lazy bool
+
+	// FSTIndex builds an FST index of terms for each dictionary,
+	// see SetFSTIndex
+	FSTIndex bool
+
+	// Pool, if set, manages loading and unloading of the returned
+	// dictionaries, which implies Lazy
+	Pool *Pool
+
+	// Include, if not empty, only keeps dictionaries whose bookname equals
+	// or matches one of these glob patterns, or whose .ifo path matches.
+	// Exclude removes dictionaries the same way, after Include.
+	Include []string
+	Exclude []string
+
+	// Normalizer and Lemmatizer are set on every dictionary,
+	// nil means DefaultNormalizer and DefaultLemmatizer
+	Normalizer *Normalizer
+	Lemmatizer Lemmatizer
+
+	// Logger is used instead of slog.Default()
+	Logger *slog.Logger
+
+	// ErrorHandler is used for errors while opening and by the returned
+	// dictionaries, nil means the global ErrorHandler
+	ErrorHandler func(error)
+
+	// Progress is called for each found, loaded or failed dictionary.
+	// Calls are serialized, but may come from different goroutines.
+	Progress func(ProgressEvent)
+}
+
+func (opts *OpenOptions) maxDepth() int {
+	if opts.MaxDepth == 0 {
+		return 1
+	}
+	return opts.MaxDepth
+}
+
+func (opts *OpenOptions) lazy() bool {
+	return opts.Lazy || opts.Pool != nil
+}
+
+func (opts *OpenOptions) maxConcurrentLoads() int {
+	if opts.MaxConcurrentLoads > 0 {
+		return opts.MaxConcurrentLoads
+	}
+	return runtime.NumCPU()
+}
+
+// matchDictPatterns returns true if bookname or ifoPath matches one of patterns
+func matchDictPatterns(patterns []string, bookname string, ifoPath string) bool {
+	for _, pattern := range patterns {
+		if pattern == bookname {
+			return true
+		}
+		if ok, _ := filepath.Match(pattern, bookname); ok {
+			return true
+		}
+		if ok, _ := filepath.Match(pattern, ifoPath); ok {
+			return true
+		}
+		if ok, _ := filepath.Match(pattern, filepath.Base(ifoPath)); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (opts *OpenOptions) filter(dic *dictionaryImp) bool {
+	name := dic.DictName()
+	if len(opts.Include) > 0 && !matchDictPatterns(opts.Include, name, dic.ifoPath) {
+		return false
+	}
+	return !matchDictPatterns(opts.Exclude, name, dic.ifoPath)
+}
+
+// Open open directories, relative paths are relative to home directory
 func Open(dirPathList []string, order map[string]int) ([]common.Dictionary, error) {
-	var dicList []common.Dictionary
+	dicList, _, err := OpenWithReport(dirPathList, order, nil)
+	return dicList, err
+}
 
+// OpenWithReport is like Open, but also returns the load status, timing
+// and error of each dictionary. errorHandler is used for errors while
+// loading and by the returned dictionaries (for example for errors in
+// Items() of search results), nil means the global ErrorHandler.
+// Dictionaries that fail to load are still returned, but not loaded.
+func OpenWithReport(
+	dirPathList []string,
+	order map[string]int,
+	errorHandler func(error),
+) ([]common.Dictionary, *LoadReport, error) {
 	homeDir, err := os.UserHomeDir()
 	if err != nil {
-		panic(err)
+		return nil, nil, err
+	}
+	return OpenWithOptions(&OpenOptions{
+		Dirs:         dirPathList,
+		BaseDir:      homeDir,
+		Order:        order,
+		ErrorHandler: errorHandler,
+	})
+}
+
+// opener holds the state of one OpenWithOptions call
+type opener struct {
+	opts         *OpenOptions
+	logger       *slog.Logger
+	errorHandler func(error)
+	report       *LoadReport
+
+	// visited holds real paths of scanned directories
+	visited map[string]bool
+	// dirs and ifoFiles are scanned directories and found .ifo files
+	dirs     []string
+	ifoFiles []string
+	// failedDirs are existing directories that could not be read
+	failedDirs []string
+
+	dicList []common.Dictionary
+	results []*DictLoadResult
+
+	progressLock sync.Mutex
+	done         int
+}
+
+func newOpener(opts *OpenOptions) *opener {
+	o := &opener{
+		opts:         opts,
+		logger:       opts.Logger,
+		errorHandler: opts.ErrorHandler,
+		report:       &LoadReport{},
+		visited:      map[string]bool{},
+	}
+	if o.logger == nil {
+		o.logger = slog.Default()
+	}
+	if o.errorHandler == nil {
+		o.errorHandler = ErrorHandler
+	}
+	return o
+}
+
+// OpenWithOptions scans directories for dictionaries, and loads them
+// (unless opts.Lazy is set). It returns all found dictionaries, including
+// disabled ones and the ones that failed to load, and the load report.
+func OpenWithOptions(opts *OpenOptions) ([]common.Dictionary, *LoadReport, error) {
+	o := newOpener(opts)
+	o.open()
+	return o.dicList, o.report, nil
+}
+
+func (o *opener) open() {
+	t0 := time.Now()
+	o.scan()
+	for _, ifoPath := range o.ifoFiles {
+		o.addDictionary(ifoPath)
 	}
-	for _, dirPath := range dirPathList {
+	if o.opts.lazy() {
+		o.logger.Info("Lazy loading enabled, not loading indexes")
+	} else {
+		o.loadAll()
+	}
+	o.report.Dictionaries = append(o.report.Dictionaries, o.results...)
+	o.report.Duration = time.Since(t0)
+}
+
+// scan finds .ifo files in opts.Dirs
+func (o *opener) scan() {
+	for _, dirPath := range o.opts.Dirs {
 		// dirPath = pathFromUnix(dirPath) // not needed for relative paths
 		if !filepath.IsAbs(dirPath) {
-			dirPath = filepath.Join(homeDir, dirPath)
+			if o.opts.BaseDir != "" {
+				dirPath = filepath.Join(o.opts.BaseDir, dirPath)
+			} else {
+				absPath, err := filepath.Abs(dirPath)
+				if err != nil {
+					o.failDir(dirPath, err)
+					continue
+				}
+				dirPath = absPath
+			}
 		}
+		o.scanDir(dirPath, 0)
+	}
+}
 
-		dirEntries, err := os.ReadDir(dirPath)
-		if err != nil {
-			ErrorHandler(err)
-			continue
+func (o *opener) progress(event ProgressEvent) {
+	if o.opts.Progress == nil {
+		return
+	}
+	o.progressLock.Lock()
+	defer o.progressLock.Unlock()
+	if event.Stage != ProgressFound {
+		o.done++
+	}
+	event.Done = o.done
+	event.Total = len(o.results)
+	o.opts.Progress(event)
+}
+
+func (o *opener) fail(path string, dictName string, err error) {
+	o.errorHandler(err)
+	o.report.Dictionaries = append(o.report.Dictionaries, &DictLoadResult{
+		Path:     path,
+		DictName: dictName,
+		Status:   LoadStatusFailed,
+		Err:      err,
+	})
+	o.progress(ProgressEvent{
+		Stage:    ProgressFailed,
+		Path:     path,
+		DictName: dictName,
+		Err:      err,
+	})
+}
+
+// failDir reports a directory that can not be scanned, and unless it
+// does not exist, remembers it so its dictionaries are not treated as removed
+func (o *opener) failDir(dirPath string, err error) {
+	o.fail(dirPath, "", err)
+	if !errors.Is(err, fs.ErrNotExist) {
+		o.failedDirs = append(o.failedDirs, dirPath)
+	}
+}
+
+// inFailedDir returns true if path is in a directory (or sub-directory)
+// that could not be scanned
+func (o *opener) inFailedDir(path string) bool {
+	for _, dir := range o.failedDirs {
+		rel, err := filepath.Rel(dir, path)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return true
 		}
-		for _, fi := range dirEntries {
-			dic, err := checkDirEntry(dirPath, fi)
-			if err != nil {
-				ErrorHandler(err)
+	}
+	return false
+}
+
+// scanDir finds .ifo files in dirPath, and scans its sub-directories
+// up to the maximum depth
+func (o *opener) scanDir(dirPath string, depth int) {
+	realPath, err := filepath.EvalSymlinks(dirPath)
+	if err != nil {
+		o.failDir(dirPath, err)
+		return
+	}
+	if o.visited[realPath] {
+		return
+	}
+	o.visited[realPath] = true
+	o.dirs = append(o.dirs, dirPath)
+
+	dirEntries, err := os.ReadDir(dirPath)
+	if err != nil {
+		o.failDir(dirPath, err)
+		return
+	}
+	maxDepth := o.opts.maxDepth()
+	for _, entry := range dirEntries {
+		path := filepath.Join(dirPath, entry.Name())
+		isDir := entry.IsDir()
+		if entry.Type()&fs.ModeSymlink != 0 {
+			if o.opts.Symlinks == SymlinkIgnore {
 				continue
 			}
-			if dic == nil {
+			stat, err := os.Stat(path)
+			if err != nil {
+				o.fail(path, "", err)
 				continue
 			}
-			if order[dic.DictName()] < 0 {
-				dic.disabled = true
-				dicList = append(dicList, dic)
+			isDir = stat.IsDir()
+			if isDir && o.opts.Symlinks == SymlinkFiles {
 				continue
 			}
-			dicList = append(dicList, dic)
 		}
+		if isDir {
+			if maxDepth < 0 || depth < maxDepth {
+				o.scanDir(path, depth+1)
+			}
+			continue
+		}
+		if filepath.Ext(entry.Name()) != ifoExt {
+			continue
+		}
+		o.ifoFiles = append(o.ifoFiles, path)
 	}
-	slog.Info("Starting to load indexes")
+}
+
+// addDictionary initializes the dictionary of ifoPath, it returns nil
+// if the dictionary fails to initialize or is filtered out
+func (o *opener) addDictionary(ifoPath string) *dictionaryImp {
+	opts := o.opts
+	dictDir, fname := filepath.Split(ifoPath)
+	o.logger.Info("Initializing dictionary", "directory", dictDir)
+	dic, err := NewDictionary(dictDir, fname[:len(fname)-len(ifoExt)])
+	if err != nil {
+		o.fail(ifoPath, "", err)
+		return nil
+	}
+	if !opts.filter(dic) {
+		o.logger.Debug("Skipping filtered dictionary", "path", ifoPath)
+		return nil
+	}
+	resDir := filepath.Join(dictDir, "res")
+	if isDir(resDir) {
+		dic.resDir = resDir
+		dic.resURL = "file://" + pathToUnix(resDir)
+	}
+	if opts.Order[dic.DictName()] < 0 {
+		dic.disabled.Store(true)
+	}
+	if opts.Normalizer != nil {
+		dic.normalizer = opts.Normalizer
+	}
+	if opts.Lemmatizer != nil {
+		dic.lemmatizer = opts.Lemmatizer
+	}
+	dic.errorHandler = o.errorHandler
+	dic.logger = opts.Logger
+	dic.lazy = opts.lazy()
+	dic.useFST = opts.FSTIndex
+	if opts.Pool != nil {
+		// can not fail for *dictionaryImp
+		_ = opts.Pool.Add(dic)
+	}
+	o.dicList = append(o.dicList, dic)
+	o.results = append(o.results, &DictLoadResult{
+		Path:       ifoPath,
+		DictName:   dic.DictName(),
+		Dictionary: dic,
+		Status:     LoadStatusDisabled,
+	})
+	o.progress(ProgressEvent{
+		Stage:    ProgressFound,
+		Path:     ifoPath,
+		DictName: dic.DictName(),
+	})
+	return dic
+}
+
+func (o *opener) loadAll() {
+	o.logger.Info("Starting to load indexes")
 	var wg sync.WaitGroup
-	load := func(dic common.Dictionary) {
+	sem := make(chan struct{}, o.opts.maxConcurrentLoads())
+	load := func(res *DictLoadResult) {
 		defer wg.Done()
+		sem <- struct{}{}
+		defer func() { <-sem }()
+		dic := res.Dictionary
 		t0 := time.Now()
-		err = dic.Load()
+		err := dic.Load()
+		res.Duration = time.Since(t0)
 		if err != nil {
-			ErrorHandler(fmt.Errorf("error loading %#v: %w", dic.DictName(), err))
-		} else {
-			slog.Info("Loaded index", "path", dic.IndexPath(), "dt", time.Since(t0))
+			res.Status = LoadStatusFailed
+			res.Err = fmt.Errorf("error loading %#v: %w", dic.DictName(), err)
+			o.errorHandler(res.Err)
+			o.progress(ProgressEvent{
+				Stage:    ProgressFailed,
+				Path:     res.Path,
+				DictName: res.DictName,
+				Err:      res.Err,
+			})
+			return
 		}
+		res.Status = LoadStatusLoaded
+		o.logger.Info("Loaded index", "path", dic.IndexPath(), "dt", res.Duration)
+		o.progress(ProgressEvent{
+			Stage:    ProgressLoaded,
+			Path:     res.Path,
+			DictName: res.DictName,
+		})
 	}
-	for _, dic := range dicList {
-		if dic.Disabled() {
+	for _, res := range o.results {
+		if res.Dictionary.Disabled() {
 			continue
 		}
 		wg.Add(1)
-		go load(dic)
+		go load(res)
 	}
 	wg.Wait()
-	return dicList, nil
 }
 
+// DirEntryFromFileInfo implements fs.DirEntry for a fs.FileInfo.
+//
+// Deprecated: use fs.FileInfoToDirEntry instead.
 type DirEntryFromFileInfo struct {
 	fs.FileInfo
 }
@@ -87,40 +534,6 @@ func (e *DirEntryFromFileInfo) Info() (fs.FileInfo, error) {
 	return e.FileInfo, nil
 }
 
-func checkDirEntry(parentDir string, entry os.DirEntry) (*dictionaryImp, error) {
-	path := filepath.Join(parentDir, entry.Name())
-	dictDir := parentDir
-	if entry.IsDir() {
-		_, ifoFi, err := findIfoFile(path)
-		if err != nil {
-			return nil, err
-		}
-		if ifoFi == nil {
-			return nil, nil
-		}
-		entry = &DirEntryFromFileInfo{FileInfo: ifoFi}
-		dictDir = path
-	}
-	name := entry.Name()
-	if filepath.Ext(name) != ifoExt {
-		return nil, nil
-	}
-	slog.Info("Initializing dictionary", "directory", dictDir)
-	dic, err := NewDictionary(
-		dictDir,
-		name[:len(name)-len(ifoExt)],
-	)
-	if err != nil {
-		return nil, err
-	}
-	resDir := filepath.Join(dictDir, "res")
-	if isDir(resDir) {
-		dic.resDir = resDir
-		dic.resURL = "file://" + pathToUnix(resDir)
-	}
-	return dic, nil
-}
-
 func isDir(pathStr string) bool {
 	stat, _ := os.Stat(pathStr)
 	if stat == nil {
@@ -129,27 +542,6 @@ func isDir(pathStr string) bool {
 	return stat.IsDir()
 }
 
-func findIfoFile(path string) (string, os.FileInfo, error) {
-	dirEntries, err := os.ReadDir(path)
-	if err != nil {
-		return "", nil, err
-	}
-	for _, de := range dirEntries {
-		if filepath.Ext(de.Name()) != ifoExt {
-			continue
-		}
-		fi, err := de.Info()
-		if err != nil {
-			return "", nil, err
-		}
-		if fi == nil {
-			return "", nil, nil
-		}
-		return filepath.Join(path, fi.Name()), fi, nil
-	}
-	return "", nil, nil
-}
-
 func pathToUnix(pathStr string) string {
 	if runtime.GOOS != "windows" {
 		return pathStr
@@ -0,0 +1,64 @@
+package stardict
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestOpenWithOptions(t *testing.T) {
+	base := t.TempDir()
+	for _, dir := range []string{"a/b/c", "a/skip"} {
+		if err := os.MkdirAll(filepath.Join(base, dir), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeTestDict(t, filepath.Join(base, "a"), "top", testEntries)
+	writeTestDict(t, filepath.Join(base, "a", "b", "c"), "deep", testEntries)
+	writeTestDict(t, filepath.Join(base, "a", "skip"), "skipped", testEntries)
+	// a link loop must not be scanned forever
+	if err := os.Symlink(filepath.Join(base, "a"), filepath.Join(base, "a", "b", "loop")); err != nil {
+		t.Fatal(err)
+	}
+
+	var events []ProgressEvent
+	dicList, report, err := OpenWithOptions(&OpenOptions{
+		Dirs:     []string{"a"},
+		BaseDir:  base,
+		MaxDepth: -1,
+		Lazy:     true,
+		Exclude:  []string{"skip*"},
+		Progress: func(event ProgressEvent) {
+			events = append(events, event)
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dicList) != 2 || len(report.Dictionaries) != 2 || len(events) != 2 {
+		t.Fatalf("expected 2 dictionaries, got %d, %d results and %d events",
+			len(dicList), len(report.Dictionaries), len(events))
+	}
+	for _, dic := range dicList {
+		if dic.Loaded() {
+			t.Fatalf("%s: loaded before first search", dic.DictName())
+		}
+		if len(dic.SearchExact("apple", 0, 0)) == 0 {
+			t.Fatalf("%s: no result for lazy search", dic.DictName())
+		}
+		if !dic.Loaded() {
+			t.Fatalf("%s: not loaded after search", dic.DictName())
+		}
+	}
+
+	dicList, _, err = OpenWithOptions(&OpenOptions{
+		Dirs:    []string{filepath.Join(base, "a")},
+		Include: []string{"top"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dicList) != 1 || !dicList[0].Loaded() {
+		t.Fatalf("expected 1 loaded dictionary, got %d", len(dicList))
+	}
+}
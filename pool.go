@@ -0,0 +1,146 @@
+package stardict
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	common "codeberg.org/ilius/go-dict-commons"
+)
+
+// Pool manages loading of dictionaries: each dictionary is loaded on its
+// first search, and least recently used dictionaries are unloaded when
+// the memory used by loaded indexes exceeds the memory limit.
+// Unloaded dictionaries are loaded again on their next use.
+type Pool struct {
+	mu          sync.Mutex
+	memoryLimit int64
+	dicts       []*dictionaryImp
+}
+
+// NewPool returns a new Pool, memoryLimit is in bytes and 0 means unlimited
+func NewPool(memoryLimit int64) *Pool {
+	return &Pool{memoryLimit: memoryLimit}
+}
+
+// SetMemoryLimit changes the memory limit, and unloads dictionaries
+// if needed
+func (p *Pool) SetMemoryLimit(memoryLimit int64) {
+	p.mu.Lock()
+	p.memoryLimit = memoryLimit
+	p.mu.Unlock()
+	p.evict(nil)
+}
+
+// Add adds a dictionary returned by Open (or similar functions) to pool,
+// and enables its lazy loading
+func (p *Pool) Add(dic common.Dictionary) error {
+	d, ok := dic.(*dictionaryImp)
+	if !ok {
+		return fmt.Errorf("can not add %T to stardict pool", dic)
+	}
+	d.loadMu.Lock()
+	d.lazy = true
+	d.pool = p
+	d.loadMu.Unlock()
+
+	p.mu.Lock()
+	p.dicts = append(p.dicts, d)
+	p.mu.Unlock()
+	p.evict(nil)
+	return nil
+}
+
+// Remove removes a dictionary from pool, without unloading it
+func (p *Pool) Remove(dic common.Dictionary) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for i, d := range p.dicts {
+		if common.Dictionary(d) != dic {
+			continue
+		}
+		d.loadMu.Lock()
+		d.pool = nil
+		d.loadMu.Unlock()
+		p.dicts = append(p.dicts[:i], p.dicts[i+1:]...)
+		return
+	}
+}
+
+// MemoryUsage returns the estimated memory used by loaded indexes in bytes
+func (p *Pool) MemoryUsage() int64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.memoryUsage()
+}
+
+func (p *Pool) memoryUsage() int64 {
+	var usage int64
+	for _, d := range p.dicts {
+		usage += d.MemorySize()
+	}
+	return usage
+}
+
+// UnloadIdle unloads dictionaries that are not used in the last maxIdle
+// duration, and returns the number of unloaded dictionaries
+func (p *Pool) UnloadIdle(maxIdle time.Duration) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	count := 0
+	for _, d := range p.dicts {
+		if time.Since(d.LastUsed()) < maxIdle {
+			continue
+		}
+		if d.Unload() {
+			count++
+		}
+	}
+	return count
+}
+
+// evict unloads least recently used dictionaries (except keep) until
+// memory usage is under the limit. Dictionaries in use are skipped.
+func (p *Pool) evict(keep *dictionaryImp) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.memoryLimit <= 0 {
+		return
+	}
+	usage := p.memoryUsage()
+	if usage <= p.memoryLimit {
+		return
+	}
+	type candidate struct {
+		dic     *dictionaryImp
+		size    int64
+		lastUse int64
+	}
+	candidates := make([]candidate, 0, len(p.dicts))
+	for _, d := range p.dicts {
+		if d == keep {
+			continue
+		}
+		size := d.MemorySize()
+		if size == 0 {
+			continue
+		}
+		candidates = append(candidates, candidate{
+			dic:     d,
+			size:    size,
+			lastUse: d.lastUse.Load(),
+		})
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].lastUse < candidates[j].lastUse
+	})
+	for _, c := range candidates {
+		if usage <= p.memoryLimit {
+			return
+		}
+		if c.dic.Unload() {
+			usage -= c.size
+		}
+	}
+}
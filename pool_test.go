@@ -0,0 +1,99 @@
+package stardict
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestPool(t *testing.T) {
+	dir := t.TempDir()
+	for i := 0; i < 3; i++ {
+		writeTestDict(t, dir, fmt.Sprintf("dict%d", i), testEntries)
+	}
+	pool := NewPool(1)
+	dicList, _, err := OpenWithOptions(&OpenOptions{
+		Dirs: []string{dir},
+		Pool: pool,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dicList) != 3 {
+		t.Fatalf("expected 3 dictionaries, got %d", len(dicList))
+	}
+	if usage := pool.MemoryUsage(); usage != 0 {
+		t.Fatalf("expected no memory usage before search, got %d", usage)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(dic *dictionaryImp) {
+			defer wg.Done()
+			results := dic.SearchExact("apple", 0, 0)
+			if len(results) != 1 {
+				t.Errorf("%s: expected 1 result, got %d", dic.DictName(), len(results))
+				return
+			}
+			items := results[0].Items()
+			if len(items) != 1 || string(items[0].Data) != "a fruit" {
+				t.Errorf("%s: unexpected items %v", dic.DictName(), items)
+			}
+		}(dicList[i%3].(*dictionaryImp))
+	}
+	wg.Wait()
+
+	// with a limit of 1 byte, only the last used dictionary stays loaded
+	pool.UnloadIdle(0)
+	for _, dic := range dicList {
+		dic.SearchExact("banana", 0, 0)
+	}
+	loaded := 0
+	for _, dic := range dicList {
+		if dic.Loaded() {
+			loaded++
+		}
+	}
+	if loaded != 1 {
+		t.Fatalf("expected 1 loaded dictionary, got %d", loaded)
+	}
+
+	pool.SetMemoryLimit(0)
+	for _, dic := range dicList {
+		dic.SearchExact("banana", 0, 0)
+	}
+	if pool.UnloadIdle(0) != 3 || pool.MemoryUsage() != 0 {
+		t.Fatal("expected all dictionaries to be unloaded")
+	}
+}
+
+func TestPoolRemoveWhileLoading(t *testing.T) {
+	dir := t.TempDir()
+	for i := 0; i < 2; i++ {
+		writeTestDict(t, dir, fmt.Sprintf("dict%d", i), testEntries)
+	}
+	pool := NewPool(1)
+	dicList, _, err := OpenWithOptions(&OpenOptions{
+		Dirs: []string{dir},
+		Pool: pool,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		dic := dicList[i%2]
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			dic.SearchExact("apple", 0, 0)
+		}()
+		go func() {
+			defer wg.Done()
+			pool.Remove(dic)
+			_ = pool.Add(dic)
+		}()
+	}
+	wg.Wait()
+}
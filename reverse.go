@@ -0,0 +1,338 @@
+package stardict
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	common "codeberg.org/ilius/go-dict-commons"
+	"github.com/ilius/go-stardict/v2/murmur3"
+)
+
+// ReverseOptions are the options of BuildReverse
+type ReverseOptions struct {
+	// Language is the language of definitions as a BCP 47 tag,
+	// empty means DefinitionLanguage of the dictionary
+	Language string
+
+	// Tokenize splits definition text into tokens,
+	// nil means TokenizeText with Language
+	Tokenize func(text string) []string
+
+	// Normalizer is used for tokens and queries of the reverse dictionary,
+	// nil means a Normalizer with Language (or lowercasing if no language)
+	Normalizer *Normalizer
+
+	// MinTokenLength is the minimum number of characters of a token,
+	// 0 means 1 for Chinese and Japanese, and 2 for other languages
+	MinTokenLength int
+
+	// MaxEntriesPerToken skips tokens found in more entries,
+	// usually stop words like "the" or "a". 0 means no limit.
+	MaxEntriesPerToken int
+}
+
+// ReverseDictionary is an in-memory dictionary whose headwords are the
+// tokens of definitions of another dictionary. The article of each token
+// lists the headwords of source entries (one per line), and SourceEntries
+// gives their entry indexes.
+type ReverseDictionary struct {
+	*dictionaryImp
+
+	source   *dictionaryImp
+	language string
+	// entries are the source entry indexes of each token
+	entries [][]int32
+}
+
+// DefinitionLanguage returns the language of definitions from .ifo options,
+// or empty string if not known
+func (info Info) DefinitionLanguage() string {
+	if info.TargetLang != "" {
+		return info.TargetLang
+	}
+	lang := info.Lang
+	// "en-de" means English to German, while "en-US" is a region
+	if src, target, ok := strings.Cut(lang, "-"); ok && isLanguageCode(src) && isLanguageCode(target) {
+		return target
+	}
+	return lang
+}
+
+func isLanguageCode(str string) bool {
+	if len(str) < 2 || len(str) > 3 {
+		return false
+	}
+	for _, c := range str {
+		if c < 'a' || c > 'z' {
+			return false
+		}
+	}
+	return true
+}
+
+// primaryLanguage returns the lowercase primary subtag of a language tag
+func primaryLanguage(lang string) string {
+	lang, _, _ = strings.Cut(lang, "-")
+	lang, _, _ = strings.Cut(lang, "_")
+	return strings.ToLower(lang)
+}
+
+// isIdeographic returns true for characters that are words on their own,
+// since Chinese and Japanese text has no spaces between words
+func isIdeographic(c rune) bool {
+	return unicode.In(c, unicode.Han, unicode.Hiragana, unicode.Katakana)
+}
+
+// TokenizeText splits plain text into words of the given language.
+// Words are made of letters, marks and digits, each Chinese or Japanese
+// character is a separate token, and for languages with elision like
+// French, apostrophes split words ("l'eau" gives "l" and "eau").
+func TokenizeText(text string, language string) []string {
+	splitApostrophe := false
+	switch primaryLanguage(language) {
+	case "fr", "it", "ca":
+		splitApostrophe = true
+	}
+	var tokens []string
+	var word []rune
+	flush := func() {
+		if len(word) > 0 {
+			tokens = append(tokens, string(word))
+			word = word[:0]
+		}
+	}
+	runes := []rune(text)
+	for i, c := range runes {
+		switch {
+		case isIdeographic(c):
+			flush()
+			tokens = append(tokens, string(c))
+		case unicode.IsLetter(c) || unicode.IsMark(c) || unicode.IsDigit(c):
+			word = append(word, c)
+		case (c == '\'' || c == '’') && !splitApostrophe &&
+			len(word) > 0 && i+1 < len(runes) && unicode.IsLetter(runes[i+1]):
+			word = append(word, c)
+		default:
+			flush()
+		}
+	}
+	flush()
+	return tokens
+}
+
+var (
+	markupTagRE = regexp.MustCompile(`<[^>]*>`)
+	xdxfKeyRE   = regexp.MustCompile(`(?s)<k>.*?</k>`)
+)
+
+// definitionText returns the plain text of definition items,
+// skipping phonetics, resources and binary items
+func definitionText(items []*common.SearchResultItem) string {
+	var sb strings.Builder
+	for _, item := range items {
+		data := item.Data
+		switch item.Type {
+		case 'm', 'l', 'w':
+		case 'x':
+			// headword is repeated in <k> tag
+			data = xdxfKeyRE.ReplaceAll(data, nil)
+			data = markupTagRE.ReplaceAll(data, []byte(" "))
+		case 'g', 'h', 'k':
+			data = markupTagRE.ReplaceAll(data, []byte(" "))
+		default:
+			continue
+		}
+		sb.WriteString(html.UnescapeString(string(data)))
+		sb.WriteByte('\n')
+	}
+	return sb.String()
+}
+
+// memDictFile is a DictFile in memory
+type memDictFile struct {
+	*bytes.Reader
+}
+
+func (memDictFile) Close() error {
+	return nil
+}
+
+// BuildReverse reads all definitions and builds a ReverseDictionary,
+// which is loaded and needs no Load. nil opts means default options.
+func (d *dictionaryImp) BuildReverse(opts *ReverseOptions) (*ReverseDictionary, error) {
+	if opts == nil {
+		opts = &ReverseOptions{}
+	}
+	language := opts.Language
+	if language == "" {
+		language = d.DefinitionLanguage()
+	}
+	tokenize := opts.Tokenize
+	if tokenize == nil {
+		tokenize = func(text string) []string {
+			return TokenizeText(text, language)
+		}
+	}
+	normalizer := opts.Normalizer
+	if normalizer == nil && language != "" {
+		normalizer = &Normalizer{Language: language}
+	}
+	minLength := opts.MinTokenLength
+	if minLength == 0 {
+		minLength = 2
+		switch primaryLanguage(language) {
+		case "zh", "ja":
+			minLength = 1
+		}
+	}
+
+	idx, release, err := d.acquire()
+	if err != nil {
+		return nil, err
+	}
+	byToken := map[string][]int32{}
+	for entryIndex, entry := range idx.entries {
+		data, err := d.dict.ReadSequence(entry.offset, entry.size)
+		if err != nil {
+			release()
+			return nil, err
+		}
+		items, err := decodeItems(data, d.SameTypeSequence)
+		if err != nil {
+			d.handleError(fmt.Errorf(
+				"error decoding article of %#v from %#v: %w",
+				entry.terms[0], d.DictName(), err,
+			))
+		}
+		for _, token := range tokenize(definitionText(items)) {
+			if len([]rune(token)) < minLength {
+				continue
+			}
+			key := normalizer.Normalize(token)
+			list := byToken[key]
+			if len(list) > 0 && list[len(list)-1] == int32(entryIndex) {
+				continue
+			}
+			byToken[key] = append(list, int32(entryIndex))
+		}
+	}
+
+	keys := make([]string, 0, len(byToken))
+	for key, list := range byToken {
+		if opts.MaxEntriesPerToken > 0 && len(list) > opts.MaxEntriesPerToken {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return CompareTerms(keys[i], keys[j]) < 0
+	})
+
+	revIdx := newIdx(len(keys))
+	revIdx.normalizer = normalizer
+	revIdx.errorHandler = d.errorHandler
+	wordPrefixMap := WordPrefixMap{}
+	entries := make([][]int32, len(keys))
+	var data bytes.Buffer
+	for i, key := range keys {
+		entries[i] = byToken[key]
+		offset := data.Len()
+		for j, entryIndex := range entries[i] {
+			if j > 0 {
+				data.WriteByte('\n')
+			}
+			data.WriteString(idx.entries[entryIndex].terms[0])
+		}
+		termIndex := revIdx.Add(key, uint64(offset), uint64(data.Len()-offset))
+		revIdx.addKey(wordPrefixMap, revIdx.entries[termIndex], key, termIndex)
+	}
+	revIdx.setWordPrefixMap(wordPrefixMap)
+	release()
+
+	rev := &dictionaryImp{
+		Info: newInfo(map[string]string{
+			I_bookname:         d.DictName() + " (reverse)",
+			I_wordcount:        strconv.Itoa(len(keys)),
+			I_sametypesequence: "m",
+			I_lang:             language,
+		}),
+		dict: &Dict{
+			filename: d.dictPath + " (reverse)",
+			file:     memDictFile{bytes.NewReader(data.Bytes())},
+			size:     uint64(data.Len()),
+			logger:   d.logger,
+		},
+		idx:          revIdx,
+		normalizer:   normalizer,
+		errorHandler: d.errorHandler,
+		logger:       d.logger,
+	}
+	rev.idxSize = revIdx.memorySize() + int64(data.Len())
+	return &ReverseDictionary{
+		dictionaryImp: rev,
+		source:        d,
+		language:      language,
+		entries:       entries,
+	}, nil
+}
+
+// Source returns the dictionary that the reverse dictionary is built from
+func (r *ReverseDictionary) Source() common.Dictionary {
+	return r.source
+}
+
+// Language returns the language of tokens
+func (r *ReverseDictionary) Language() string {
+	return r.language
+}
+
+// SourceEntries returns the entry indexes in source dictionary
+// whose definitions contain the token with the given entry index
+func (r *ReverseDictionary) SourceEntries(entryIndex int) []int {
+	if entryIndex < 0 || entryIndex >= len(r.entries) {
+		return nil
+	}
+	list := make([]int, len(r.entries[entryIndex]))
+	for i, sourceIndex := range r.entries[entryIndex] {
+		list[i] = int(sourceIndex)
+	}
+	return list
+}
+
+// SourceResults returns the source entries of a result of the reverse
+// dictionary, with the score of res
+func (r *ReverseDictionary) SourceResults(res *common.SearchResultLow) []*common.SearchResultLow {
+	var results []*common.SearchResultLow
+	for _, sourceIndex := range r.SourceEntries(int(res.F_EntryIndex)) {
+		sourceRes := r.source.EntryByIndex(sourceIndex)
+		if sourceRes == nil {
+			continue
+		}
+		sourceRes.F_Score = res.F_Score
+		results = append(results, sourceRes)
+	}
+	return results
+}
+
+// CalcHash returns a hash of source index and language
+func (r *ReverseDictionary) CalcHash() ([]byte, error) {
+	sourceHash, err := r.source.CalcHash()
+	if err != nil {
+		return nil, err
+	}
+	hash := murmur3.New128()
+	_, _ = hash.Write(sourceHash)
+	_, _ = hash.Write([]byte("reverse:" + r.language))
+	return hash.Sum(nil), nil
+}
+
+// Unload does nothing, since the index has no files to be loaded from
+func (r *ReverseDictionary) Unload() bool {
+	return false
+}
@@ -0,0 +1,69 @@
+package stardict
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTokenizeText(t *testing.T) {
+	test := func(text string, lang string, expected ...string) {
+		t.Helper()
+		if tokens := TokenizeText(text, lang); !reflect.DeepEqual(tokens, expected) {
+			t.Errorf("%q: expected %q, got %q", text, expected, tokens)
+		}
+	}
+	test("a big-house, don't!", "en", "a", "big", "house", "don't")
+	test("l'eau froide", "fr", "l", "eau", "froide")
+	test("水果 fruit", "zh", "水", "果", "fruit")
+}
+
+func TestDefinitionLanguage(t *testing.T) {
+	test := func(options map[string]string, expected string) {
+		t.Helper()
+		if lang := newInfo(options).DefinitionLanguage(); lang != expected {
+			t.Errorf("%v: expected %q, got %q", options, expected, lang)
+		}
+	}
+	test(map[string]string{I_lang: "en-de"}, "de")
+	test(map[string]string{I_lang: "en-US"}, "en-US")
+	test(map[string]string{I_lang: "en-de", I_targetlang: "fa"}, "fa")
+	test(map[string]string{}, "")
+}
+
+func TestBuildReverse(t *testing.T) {
+	d := openTestDict(t, []testEntry{
+		{terms: []string{"apple"}, defi: "<b>Apfel</b> (die Frucht)"},
+		{terms: []string{"house"}, defi: "Haus, Gebäude"},
+		{terms: []string{"pear"}, defi: "Birne &amp; die Frucht"},
+	})
+	if err := d.Load(); err != nil {
+		t.Fatal(err)
+	}
+	rev, err := d.BuildReverse(&ReverseOptions{Language: "de", MaxEntriesPerToken: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := rev.EntryCount(); n != 4 {
+		t.Fatalf("expected 4 tokens, got %d", n)
+	}
+	results := rev.SearchExact("frucht", 0, 0)
+	if len(results) != 0 {
+		t.Fatalf("expected frequent token to be skipped, got %v", results)
+	}
+	results = rev.SearchExact("GEBÄUDE", 0, 0)
+	if len(results) != 1 {
+		t.Fatalf("expected 1 result, got %d", len(results))
+	}
+	items := results[0].Items()
+	if len(items) != 1 || string(items[0].Data) != "house" {
+		t.Fatalf("unexpected items: %v", items)
+	}
+	sources := rev.SourceResults(results[0])
+	if len(sources) != 1 || sources[0].F_Terms[0] != "house" {
+		t.Fatalf("unexpected source results: %v", sources)
+	}
+	results = rev.SearchStartWith("bir", 0, 0)
+	if len(results) != 1 || !reflect.DeepEqual(rev.SourceEntries(int(results[0].F_EntryIndex)), []int{2}) {
+		t.Fatalf("unexpected results: %v", results)
+	}
+}
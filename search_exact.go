@@ -2,7 +2,6 @@ package stardict
 
 import (
 	"fmt"
-	"strings"
 	"time"
 	"unicode/utf8"
 
@@ -10,17 +9,76 @@ import (
 	su "codeberg.org/ilius/go-dict-commons/search_utils"
 )
 
+// SearchExact finds entries with a term equal to query, and if the
+// dictionary has a Lemmatizer, entries with a term equal to a stem of
+// query (with a lower score), see SearchExactStems for the matched stems
 func (d *dictionaryImp) SearchExact(
 	query string,
 	workerCount int,
 	timeout time.Duration,
 ) []*common.SearchResultLow {
-	idx := d.idx
-	query = strings.ToLower(strings.TrimSpace(query))
+	stemResults := d.SearchExactStems(query, workerCount, timeout)
+	if stemResults == nil {
+		return nil
+	}
+	results := make([]*common.SearchResultLow, len(stemResults))
+	for i, res := range stemResults {
+		results[i] = res.SearchResultLow
+	}
+	return results
+}
 
+// SearchExactStems is like SearchExact, but annotates results with the
+// stem that matched, which is empty for entries that matched query itself
+func (d *dictionaryImp) SearchExactStems(
+	query string,
+	workerCount int,
+	timeout time.Duration,
+) []*StemResult {
+	idx, release, err := d.acquire()
+	if err != nil {
+		d.handleError(err)
+		return nil
+	}
+	defer release()
+	key := d.normalizeQuery(query)
+	var results []*StemResult
+	found := map[uint64]bool{}
+	for _, res := range d.searchExact(idx, key, 200, workerCount, timeout) {
+		found[res.F_EntryIndex] = true
+		results = append(results, &StemResult{SearchResultLow: res})
+	}
+	if d.lemmatizer == nil {
+		return results
+	}
+	for _, res := range d.searchStems(idx, query, key, workerCount, timeout) {
+		if found[res.F_EntryIndex] {
+			continue
+		}
+		found[res.F_EntryIndex] = true
+		results = append(results, res)
+	}
+	return results
+}
+
+// searchExact finds entries with a term equal to normalized query
+func (d *dictionaryImp) searchExact(
+	idx *Idx,
+	query string,
+	score uint8,
+	workerCount int,
+	timeout time.Duration,
+) []*common.SearchResultLow {
+	if d.terms != nil {
+		var results []*common.SearchResultLow
+		for _, entryIndex := range d.terms.lookup(query) {
+			results = append(results, d.newResult(idx.entries[entryIndex], entryIndex, score))
+		}
+		return results
+	}
 	prefix, _ := utf8.DecodeRuneInString(query)
 	if prefix == utf8.RuneError {
-		ErrorHandler(fmt.Errorf(
+		d.handleError(fmt.Errorf(
 			"RuneError from DecodeRuneInString for query: %#v",
 			query,
 		))
@@ -37,11 +95,8 @@ func (d *dictionaryImp) SearchExact(
 			var entryI int
 			for entryI = start; entryI < end; entryI++ {
 				entry = idx.entries[entryIndexes[entryI]]
-				for _, term := range entry.terms {
-					if strings.ToLower(term) == query {
-						results = append(results, d.newResult(entry, entryI, 200))
-						break
-					}
+				if entry.hasKey(query) {
+					results = append(results, d.newResult(entry, entryIndexes[entryI], score))
 				}
 			}
 			return results
@@ -6,6 +6,7 @@ import (
 
 	common "codeberg.org/ilius/go-dict-commons"
 	su "codeberg.org/ilius/go-dict-commons/search_utils"
+	"github.com/ilius/go-stardict/v2/fst"
 )
 
 // SearchFuzzy: run a fuzzy search with similarity scores
@@ -19,10 +20,15 @@ func (d *dictionaryImp) SearchFuzzy(
 	// 	return d.searchVeryShort(query)
 	// }
 
-	idx := d.idx
+	idx, release, err := d.acquire()
+	if err != nil {
+		d.handleError(err)
+		return nil
+	}
+	defer release()
 	const minScore = uint8(64)
 
-	query = strings.ToLower(strings.TrimSpace(query))
+	query = d.normalizeQuery(query)
 	queryWords := strings.Split(query, " ")
 	queryRunes := []rune(query)
 
@@ -43,8 +49,20 @@ func (d *dictionaryImp) SearchFuzzy(
 		queryWordCount++
 	}
 
-	prefix := queryMainWord[0]
-	entryIndexes := idx.byWordPrefix[prefix]
+	if len(queryMainWord) == 0 {
+		return nil
+	}
+	// candidates come from shared n-grams rather than the first letter,
+	// so a typo in the first letter can still be found
+	var entryIndexes []int
+	if d.terms != nil && minWordCount == 1 {
+		entryIndexes = d.terms.search(fst.NewLevenshtein(
+			query,
+			fuzzyMaxDistance(len(queryRunes)),
+		))
+	} else {
+		entryIndexes = d.fuzzyCandidates(idx, queryMainWord)
+	}
 
 	args := &su.ScoreFuzzyArgs{
 		Query:          query,
@@ -67,11 +85,11 @@ func (d *dictionaryImp) SearchFuzzy(
 			var entryI int
 			for entryI = start; entryI < end; entryI++ {
 				entry = idx.entries[entryIndexes[entryI]]
-				score = su.ScoreFuzzy(entry.terms, args, buff)
+				score = su.ScoreFuzzy(entry.matchTerms(), args, buff)
 				if score < minScore {
 					continue
 				}
-				results = append(results, d.newResult(entry, entryI, score))
+				results = append(results, d.newResult(entry, entryIndexes[entryI], score))
 			}
 			return results
 		},
@@ -2,22 +2,33 @@ package stardict
 
 import (
 	"regexp"
+	"regexp/syntax"
+	"sort"
+	"strings"
 	"time"
+	"unicode"
 
 	common "codeberg.org/ilius/go-dict-commons"
 	su "codeberg.org/ilius/go-dict-commons/search_utils"
 	"github.com/ilius/glob"
+	"github.com/ilius/go-stardict/v2/fst"
 )
 
+// searchPattern scores terms of the given entries with checkTerm,
+// nil entryIndexes means all entries
 func (d *dictionaryImp) searchPattern(
+	idx *Idx,
+	entryIndexes []int,
 	workerCount int,
 	timeout time.Duration,
 	checkTerm func(string) uint8,
 ) []*common.SearchResultLow {
-	idx := d.idx
 	const minScore = uint8(140)
 
 	N := len(idx.entries)
+	if entryIndexes != nil {
+		N = len(entryIndexes)
+	}
 	return su.RunWorkers(
 		N,
 		workerCount,
@@ -27,14 +38,17 @@ func (d *dictionaryImp) searchPattern(
 			var entry *IdxEntry
 			var score uint8
 			var entryI int
-			for entryI = start; entryI < end; entryI++ {
+			for i := start; i < end; i++ {
+				entryI = i
+				if entryIndexes != nil {
+					entryI = entryIndexes[i]
+				}
 				entry = idx.entries[entryI]
 				score = uint8(0)
 				for _, term := range entry.terms {
 					termScore := checkTerm(term)
 					if termScore > score {
 						score = termScore
-						break
 					}
 				}
 				if score < minScore {
@@ -47,16 +61,49 @@ func (d *dictionaryImp) searchPattern(
 	)
 }
 
+// RegexOptions are the options of SearchRegexWithOptions
+type RegexOptions struct {
+	// CaseInsensitive matches terms ignoring case, like (?i) flag.
+	// Unlike other search modes, regex matches raw terms, so it is
+	// case-sensitive by default.
+	CaseInsensitive bool
+}
+
+// SearchRegex finds terms fully matching query, case-sensitively
 func (d *dictionaryImp) SearchRegex(
 	query string,
 	workerCount int,
 	timeout time.Duration,
 ) ([]*common.SearchResultLow, error) {
-	re, err := regexp.Compile("^" + query + "$")
+	return d.SearchRegexWithOptions(query, nil, workerCount, timeout)
+}
+
+// SearchRegexWithOptions is like SearchRegex with options, nil opts
+// means default options
+func (d *dictionaryImp) SearchRegexWithOptions(
+	query string,
+	opts *RegexOptions,
+	workerCount int,
+	timeout time.Duration,
+) ([]*common.SearchResultLow, error) {
+	pattern := "^(?:" + query + ")$"
+	if opts != nil && opts.CaseInsensitive {
+		pattern = "(?i)" + pattern
+	}
+	re, err := regexp.Compile(pattern)
 	if err != nil {
 		return nil, err
 	}
-	return d.searchPattern(workerCount, timeout, func(term string) uint8 {
+	idx, release, err := d.acquire()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	entryIndexes := d.regexCandidates(pattern)
+	if entryIndexes == nil {
+		entryIndexes = d.prefixCandidates(idx, regexLiteralPrefix(pattern))
+	}
+	return d.searchPattern(idx, entryIndexes, workerCount, timeout, func(term string) uint8 {
 		if !re.MatchString(term) {
 			return 0
 		}
@@ -67,17 +114,81 @@ func (d *dictionaryImp) SearchRegex(
 	}), nil
 }
 
+// GlobDialect selects the syntax of glob patterns
+type GlobDialect uint8
+
+const (
+	// GlobStandard supports `*`, `?`, character classes like `[a-z]`
+	// and `[!a]`, alternatives like `{a,b}` and `\` escapes.
+	// `*` matches any sequence of characters, including spaces.
+	GlobStandard GlobDialect = iota
+	// GlobWords is like GlobStandard, but `*` and `?` do not match
+	// space, so they stay within one word, while `**` matches across words
+	GlobWords
+	// GlobSimple only supports `*` and `?`, all other characters
+	// are matched literally
+	GlobSimple
+)
+
+// GlobOptions are the options of SearchGlobWithOptions
+type GlobOptions struct {
+	// CaseSensitive matches raw terms, instead of normalized terms
+	// (lowercased, or normalized by the Normalizer of dictionary)
+	CaseSensitive bool
+
+	// IgnoreAccents removes diacritics from terms and pattern,
+	// so "cafe" matches "café"
+	IgnoreAccents bool
+
+	Dialect GlobDialect
+}
+
+// SearchGlob finds terms fully matching the glob pattern query,
+// case-insensitively
 func (d *dictionaryImp) SearchGlob(
 	query string,
 	workerCount int,
 	timeout time.Duration,
 ) ([]*common.SearchResultLow, error) {
-	pattern, err := glob.Compile(query)
+	return d.SearchGlobWithOptions(query, nil, workerCount, timeout)
+}
+
+// SearchGlobWithOptions is like SearchGlob with options, nil opts
+// means default options
+func (d *dictionaryImp) SearchGlobWithOptions(
+	query string,
+	opts *GlobOptions,
+	workerCount int,
+	timeout time.Duration,
+) ([]*common.SearchResultLow, error) {
+	if opts == nil {
+		opts = &GlobOptions{}
+	}
+	fold := func(str string) string {
+		if !opts.CaseSensitive {
+			str = d.normalizer.Normalize(str)
+		}
+		if opts.IgnoreAccents {
+			str = stripAccents(str)
+		}
+		return str
+	}
+	pattern, err := compileGlob(fold(query), opts.Dialect)
+	if err != nil {
+		return nil, err
+	}
+	idx, release, err := d.acquire()
 	if err != nil {
 		return nil, err
 	}
-	return d.searchPattern(workerCount, timeout, func(term string) uint8 {
-		if !pattern.Match(term) {
+	defer release()
+	var entryIndexes []int
+	// completion keys keep diacritics, unless normalizer strips them
+	if !opts.IgnoreAccents || d.normalizer != nil && d.normalizer.StripAccents {
+		entryIndexes = d.prefixCandidates(idx, globLiteralPrefix(query, opts.Dialect))
+	}
+	return d.searchPattern(idx, entryIndexes, workerCount, timeout, func(term string) uint8 {
+		if !pattern.Match(fold(term)) {
 			return 0
 		}
 		if len(term) < 20 {
@@ -86,3 +197,192 @@ func (d *dictionaryImp) SearchGlob(
 		return 180
 	}), nil
 }
+
+// compileGlob compiles a glob pattern of the given dialect
+func compileGlob(pattern string, dialect GlobDialect) (glob.Glob, error) {
+	switch dialect {
+	case GlobWords:
+		return glob.Compile(pattern, ' ')
+	case GlobSimple:
+		var sb strings.Builder
+		for _, c := range pattern {
+			if c == '*' || c == '?' {
+				sb.WriteRune(c)
+				continue
+			}
+			sb.WriteString(glob.QuoteMeta(string(c)))
+		}
+		return glob.Compile(sb.String())
+	}
+	return glob.Compile(pattern)
+}
+
+// globLiteralPrefix returns the literal part of a glob pattern
+// before the first special character
+func globLiteralPrefix(pattern string, dialect GlobDialect) string {
+	var sb strings.Builder
+	escaped := false
+	for _, c := range pattern {
+		if escaped {
+			sb.WriteRune(c)
+			escaped = false
+			continue
+		}
+		switch c {
+		case '*', '?':
+			return sb.String()
+		case '\\', '[', ']', '{', '}', ',':
+			if dialect != GlobSimple {
+				if c != '\\' {
+					return sb.String()
+				}
+				escaped = true
+				continue
+			}
+		}
+		sb.WriteRune(c)
+	}
+	return sb.String()
+}
+
+// regexCandidates returns the entries that may match pattern using the
+// FST index, or nil if all entries must be checked. Lowercase terms are
+// matched with (?i), which gives a superset of matches only when the
+// index is built without a Normalizer, and every character class of
+// pattern contains all cases of its characters (see isFoldClosed).
+func (d *dictionaryImp) regexCandidates(pattern string) []int {
+	if d.terms == nil || d.normalizer != nil {
+		return nil
+	}
+	re, err := syntax.Parse(pattern, syntax.Perl)
+	if err != nil {
+		return nil
+	}
+	if !isFoldClosed(re) {
+		return nil
+	}
+	// the automaton matches whole terms, unlike MatchString
+	if !isAnchoredRegex(re) {
+		pattern = "(?s:.*)(?:" + pattern + ")(?s:.*)"
+	}
+	automaton, err := fst.NewRegexp("(?i)" + pattern)
+	if err != nil {
+		return nil
+	}
+	return d.terms.search(automaton)
+}
+
+// maxFoldCheckRunes limits the size of character classes checked by
+// isFoldClosed, larger ones (usually negated classes) are rejected
+const maxFoldCheckRunes = 4096
+
+// isFoldClosed returns true if every character class of re contains the
+// other cases of its characters. For example [^a-z] matches "A" but not
+// "a", so lowercase terms do not match it.
+func isFoldClosed(re *syntax.Regexp) bool {
+	if re.Op == syntax.OpCharClass {
+		count := 0
+		for i := 0; i+1 < len(re.Rune); i += 2 {
+			lo, hi := re.Rune[i], re.Rune[i+1]
+			count += int(hi-lo) + 1
+			if count > maxFoldCheckRunes {
+				return false
+			}
+			for c := lo; c <= hi; c++ {
+				for f := unicode.SimpleFold(c); f != c; f = unicode.SimpleFold(f) {
+					if !classContains(re.Rune, f) {
+						return false
+					}
+				}
+			}
+		}
+	}
+	for _, sub := range re.Sub {
+		if !isFoldClosed(sub) {
+			return false
+		}
+	}
+	return true
+}
+
+// classContains returns true if c is in the ranges of a character class
+func classContains(ranges []rune, c rune) bool {
+	for i := 0; i+1 < len(ranges); i += 2 {
+		if c >= ranges[i] && c <= ranges[i+1] {
+			return true
+		}
+	}
+	return false
+}
+
+// isAnchoredRegex returns true if re can only match a whole string
+func isAnchoredRegex(re *syntax.Regexp) bool {
+	if re.Op != syntax.OpConcat || len(re.Sub) < 2 {
+		return false
+	}
+	return re.Sub[0].Op == syntax.OpBeginText && re.Sub[len(re.Sub)-1].Op == syntax.OpEndText
+}
+
+// regexLiteralPrefix returns the literal that every match of pattern
+// starts with, or empty string if there is none. Case-insensitive
+// literals are lowercased.
+func regexLiteralPrefix(pattern string) string {
+	re, err := syntax.Parse(pattern, syntax.Perl)
+	if err != nil {
+		return ""
+	}
+	var sb strings.Builder
+	// walk returns false when it reaches a non-literal part
+	var walk func(re *syntax.Regexp) bool
+	walk = func(re *syntax.Regexp) bool {
+		switch re.Op {
+		case syntax.OpBeginText, syntax.OpBeginLine, syntax.OpEmptyMatch:
+			return true
+		case syntax.OpLiteral:
+			for _, r := range re.Rune {
+				if re.Flags&syntax.FoldCase != 0 {
+					r = unicode.ToLower(r)
+				}
+				sb.WriteRune(r)
+			}
+			return true
+		case syntax.OpCapture:
+			return walk(re.Sub[0])
+		case syntax.OpPlus:
+			// the first repetition is required
+			walk(re.Sub[0])
+			return false
+		case syntax.OpConcat:
+			for _, sub := range re.Sub {
+				if !walk(sub) {
+					return false
+				}
+			}
+			return true
+		}
+		return false
+	}
+	walk(re.Simplify())
+	return sb.String()
+}
+
+// prefixCandidates returns the sorted entries having a term that starts
+// with prefix (compared in normalized form), or nil for empty prefix
+func (d *dictionaryImp) prefixCandidates(idx *Idx, prefix string) []int {
+	if prefix == "" {
+		return nil
+	}
+	index := d.completionIndex(idx)
+	start, end := index.prefixRange(d.normalizer.Normalize(prefix))
+	found := make(map[int32]bool, end-start)
+	entryIndexes := make([]int, 0, end-start)
+	for _, item := range index.items[start:end] {
+		if found[item.entryIndex] {
+			continue
+		}
+		found[item.entryIndex] = true
+		entryIndexes = append(entryIndexes, int(item.entryIndex))
+	}
+	sort.Ints(entryIndexes)
+	return entryIndexes
+}
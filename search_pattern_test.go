@@ -0,0 +1,116 @@
+package stardict
+
+import (
+	"testing"
+)
+
+func TestRegexLiteralPrefix(t *testing.T) {
+	tests := map[string]string{
+		"^(?:abc.*)$":     "abc",
+		"^(?:(ab)c+)$":    "abc",
+		"^(?:a|b)$":       "",
+		"(?i)^(?:Hello)$": "hello",
+		"^(?:x?y)$":       "",
+	}
+	for pattern, expected := range tests {
+		if prefix := regexLiteralPrefix(pattern); prefix != expected {
+			t.Errorf("%s: expected %q, got %q", pattern, expected, prefix)
+		}
+	}
+}
+
+func TestSearchRegex(t *testing.T) {
+	d := openTestDict(t, append([]testEntry{
+		{terms: []string{"Apple pie"}, defi: "a dessert"},
+		{terms: []string{"apricot jam and other things", "apjam"}, defi: "a jam"},
+	}, testEntries...))
+	if err := d.Load(); err != nil {
+		t.Fatal(err)
+	}
+	results, err := d.SearchRegex("Apple.*", 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].F_Terms[0] != "Apple pie" {
+		t.Fatalf("unexpected case-sensitive results: %v", results)
+	}
+	results, err = d.SearchRegexWithOptions("Apple.*", &RegexOptions{CaseInsensitive: true}, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("expected 2 case-insensitive results, got %d", len(results))
+	}
+	// alternatives are anchored as a whole
+	results, _ = d.SearchRegex("apple|banana", 0, 0)
+	if len(results) != 2 {
+		t.Fatalf("expected 2 results for alternation, got %d", len(results))
+	}
+	// the best scoring synonym is used
+	results, _ = d.SearchRegex("ap.*", 0, 0)
+	for _, res := range results {
+		if res.F_Terms[0] == "apricot jam and other things" && res.F_Score != 200-5 {
+			t.Fatalf("expected score of synonym apjam, got %d", res.F_Score)
+		}
+	}
+}
+
+func TestGlobLiteralPrefix(t *testing.T) {
+	test := func(pattern string, dialect GlobDialect, expected string) {
+		t.Helper()
+		if prefix := globLiteralPrefix(pattern, dialect); prefix != expected {
+			t.Errorf("%s: expected %q, got %q", pattern, expected, prefix)
+		}
+	}
+	test("app*", GlobStandard, "app")
+	test("a\\*b?", GlobStandard, "a*b")
+	test("ap[pq]le", GlobStandard, "ap")
+	test("{a,b}*", GlobStandard, "")
+	test("ap[pq]le*", GlobSimple, "ap[pq]le")
+}
+
+func TestSearchGlob(t *testing.T) {
+	d := openTestDict(t, append([]testEntry{
+		{terms: []string{"Apple pie"}, defi: "a dessert"},
+		{terms: []string{"Café au lait"}, defi: "a drink"},
+		{terms: []string{"apricot jam and other things", "apjam"}, defi: "a jam"},
+	}, testEntries...))
+	if err := d.Load(); err != nil {
+		t.Fatal(err)
+	}
+	count := func(query string, opts *GlobOptions) int {
+		t.Helper()
+		results, err := d.SearchGlobWithOptions(query, opts, 0, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(results)
+	}
+	if n := count("Apple*", nil); n != 2 {
+		t.Fatalf("expected 2 case-insensitive results, got %d", n)
+	}
+	if n := count("Apple*", &GlobOptions{CaseSensitive: true}); n != 1 {
+		t.Fatalf("expected 1 case-sensitive result, got %d", n)
+	}
+	if n := count("cafe *", nil); n != 0 {
+		t.Fatalf("expected no result without IgnoreAccents, got %d", n)
+	}
+	if n := count("cafe *", &GlobOptions{IgnoreAccents: true}); n != 1 {
+		t.Fatalf("expected 1 result with IgnoreAccents, got %d", n)
+	}
+	if n := count("apple*", &GlobOptions{Dialect: GlobWords}); n != 1 {
+		t.Fatalf("expected 1 result for single word, got %d", n)
+	}
+	if n := count("apple**", &GlobOptions{Dialect: GlobWords}); n != 2 {
+		t.Fatalf("expected 2 results across words, got %d", n)
+	}
+	if n := count("[a]pple", &GlobOptions{Dialect: GlobSimple}); n != 0 {
+		t.Fatalf("expected no result for literal brackets, got %d", n)
+	}
+	results, _ := d.SearchGlob("ap*", 0, 0)
+	for _, res := range results {
+		if res.F_Terms[0] == "apricot jam and other things" && res.F_Score != 200-5 {
+			t.Fatalf("expected score of synonym apjam, got %d", res.F_Score)
+		}
+	}
+}
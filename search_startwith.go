@@ -2,12 +2,12 @@ package stardict
 
 import (
 	"fmt"
-	"strings"
 	"time"
 	"unicode/utf8"
 
 	common "codeberg.org/ilius/go-dict-commons"
 	su "codeberg.org/ilius/go-dict-commons/search_utils"
+	"github.com/ilius/go-stardict/v2/fst"
 )
 
 func (d *dictionaryImp) SearchStartWith(
@@ -15,20 +15,30 @@ func (d *dictionaryImp) SearchStartWith(
 	workerCount int,
 	timeout time.Duration,
 ) []*common.SearchResultLow {
-	idx := d.idx
+	idx, release, err := d.acquire()
+	if err != nil {
+		d.handleError(err)
+		return nil
+	}
+	defer release()
 	const minScore = uint8(140)
 
-	query = strings.ToLower(strings.TrimSpace(query))
+	query = d.normalizeQuery(query)
 
-	prefix, _ := utf8.DecodeRuneInString(query)
-	if prefix == utf8.RuneError {
-		ErrorHandler(fmt.Errorf(
-			"RuneError from DecodeRuneInString for query: %#v",
-			query,
-		))
-		return nil
+	var entryIndexes []int
+	if d.terms != nil {
+		entryIndexes = d.terms.search(fst.Prefix(query))
+	} else {
+		prefix, _ := utf8.DecodeRuneInString(query)
+		if prefix == utf8.RuneError {
+			d.handleError(fmt.Errorf(
+				"RuneError from DecodeRuneInString for query: %#v",
+				query,
+			))
+			return nil
+		}
+		entryIndexes = idx.byWordPrefix[prefix]
 	}
-	entryIndexes := idx.byWordPrefix[prefix]
 	return su.RunWorkers(
 		len(entryIndexes),
 		workerCount,
@@ -40,11 +50,11 @@ func (d *dictionaryImp) SearchStartWith(
 			var entryI int
 			for entryI = start; entryI < end; entryI++ {
 				entry = idx.entries[entryIndexes[entryI]]
-				score = su.ScoreStartsWith(entry.terms, query)
+				score = su.ScoreStartsWith(entry.matchTerms(), query)
 				if score < minScore {
 					continue
 				}
-				results = append(results, d.newResult(entry, entryI, score))
+				results = append(results, d.newResult(entry, entryIndexes[entryI], score))
 			}
 			return results
 		},
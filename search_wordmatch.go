@@ -1,9 +1,9 @@
 package stardict
 
 import (
-	"log/slog"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	common "codeberg.org/ilius/go-dict-commons"
 	su "codeberg.org/ilius/go-dict-commons/search_utils"
@@ -14,12 +14,21 @@ func (d *dictionaryImp) SearchWordMatch(
 	workerCount int,
 	timeout time.Duration,
 ) []*common.SearchResultLow {
-	idx := d.idx
+	idx, release, err := d.acquire()
+	if err != nil {
+		d.handleError(err)
+		return nil
+	}
+	defer release()
 	const minScore = uint8(140)
 
-	query = strings.ToLower(strings.TrimSpace(query))
+	query = d.normalizeQuery(query)
 
-	prefix := []rune(strings.Split(query, " ")[0])[0]
+	firstWord, _, _ := strings.Cut(query, " ")
+	if firstWord == "" {
+		return nil
+	}
+	prefix, _ := utf8.DecodeRuneInString(firstWord)
 	entryIndexes := idx.byWordPrefix[prefix]
 
 	t1 := time.Now()
@@ -36,11 +45,11 @@ func (d *dictionaryImp) SearchWordMatch(
 			var entryI int
 			for entryI = start; entryI < end; entryI++ {
 				entry = idx.entries[entryIndexes[entryI]]
-				score = su.ScoreWordMatch(entry.terms, query)
+				score = su.ScoreWordMatch(entry.matchTerms(), query)
 				if score < minScore {
 					continue
 				}
-				results = append(results, d.newResult(entry, entryI, score))
+				results = append(results, d.newResult(entry, entryIndexes[entryI], score))
 			}
 			return results
 		},
@@ -48,7 +57,7 @@ func (d *dictionaryImp) SearchWordMatch(
 
 	dt := time.Since(t1)
 	if dt > time.Millisecond {
-		slog.Debug("SearchWordMatch index loop", "dt", dt, "query", query, "dictName", d.DictName())
+		d.log().Debug("SearchWordMatch index loop", "dt", dt, "query", query, "dictName", d.DictName())
 	}
 	return results
 }
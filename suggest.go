@@ -0,0 +1,237 @@
+package stardict
+
+import (
+	"sort"
+	"strings"
+)
+
+// Suggestion is a headword that is close to a (probably misspelled) query
+type Suggestion struct {
+	Term       string
+	Distance   int
+	EntryIndex int
+}
+
+// suggestKey is a normalized term with the entries it belongs to
+type suggestKey struct {
+	key     []rune
+	term    string
+	entries []int
+}
+
+// suggestIndex has the distinct keys of index grouped by their length
+// in runes, so only keys with length within query distance are compared
+type suggestIndex struct {
+	byLength [][]*suggestKey
+}
+
+func buildSuggestIndex(idx *Idx) *suggestIndex {
+	index := &suggestIndex{}
+	byKey := map[string]*suggestKey{}
+	for entryIndex, entry := range idx.entries {
+		for termI, term := range entry.terms {
+			key := strings.ToLower(term)
+			if entry.keys != nil {
+				key = entry.keys[termI]
+			}
+			if sk := byKey[key]; sk != nil {
+				if sk.entries[len(sk.entries)-1] != entryIndex {
+					sk.entries = append(sk.entries, entryIndex)
+				}
+				continue
+			}
+			sk := &suggestKey{key: []rune(key), term: term, entries: []int{entryIndex}}
+			byKey[key] = sk
+			for len(index.byLength) <= len(sk.key) {
+				index.byLength = append(index.byLength, nil)
+			}
+			index.byLength[len(sk.key)] = append(index.byLength[len(sk.key)], sk)
+		}
+	}
+	return index
+}
+
+func (index *suggestIndex) search(query []rune, maxDistance int, visit func(sk *suggestKey, dist int)) {
+	var dl dlDistance
+	queryHist := runeHistogram(query)
+	minLen := max(0, len(query)-maxDistance)
+	maxLen := min(len(query)+maxDistance, len(index.byLength)-1)
+	for n := minLen; n <= maxLen; n++ {
+		for _, sk := range index.byLength[n] {
+			if histogramDistance(&queryHist, sk.key) > maxDistance {
+				continue
+			}
+			dist := dl.bounded(query, sk.key, maxDistance)
+			if dist <= maxDistance {
+				visit(sk, dist)
+			}
+		}
+	}
+}
+
+// runeHistogram counts runes of str in 64 buckets
+func runeHistogram(str []rune) [64]int16 {
+	var hist [64]int16
+	for _, r := range str {
+		hist[r&63]++
+	}
+	return hist
+}
+
+// histogramDistance returns a lower bound of edit distance between the
+// string of hist and str, since each edit adds and removes at most one
+// rune. It is much cheaper than the distance itself.
+func histogramDistance(hist *[64]int16, str []rune) int {
+	diff := *hist
+	for _, r := range str {
+		diff[r&63]--
+	}
+	added, removed := 0, 0
+	for _, count := range diff {
+		if count > 0 {
+			removed += int(count)
+		} else {
+			added -= int(count)
+		}
+	}
+	return max(added, removed)
+}
+
+// Suggest returns up to limit headwords (including synonyms) whose
+// Damerau-Levenshtein distance to query is at most maxDistance,
+// sorted by distance. Unlike search methods, the first letter of
+// query does not need to be correct.
+// The suggestion index is built on first call.
+func (d *dictionaryImp) Suggest(query string, maxDistance int, limit int) []*Suggestion {
+	idx, release, err := d.acquire()
+	if err != nil {
+		d.handleError(err)
+		return nil
+	}
+	defer release()
+	d.suggestOnce.Do(func() {
+		d.suggestIndex = buildSuggestIndex(idx)
+	})
+	var suggestions []*Suggestion
+	d.suggestIndex.search(
+		[]rune(d.normalizeQuery(query)),
+		maxDistance,
+		func(sk *suggestKey, dist int) {
+			for _, entryIndex := range sk.entries {
+				suggestions = append(suggestions, &Suggestion{
+					Term:       sk.term,
+					Distance:   dist,
+					EntryIndex: entryIndex,
+				})
+			}
+		},
+	)
+	sort.SliceStable(suggestions, func(i, j int) bool {
+		if suggestions[i].Distance != suggestions[j].Distance {
+			return suggestions[i].Distance < suggestions[j].Distance
+		}
+		return suggestions[i].Term < suggestions[j].Term
+	})
+	if limit > 0 && len(suggestions) > limit {
+		suggestions = suggestions[:limit]
+	}
+	return suggestions
+}
+
+// dlDistance computes Damerau-Levenshtein distances, reusing its
+// buffers between calls
+type dlDistance struct {
+	matrix []int
+	// lastRow is the last row in which each rune of a was seen
+	lastRow []runeRow
+}
+
+type runeRow struct {
+	r   rune
+	row int
+}
+
+func (dl *dlDistance) findRow(r rune) int {
+	for _, rr := range dl.lastRow {
+		if rr.r == r {
+			return rr.row
+		}
+	}
+	return 0
+}
+
+func (dl *dlDistance) setRow(r rune, row int) {
+	for i := range dl.lastRow {
+		if dl.lastRow[i].r == r {
+			dl.lastRow[i].row = row
+			return
+		}
+	}
+	dl.lastRow = append(dl.lastRow, runeRow{r: r, row: row})
+}
+
+// bounded returns the edit distance between a and b, counting insertion,
+// deletion, substitution and transposition of adjacent runes, or limit+1
+// if it is more than limit. Unlike optimal string alignment distance,
+// it is a metric.
+func (dl *dlDistance) bounded(a []rune, b []rune, limit int) int {
+	lenA, lenB := len(a), len(b)
+	if lenA-lenB > limit || lenB-lenA > limit {
+		return limit + 1
+	}
+	if lenA == 0 || lenB == 0 {
+		return max(lenA, lenB)
+	}
+	maxDist := lenA + lenB
+	width := lenB + 2
+	// matrix has an extra first row and column filled with maxDist
+	size := (lenA + 2) * width
+	if cap(dl.matrix) < size {
+		dl.matrix = make([]int, size)
+	}
+	matrix := dl.matrix[:size]
+	matrix[0] = maxDist
+	for i := 0; i <= lenA; i++ {
+		matrix[(i+1)*width] = maxDist
+		matrix[(i+1)*width+1] = i
+	}
+	for j := 0; j <= lenB; j++ {
+		matrix[j+1] = maxDist
+		matrix[width+j+1] = j
+	}
+	dl.lastRow = dl.lastRow[:0]
+	for i := 1; i <= lenA; i++ {
+		lastCol := 0
+		// the minimum of a row never decreases in next rows
+		rowMin := i
+		for j := 1; j <= lenB; j++ {
+			i1 := dl.findRow(b[j-1])
+			j1 := lastCol
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+				lastCol = j
+			}
+			value := min(
+				matrix[i*width+j]+cost,
+				matrix[(i+1)*width+j]+1,
+				matrix[i*width+j+1]+1,
+				matrix[i1*width+j1]+(i-i1-1)+1+(j-j1-1),
+			)
+			matrix[(i+1)*width+j+1] = value
+			rowMin = min(rowMin, value)
+		}
+		if rowMin > limit {
+			return limit + 1
+		}
+		dl.setRow(a[i-1], i)
+	}
+	return min(matrix[(lenA+1)*width+lenB+1], limit+1)
+}
+
+// damerauLevenshtein returns the edit distance between a and b,
+// see dlDistance.bounded
+func damerauLevenshtein(a []rune, b []rune) int {
+	var dl dlDistance
+	return dl.bounded(a, b, len(a)+len(b))
+}
@@ -0,0 +1,102 @@
+package stardict
+
+import (
+	"io"
+	"log/slog"
+	"math/rand"
+	"testing"
+)
+
+func Test_damerauLevenshtein(t *testing.T) {
+	test := func(a string, b string, expected int) {
+		t.Helper()
+		actual := damerauLevenshtein([]rune(a), []rune(b))
+		if actual != expected {
+			t.Errorf("distance(%#v, %#v): expected %d, got %d", a, b, expected, actual)
+		}
+	}
+	test("", "", 0)
+	test("abc", "", 3)
+	test("physics", "physics", 0)
+	test("phsyics", "physics", 1)
+	test("fhysics", "physics", 1)
+	test("ca", "abc", 2)
+	test("kitten", "sitting", 3)
+	test("café", "cafe", 1)
+}
+
+func TestBoundedDistance(t *testing.T) {
+	var dl dlDistance
+	test := func(a string, b string, limit int, expected int) {
+		t.Helper()
+		actual := dl.bounded([]rune(a), []rune(b), limit)
+		if actual != expected {
+			t.Errorf("distance(%#v, %#v, %d): expected %d, got %d", a, b, limit, expected, actual)
+		}
+	}
+	test("kitten", "sitting", 3, 3)
+	test("kitten", "sitting", 2, 3)
+	test("phsyics", "physics", 1, 1)
+	test("abcdef", "ab", 2, 3)
+	test("abcdef", "uvwxyz", 1, 2)
+	test("ca", "abc", 2, 2)
+}
+
+func TestSuggest(t *testing.T) {
+	d := openTestDict(t, []testEntry{
+		{terms: []string{"chemistry"}, defi: "-"},
+		{terms: []string{"physician", "doctor"}, defi: "-"},
+		{terms: []string{"physics"}, defi: "-"},
+	})
+	if err := d.Load(); err != nil {
+		t.Fatal(err)
+	}
+	suggestions := d.Suggest("fhsyics", 2, 5)
+	if len(suggestions) != 1 || suggestions[0].Term != "physics" || suggestions[0].EntryIndex != 2 {
+		t.Fatalf("unexpected suggestions: %+v", suggestions)
+	}
+	suggestions = d.Suggest("docter", 1, 5)
+	if len(suggestions) != 1 || suggestions[0].Term != "doctor" || suggestions[0].EntryIndex != 1 {
+		t.Fatalf("unexpected suggestions: %+v", suggestions)
+	}
+}
+
+func BenchmarkSuggest(b *testing.B) {
+	rnd := rand.New(rand.NewSource(1))
+	seen := map[string]bool{}
+	var entries []testEntry
+	for len(entries) < 300_000 {
+		word := make([]byte, 4+rnd.Intn(8))
+		for i := range word {
+			word[i] = byte('a' + rnd.Intn(26))
+		}
+		if seen[string(word)] {
+			continue
+		}
+		seen[string(word)] = true
+		entries = append(entries, testEntry{terms: []string{string(word)}, defi: "-"})
+	}
+	dir := b.TempDir()
+	writeTestDict(&testing.T{}, dir, "bench", entries)
+	d, err := NewDictionary(dir, "bench")
+	if err != nil {
+		b.Fatal(err)
+	}
+	d.SetLogger(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err := d.Load(); err != nil {
+		b.Fatal(err)
+	}
+	b.Run("build", func(b *testing.B) {
+		idx := d.idx
+		for range b.N {
+			buildSuggestIndex(idx)
+		}
+	})
+	d.Suggest("warmup", 2, 10)
+	queries := []string{"physics", "dictionary", "abcd", "qwertyui"}
+	b.Run("query", func(b *testing.B) {
+		for i := range b.N {
+			d.Suggest(queries[i%len(queries)], 2, 10)
+		}
+	})
+}
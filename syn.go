@@ -20,26 +20,38 @@ func readSyn(idx *Idx, synPath string, wordPrefixMap WordPrefixMap) error {
 		// Python: pos = data.find("\x00", beg)
 		offset := bytes.Index(data[beg:], []byte{0})
 		if offset < 0 {
-			return fmt.Errorf("synonym file is corrupted")
+			return &FormatError{
+				File:   synPath,
+				Offset: int64(beg),
+				Err:    fmt.Errorf("%w: missing NUL after term", ErrCorruptSyn),
+			}
 		}
 		pos = offset + beg
 		b_alt := data[beg:pos]
 		pos += 1
 		if pos+4 > len(data) {
-			return fmt.Errorf("synonym file is corrupted")
+			return &FormatError{
+				File:   synPath,
+				Offset: int64(beg),
+				Err:    fmt.Errorf("%w: missing entry index", ErrCorruptSyn),
+			}
 		}
 		termIndex := int(binary.BigEndian.Uint32(data[pos : pos+4]))
 		pos += 4
 		if termIndex >= len(idx.entries) {
-			return fmt.Errorf(
-				"corrupted synonym file. Word %#v references invalid item",
-				string(b_alt),
-			)
+			return &FormatError{
+				File:   synPath,
+				Offset: int64(beg),
+				Err: fmt.Errorf(
+					"%w: word %#v references invalid item %d",
+					ErrCorruptSyn, string(b_alt), termIndex,
+				),
+			}
 		}
 		alt := string(b_alt)
 		entry := idx.entries[termIndex]
 		entry.terms = append(entry.terms, alt)
-		wordPrefixMap.Add(alt, termIndex)
+		idx.addKey(wordPrefixMap, entry, alt, termIndex)
 	}
 	return nil
 }
@@ -0,0 +1,257 @@
+package stardict
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ilius/go-stardict/v2/fst"
+)
+
+// termIndexCacheVersion must be incremented whenever the layout of
+// term index cache files changes
+const termIndexCacheVersion = 1
+
+const termIndexCacheExt = ".fstcache"
+
+var termIndexCacheMagic = []byte("GOSDFSC\x00")
+
+// termIndex maps every normalized term and synonym to its entries using
+// an FST, it is used instead of byWordPrefix buckets for exact, prefix,
+// fuzzy and regex searches
+type termIndex struct {
+	fst *fst.FST
+	// entries of the i-th key are postings[offsets[i]:offsets[i+1]],
+	// the value of each key in fst is i
+	offsets  []uint32
+	postings []int32
+}
+
+type termPosting struct {
+	key        string
+	entryIndex int32
+}
+
+func buildTermIndex(idx *Idx) (*termIndex, error) {
+	list := make([]termPosting, 0, len(idx.entries))
+	for entryIndex, entry := range idx.entries {
+		for i, term := range entry.terms {
+			key := strings.ToLower(term)
+			if entry.keys != nil {
+				key = entry.keys[i]
+			}
+			list = append(list, termPosting{key: key, entryIndex: int32(entryIndex)})
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].key != list[j].key {
+			return list[i].key < list[j].key
+		}
+		return list[i].entryIndex < list[j].entryIndex
+	})
+	ti := &termIndex{
+		postings: make([]int32, 0, len(list)),
+	}
+	builder := fst.NewBuilder()
+	for i, item := range list {
+		if i > 0 && item.key == list[i-1].key {
+			if item.entryIndex != list[i-1].entryIndex {
+				ti.postings = append(ti.postings, item.entryIndex)
+			}
+			continue
+		}
+		err := builder.Insert(item.key, uint64(len(ti.offsets)))
+		if err != nil {
+			return nil, err
+		}
+		ti.offsets = append(ti.offsets, uint32(len(ti.postings)))
+		ti.postings = append(ti.postings, item.entryIndex)
+	}
+	ti.offsets = append(ti.offsets, uint32(len(ti.postings)))
+	f, err := builder.Finish()
+	if err != nil {
+		return nil, err
+	}
+	ti.fst = f
+	return ti, nil
+}
+
+// memorySize returns the size of term index in bytes
+func (ti *termIndex) memorySize() int64 {
+	return int64(ti.fst.Size() + 4*len(ti.offsets) + 4*len(ti.postings))
+}
+
+func (ti *termIndex) keyEntries(value uint64) []int32 {
+	if value+1 >= uint64(len(ti.offsets)) {
+		return nil
+	}
+	return ti.postings[ti.offsets[value]:ti.offsets[value+1]]
+}
+
+// lookup returns the entries with a term equal to key
+func (ti *termIndex) lookup(key string) []int {
+	value, ok := ti.fst.Get(key)
+	if !ok {
+		return nil
+	}
+	entries := ti.keyEntries(value)
+	result := make([]int, len(entries))
+	for i, entryIndex := range entries {
+		result[i] = int(entryIndex)
+	}
+	return result
+}
+
+// search returns the sorted entries with a term accepted by automaton
+func (ti *termIndex) search(automaton fst.Automaton) []int {
+	found := map[int32]bool{}
+	ti.fst.Search(automaton, func(_ []byte, value uint64) bool {
+		for _, entryIndex := range ti.keyEntries(value) {
+			found[entryIndex] = true
+		}
+		return true
+	})
+	result := make([]int, 0, len(found))
+	for entryIndex := range found {
+		result = append(result, int(entryIndex))
+	}
+	sort.Ints(result)
+	return result
+}
+
+// fuzzyMaxDistance returns the edit distance used for finding fuzzy
+// candidates of a query with n runes
+func fuzzyMaxDistance(n int) int {
+	switch {
+	case n <= 2:
+		return 0
+	case n <= 5:
+		return 1
+	}
+	return 2
+}
+
+// SetFSTIndex enables building an FST index of terms at Load (or reading
+// it from IndexCacheDir), which is used by SearchExact, SearchStartWith,
+// SearchFuzzy and SearchRegex instead of scanning entries.
+// With the FST index, SearchFuzzy finds terms within a small edit
+// distance of the whole query rather than terms sharing n-grams with it.
+// It must be called before Load.
+func (d *dictionaryImp) SetFSTIndex(enable bool) {
+	d.loadMu.Lock()
+	defer d.loadMu.Unlock()
+	d.useFST = enable
+}
+
+// loadTermIndex reads the term index from cache, or builds it
+func (d *dictionaryImp) loadTermIndex(idx *Idx) (*termIndex, error) {
+	if IndexCacheDir == "" {
+		return buildTermIndex(idx)
+	}
+	idxHash, err := hashFile(d.idxPath)
+	if err != nil {
+		return nil, err
+	}
+	var synHash []byte
+	if d.synPath != "" {
+		synHash, err = hashFile(d.synPath)
+		if err != nil {
+			return nil, err
+		}
+	}
+	cachePath := filepath.Join(
+		IndexCacheDir,
+		cacheFileName(idxHash, d.Is64, termIndexCacheVersion, termIndexCacheExt),
+	)
+	ti, err := loadTermIndexCache(cachePath, synHash, idx.normalizer, len(idx.entries))
+	if err == nil {
+		return ti, nil
+	}
+	if !os.IsNotExist(err) {
+		d.log().Warn("ignoring term index cache", "cache", cachePath, "err", err)
+	}
+	ti, err = buildTermIndex(idx)
+	if err != nil {
+		return nil, err
+	}
+	err = saveCacheFile(cachePath, func(w *cacheWriter) {
+		writeTermIndexCache(w, synHash, idx.normalizer, ti)
+	})
+	if err != nil {
+		d.handleError(fmt.Errorf("error saving term index cache: %w", err))
+	}
+	return ti, nil
+}
+
+// Term index cache file layout (all integers are uvarint):
+//
+//	magic (8 bytes), version
+//	len(synHash), synHash
+//	len(normalizerKey), normalizerKey
+//	len(offsets), offsets...
+//	len(postings), postings...
+//	len(fst), fst
+//	murmur3-128 checksum of all previous bytes (16 bytes)
+
+func writeTermIndexCache(w *cacheWriter, synHash []byte, normalizer *Normalizer, ti *termIndex) {
+	w.write(termIndexCacheMagic)
+	w.uint(termIndexCacheVersion)
+	w.bytes(synHash)
+	w.bytes([]byte(normalizer.Key()))
+	w.uint(uint64(len(ti.offsets)))
+	for _, offset := range ti.offsets {
+		w.uint(uint64(offset))
+	}
+	w.uint(uint64(len(ti.postings)))
+	for _, entryIndex := range ti.postings {
+		w.uint(uint64(entryIndex))
+	}
+	w.bytes(ti.fst.Bytes())
+}
+
+func loadTermIndexCache(cachePath string, synHash []byte, normalizer *Normalizer, entryCount int) (*termIndex, error) {
+	r, err := readCacheFile(cachePath, termIndexCacheMagic)
+	if err != nil {
+		return nil, err
+	}
+	if version := r.uint(); version != termIndexCacheVersion {
+		return nil, fmt.Errorf("%w: version %d", errIndexCacheInvalid, version)
+	}
+	if !bytes.Equal(r.bytes(), synHash) {
+		return nil, fmt.Errorf("%w: synonym file has changed", errIndexCacheInvalid)
+	}
+	if string(r.bytes()) != normalizer.Key() {
+		return nil, fmt.Errorf("%w: normalizer has changed", errIndexCacheInvalid)
+	}
+	ti := &termIndex{
+		offsets: make([]uint32, r.count()),
+	}
+	for i := range ti.offsets {
+		ti.offsets[i] = uint32(r.uint())
+	}
+	ti.postings = make([]int32, r.count())
+	for i := range ti.postings {
+		entryIndex := r.uint()
+		if entryIndex >= uint64(entryCount) {
+			return nil, errIndexCacheInvalid
+		}
+		ti.postings[i] = int32(entryIndex)
+	}
+	data := r.bytes()
+	if err := r.finish(); err != nil {
+		return nil, err
+	}
+	for i, offset := range ti.offsets {
+		if int(offset) > len(ti.postings) || i > 0 && offset < ti.offsets[i-1] {
+			return nil, errIndexCacheInvalid
+		}
+	}
+	ti.fst, err = fst.Load(data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", errIndexCacheInvalid, err)
+	}
+	return ti, nil
+}
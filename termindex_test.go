@@ -0,0 +1,88 @@
+package stardict
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	common "codeberg.org/ilius/go-dict-commons"
+)
+
+func resultIndexes(results []*common.SearchResultLow) []uint64 {
+	list := []uint64{}
+	for _, res := range results {
+		list = append(list, res.F_EntryIndex)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i] < list[j] })
+	return list
+}
+
+func TestFSTIndex(t *testing.T) {
+	IndexCacheDir = t.TempDir()
+	defer func() { IndexCacheDir = "" }()
+
+	entries := append([]testEntry{
+		{terms: []string{"ABC"}, defi: "letters"},
+		{terms: []string{"Apple pie"}, defi: "a dessert"},
+		{terms: []string{"apply", "applies"}, defi: "to use"},
+		{terms: []string{"Banana"}, defi: "another banana"},
+	}, testEntries...)
+	plain := openTestDict(t, entries)
+	if err := plain.Load(); err != nil {
+		t.Fatal(err)
+	}
+	// load twice, the second one reads the term index from cache
+	for range 2 {
+		d, err := NewDictionary(filepath.Dir(plain.ifoPath), "test")
+		if err != nil {
+			t.Fatal(err)
+		}
+		d.SetFSTIndex(true)
+		if err := d.Load(); err != nil {
+			t.Fatal(err)
+		}
+		if d.terms == nil {
+			t.Fatal("term index is not built")
+		}
+		compare := func(name string, a, b []*common.SearchResultLow) {
+			t.Helper()
+			x, y := resultIndexes(a), resultIndexes(b)
+			if len(x) != len(y) {
+				t.Fatalf("%s: %v != %v", name, x, y)
+			}
+			for i := range x {
+				if x[i] != y[i] {
+					t.Fatalf("%s: %v != %v", name, x, y)
+				}
+			}
+		}
+		for _, query := range []string{"apple", "BANANA", "hi", "missing"} {
+			compare("exact "+query, d.SearchExact(query, 0, 0), plain.SearchExact(query, 0, 0))
+		}
+		for _, query := range []string{"app", "ba", "hello w", "x"} {
+			compare("startwith "+query, d.SearchStartWith(query, 0, 0), plain.SearchStartWith(query, 0, 0))
+		}
+		for _, query := range []string{"app.*", "a.*|.*a", "(?i)APPLE.*", "b.n.n.", "[^a-z]+", "[A-Z]+", "[a-zA-Z]+"} {
+			res1, err := d.SearchRegex(query, 0, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			res2, _ := plain.SearchRegex(query, 0, 0)
+			compare("regex "+query, res1, res2)
+		}
+		if len(d.SearchFuzzy("aple", 0, 0)) == 0 {
+			t.Fatal("no fuzzy result for aple")
+		}
+	}
+	matches, _ := filepath.Glob(filepath.Join(IndexCacheDir, "*"+termIndexCacheExt))
+	if len(matches) != 1 {
+		t.Fatalf("expected 1 term index cache file, got %v", matches)
+	}
+	data, _ := os.ReadFile(matches[0])
+	data[len(data)-20] ^= 0xff
+	_ = os.WriteFile(matches[0], data, 0o644)
+	if _, err := loadTermIndexCache(matches[0], nil, nil, len(entries)); err == nil {
+		t.Fatal("expected error for corrupted cache")
+	}
+}
@@ -0,0 +1,458 @@
+package stardict
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	common "codeberg.org/ilius/go-dict-commons"
+)
+
+// RawEntry is an entry with its article data as stored in .dict file
+type RawEntry struct {
+	Terms []string
+	Data  []byte
+	// SameTypeSequence is the sametypesequence option Data is encoded with
+	SameTypeSequence string
+}
+
+// Items decodes the article data
+func (e *RawEntry) Items() ([]*common.SearchResultItem, error) {
+	return decodeItems(e.Data, e.SameTypeSequence)
+}
+
+// AddRaw adds a raw entry, copying its data as is if it has the same
+// sametypesequence as w, or re-encoding its items otherwise
+func (w *Writer) AddRaw(entry *RawEntry) error {
+	if entry.SameTypeSequence == w.Options[I_sametypesequence] {
+		return w.Add(entry.Terms, entry.Data)
+	}
+	items, err := entry.Items()
+	if err != nil {
+		return fmt.Errorf("%#v: %w", entry.Terms[0], err)
+	}
+	return w.AddItems(entry.Terms, items)
+}
+
+// WriteOptions are the options of Merge, Split and Filter functions
+type WriteOptions struct {
+	// BookName of output, defaults to the bookname of input
+	BookName string
+	// Compress writes .dict.dz (dictzip) instead of .dict
+	Compress bool
+}
+
+// openIfoFile opens and loads the dictionary of the given .ifo file
+func openIfoFile(ifoPath string) (*dictionaryImp, error) {
+	d, err := NewDictionary(filepath.Dir(ifoPath), strings.TrimSuffix(filepath.Base(ifoPath), ".ifo"))
+	if err != nil {
+		return nil, err
+	}
+	d.SetLemmatizer(nil)
+	err = d.Load()
+	if err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// walkRawEntries calls fn for each entry in .idx order, until fn
+// returns an error
+func (d *dictionaryImp) walkRawEntries(fn func(entry *RawEntry) error) error {
+	idx, release, err := d.acquire()
+	if err != nil {
+		return err
+	}
+	defer release()
+	seq := d.SameTypeSequence
+	for _, entry := range idx.entries {
+		data, err := d.dict.ReadSequence(entry.offset, entry.size)
+		if err != nil {
+			return err
+		}
+		err = fn(&RawEntry{
+			Terms:            entry.terms,
+			Data:             data,
+			SameTypeSequence: seq,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// newWriterFrom returns a Writer for outIfoPath with options of d
+func newWriterFrom(d *dictionaryImp, outIfoPath string, bookname string, opts *WriteOptions) *Writer {
+	if opts.BookName != "" {
+		bookname = opts.BookName
+	}
+	w := NewWriter(
+		filepath.Dir(outIfoPath),
+		strings.TrimSuffix(filepath.Base(outIfoPath), ".ifo"),
+		bookname,
+	)
+	for key := range d.Options {
+		if key != I_bookname {
+			w.Options[key] = d.Get(key)
+		}
+	}
+	w.Compress = opts.Compress
+	return w
+}
+
+// DuplicateStrategy selects how Merge handles entries with identical
+// headwords. Synonyms of all duplicates are kept in every strategy.
+type DuplicateStrategy uint8
+
+const (
+	// DuplicateConcat concatenates the items of duplicate articles
+	DuplicateConcat DuplicateStrategy = iota
+	// DuplicateKeepFirst keeps the article of the first dictionary
+	DuplicateKeepFirst
+	// DuplicateKeepLongest keeps the longest article
+	DuplicateKeepLongest
+)
+
+type mergedEntry struct {
+	terms []string
+	parts []*RawEntry
+}
+
+func (e *mergedEntry) addTerms(terms []string) {
+	for _, term := range terms {
+		if !slices.Contains(e.terms, term) {
+			e.terms = append(e.terms, term)
+		}
+	}
+}
+
+// Merge merges dictionaries into outIfoPath, handling entries with
+// identical headwords according to strategy. Options of output are
+// copied from the first dictionary, and bookname defaults to booknames
+// joined by " + ". nil opts means default options.
+func Merge(ifoPaths []string, outIfoPath string, strategy DuplicateStrategy, opts *WriteOptions) error {
+	if len(ifoPaths) == 0 {
+		return fmt.Errorf("no dictionary to merge")
+	}
+	if opts == nil {
+		opts = &WriteOptions{}
+	}
+	var dicts []*dictionaryImp
+	defer func() {
+		for _, d := range dicts {
+			d.Close()
+		}
+	}()
+	var names []string
+	seq := ""
+	for i, ifoPath := range ifoPaths {
+		d, err := openIfoFile(ifoPath)
+		if err != nil {
+			return err
+		}
+		dicts = append(dicts, d)
+		names = append(names, d.DictName())
+		if i == 0 {
+			seq = d.SameTypeSequence
+		} else if d.SameTypeSequence != seq {
+			// items of different sequences can only be mixed with a type
+			// byte before each item
+			seq = ""
+		}
+	}
+
+	var entries []*mergedEntry
+	byHeadword := map[string]*mergedEntry{}
+	hasDuplicates := false
+	for _, d := range dicts {
+		err := d.walkRawEntries(func(entry *RawEntry) error {
+			merged := byHeadword[entry.Terms[0]]
+			if merged == nil {
+				merged = &mergedEntry{}
+				byHeadword[entry.Terms[0]] = merged
+				entries = append(entries, merged)
+			} else {
+				hasDuplicates = true
+			}
+			merged.addTerms(entry.Terms)
+			merged.parts = append(merged.parts, entry)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if strategy == DuplicateConcat && hasDuplicates {
+		seq = ""
+	}
+
+	w := newWriterFrom(dicts[0], outIfoPath, strings.Join(names, " + "), opts)
+	w.Options[I_sametypesequence] = seq
+	if seq == "" {
+		delete(w.Options, I_sametypesequence)
+	}
+	for _, merged := range entries {
+		err := w.addMerged(merged, strategy)
+		if err != nil {
+			return err
+		}
+	}
+	return w.Close()
+}
+
+func (w *Writer) addMerged(merged *mergedEntry, strategy DuplicateStrategy) error {
+	if len(merged.parts) == 1 {
+		return w.AddRaw(&RawEntry{
+			Terms:            merged.terms,
+			Data:             merged.parts[0].Data,
+			SameTypeSequence: merged.parts[0].SameTypeSequence,
+		})
+	}
+	switch strategy {
+	case DuplicateKeepFirst, DuplicateKeepLongest:
+		kept := merged.parts[0]
+		if strategy == DuplicateKeepLongest {
+			for _, part := range merged.parts[1:] {
+				if len(part.Data) > len(kept.Data) {
+					kept = part
+				}
+			}
+		}
+		return w.AddRaw(&RawEntry{
+			Terms:            merged.terms,
+			Data:             kept.Data,
+			SameTypeSequence: kept.SameTypeSequence,
+		})
+	}
+	var items []*common.SearchResultItem
+	for _, part := range merged.parts {
+		partItems, err := part.Items()
+		if err != nil {
+			return fmt.Errorf("%#v: %w", merged.terms[0], err)
+		}
+		items = append(items, partItems...)
+	}
+	return w.AddItems(merged.terms, items)
+}
+
+// LetterRange is a range of first letters of headwords, case-insensitive
+type LetterRange struct {
+	From rune
+	To   rune
+}
+
+// ParseLetterRange parses a range like "a-f", or a single letter like "x"
+func ParseLetterRange(str string) (LetterRange, error) {
+	runes := []rune(str)
+	switch {
+	case len(runes) == 1:
+		return LetterRange{From: runes[0], To: runes[0]}, nil
+	case len(runes) == 3 && runes[1] == '-' && runes[0] <= runes[2]:
+		return LetterRange{From: runes[0], To: runes[2]}, nil
+	}
+	return LetterRange{}, fmt.Errorf("invalid letter range %#v", str)
+}
+
+func (r LetterRange) String() string {
+	if r.From == r.To {
+		return string(r.From)
+	}
+	return string(r.From) + "-" + string(r.To)
+}
+
+// Contains returns true if term starts with a letter in the range
+func (r LetterRange) Contains(term string) bool {
+	c, _ := utf8.DecodeRuneInString(term)
+	c = unicode.ToLower(c)
+	return c >= unicode.ToLower(r.From) && c <= unicode.ToLower(r.To)
+}
+
+// splitWriters creates the parts of a split dictionary in outDir,
+// named like name.label.ifo
+type splitWriters struct {
+	d      *dictionaryImp
+	outDir string
+	opts   *WriteOptions
+	paths  []string
+}
+
+func (s *splitWriters) newPart(label string) *Writer {
+	name := strings.TrimSuffix(filepath.Base(s.d.ifoPath), ".ifo") + "." + label
+	ifoPath := filepath.Join(s.outDir, name+".ifo")
+	s.paths = append(s.paths, ifoPath)
+	bookname := s.d.DictName()
+	if s.opts.BookName != "" {
+		bookname = s.opts.BookName
+	}
+	return newWriterFrom(s.d, ifoPath, bookname+" ("+label+")", &WriteOptions{
+		Compress: s.opts.Compress,
+	})
+}
+
+// SplitByLetter splits dictionary by the first letter of headwords into
+// one dictionary per range, and "other" for headwords not in any range.
+// Ranges should not overlap, the first matching range is used. It returns
+// the paths of created .ifo files, empty parts are not created.
+func SplitByLetter(ifoPath string, outDir string, ranges []LetterRange, opts *WriteOptions) ([]string, error) {
+	if opts == nil {
+		opts = &WriteOptions{}
+	}
+	d, err := openIfoFile(ifoPath)
+	if err != nil {
+		return nil, err
+	}
+	defer d.Close()
+	s := &splitWriters{d: d, outDir: outDir, opts: opts}
+	writers := make([]*Writer, len(ranges)+1)
+	for i, r := range ranges {
+		writers[i] = s.newPart(r.String())
+	}
+	writers[len(ranges)] = s.newPart("other")
+	err = d.walkRawEntries(func(entry *RawEntry) error {
+		for i, r := range ranges {
+			if r.Contains(entry.Terms[0]) {
+				return writers[i].AddRaw(entry)
+			}
+		}
+		return writers[len(ranges)].AddRaw(entry)
+	})
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	for i, w := range writers {
+		if w.EntryCount() == 0 {
+			continue
+		}
+		err := w.Close()
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, s.paths[i])
+	}
+	return paths, nil
+}
+
+// SplitBySize splits dictionary into parts with at most maxSize bytes of
+// articles (but at least one entry), named name.1.ifo, name.2.ifo etc.
+// It returns the paths of created .ifo files.
+func SplitBySize(ifoPath string, outDir string, maxSize int64, opts *WriteOptions) ([]string, error) {
+	if opts == nil {
+		opts = &WriteOptions{}
+	}
+	if maxSize <= 0 {
+		return nil, fmt.Errorf("invalid max size %d", maxSize)
+	}
+	d, err := openIfoFile(ifoPath)
+	if err != nil {
+		return nil, err
+	}
+	defer d.Close()
+	s := &splitWriters{d: d, outDir: outDir, opts: opts}
+	var w *Writer
+	var size int64
+	err = d.walkRawEntries(func(entry *RawEntry) error {
+		if w != nil && size+int64(len(entry.Data)) > maxSize {
+			err := w.Close()
+			if err != nil {
+				return err
+			}
+			w = nil
+		}
+		if w == nil {
+			w = s.newPart(strconv.Itoa(len(s.paths) + 1))
+			size = 0
+		}
+		size += int64(len(entry.Data))
+		return w.AddRaw(entry)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if w != nil {
+		err := w.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return s.paths, nil
+}
+
+// Filter writes the entries of dictionary for which keep returns true
+// into outIfoPath, and returns the number of kept entries
+func Filter(ifoPath string, outIfoPath string, keep func(entry *RawEntry) bool, opts *WriteOptions) (int, error) {
+	if opts == nil {
+		opts = &WriteOptions{}
+	}
+	d, err := openIfoFile(ifoPath)
+	if err != nil {
+		return 0, err
+	}
+	defer d.Close()
+	w := newWriterFrom(d, outIfoPath, d.DictName(), opts)
+	err = d.walkRawEntries(func(entry *RawEntry) error {
+		if !keep(entry) {
+			return nil
+		}
+		return w.AddRaw(entry)
+	})
+	if err != nil {
+		return 0, err
+	}
+	count := w.EntryCount()
+	return count, w.Close()
+}
+
+// FilterWords returns a Filter predicate that keeps entries having
+// a term in words, compared case-insensitively
+func FilterWords(words []string) func(entry *RawEntry) bool {
+	set := make(map[string]bool, len(words))
+	for _, word := range words {
+		set[strings.ToLower(strings.TrimSpace(word))] = true
+	}
+	return func(entry *RawEntry) bool {
+		for _, term := range entry.Terms {
+			if set[strings.ToLower(term)] {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// FilterRegex returns a Filter predicate that keeps entries having
+// a term matching re
+func FilterRegex(re *regexp.Regexp) func(entry *RawEntry) bool {
+	return func(entry *RawEntry) bool {
+		for _, term := range entry.Terms {
+			if re.MatchString(term) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// ReadWordList reads a word list file with one word per line,
+// ignoring empty lines
+func ReadWordList(fpath string) ([]string, error) {
+	data, err := os.ReadFile(fpath)
+	if err != nil {
+		return nil, err
+	}
+	var words []string
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" {
+			words = append(words, line)
+		}
+	}
+	return words, nil
+}
@@ -0,0 +1,150 @@
+package stardict
+
+import (
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+// readAllEntries returns terms and item data of all entries
+func readAllEntries(t *testing.T, ifoPath string) (*dictionaryImp, map[string][]string) {
+	t.Helper()
+	report, err := Validate(ifoPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Problems) > 0 {
+		t.Fatalf("unexpected problems: %v", report.Problems)
+	}
+	d, err := openIfoFile(ifoPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(d.Close)
+	entries := map[string][]string{}
+	err = d.walkRawEntries(func(entry *RawEntry) error {
+		items, err := entry.Items()
+		if err != nil {
+			return err
+		}
+		var list []string
+		for _, item := range items {
+			list = append(list, string(item.Type)+":"+string(item.Data))
+		}
+		entries[entry.Terms[0]] = list
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d, entries
+}
+
+func writeTransformTestDicts(t *testing.T) (string, string) {
+	t.Helper()
+	dir := t.TempDir()
+	writeTestDict(t, dir, "first", testEntries)
+	w := NewWriter(dir, "second", "Second")
+	w.Options[I_sametypesequence] = "h"
+	_ = w.Add([]string{"apple", "pomme"}, []byte("<b>Apfel</b>"))
+	_ = w.Add([]string{"cherry"}, []byte("<i>Kirsche</i>"))
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return filepath.Join(dir, "first.ifo"), filepath.Join(dir, "second.ifo")
+}
+
+func TestMerge(t *testing.T) {
+	first, second := writeTransformTestDicts(t)
+	outPath := filepath.Join(t.TempDir(), "merged.ifo")
+	if err := Merge([]string{first, second}, outPath, DuplicateConcat, nil); err != nil {
+		t.Fatal(err)
+	}
+	d, entries := readAllEntries(t, outPath)
+	if d.DictName() != "first + Second" || d.Options[I_sametypesequence] != "" {
+		t.Fatalf("unexpected options: %v", d.Options)
+	}
+	if !reflect.DeepEqual(entries["apple"], []string{"m:a fruit", "h:<b>Apfel</b>"}) {
+		t.Fatalf("unexpected apple: %v", entries["apple"])
+	}
+	if len(entries) != 4 {
+		t.Fatalf("expected 4 entries, got %v", entries)
+	}
+	if results := d.SearchExact("pomme", 0, 0); len(results) != 1 || len(results[0].F_Terms) != 3 {
+		t.Fatalf("expected synonyms of both dictionaries: %v", results)
+	}
+
+	if err := Merge([]string{second, first}, outPath, DuplicateKeepFirst, nil); err != nil {
+		t.Fatal(err)
+	}
+	_, entries = readAllEntries(t, outPath)
+	if !reflect.DeepEqual(entries["apple"], []string{"h:<b>Apfel</b>"}) {
+		t.Fatalf("unexpected apple: %v", entries["apple"])
+	}
+	if !reflect.DeepEqual(entries["banana"], []string{"m:a yellow fruit"}) {
+		t.Fatalf("unexpected banana: %v", entries["banana"])
+	}
+
+	if err := Merge([]string{first, second}, outPath, DuplicateKeepLongest, nil); err != nil {
+		t.Fatal(err)
+	}
+	_, entries = readAllEntries(t, outPath)
+	if !reflect.DeepEqual(entries["apple"], []string{"h:<b>Apfel</b>"}) {
+		t.Fatalf("unexpected apple: %v", entries["apple"])
+	}
+}
+
+func TestSplit(t *testing.T) {
+	first, _ := writeTransformTestDicts(t)
+	outDir := t.TempDir()
+	paths, err := SplitByLetter(first, outDir, []LetterRange{{From: 'A', To: 'b'}, {From: 'x', To: 'z'}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedPaths := []string{filepath.Join(outDir, "first.A-b.ifo"), filepath.Join(outDir, "first.other.ifo")}
+	if !reflect.DeepEqual(paths, expectedPaths) {
+		t.Fatalf("unexpected paths: %v", paths)
+	}
+	d, entries := readAllEntries(t, paths[0])
+	if len(entries) != 2 || d.DictName() != "first (A-b)" || d.Options[I_sametypesequence] != "m" {
+		t.Fatalf("unexpected part: %v %v", d.Options, entries)
+	}
+
+	paths, err = SplitBySize(first, outDir, 22, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) != 2 {
+		t.Fatalf("expected 2 parts, got %v", paths)
+	}
+	_, entries = readAllEntries(t, paths[0])
+	if len(entries) != 2 {
+		t.Fatalf("unexpected first part: %v", entries)
+	}
+
+	if _, err := ParseLetterRange("z-a"); err == nil {
+		t.Fatal("expected error for invalid range")
+	}
+}
+
+func TestFilter(t *testing.T) {
+	first, _ := writeTransformTestDicts(t)
+	outPath := filepath.Join(t.TempDir(), "filtered.ifo")
+	count, err := Filter(first, outPath, FilterWords([]string{"HI", "banana"}), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, entries := readAllEntries(t, outPath)
+	if count != 2 || entries["hello world"] == nil || entries["banana"] == nil {
+		t.Fatalf("unexpected entries: %d %v", count, entries)
+	}
+	count, err = Filter(first, outPath, FilterRegex(regexp.MustCompile(`^apples$`)), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, entries = readAllEntries(t, outPath)
+	if count != 1 || entries["apple"] == nil {
+		t.Fatalf("unexpected entries: %d %v", count, entries)
+	}
+}
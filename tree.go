@@ -0,0 +1,219 @@
+package stardict
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// TreeNode is a node of tree dictionary
+type TreeNode struct {
+	Term string
+	// EntryIndex is the entry index of node's article,
+	// or -1 if the node has no article
+	EntryIndex int
+
+	parent   int
+	children []int
+	offset   uint64
+	size     uint64
+}
+
+// Tree is the structure of a tree dictionary (.tdx file), used for
+// hierarchical content like encyclopedias. Nodes are numbered in the
+// order of .tdx file, where each node is followed by its subtree.
+// Nodes with articles are the entries of dictionary.
+type Tree struct {
+	nodes []*TreeNode
+	roots []int
+	// entryNodes are node indexes by entry index
+	entryNodes []int
+}
+
+// ReadTree reads .tdx file (or gzip-compressed .tdx.gz)
+func ReadTree(filename string) (*Tree, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	if strings.HasSuffix(filename, ".gz") {
+		reader, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		data, err = io.ReadAll(reader)
+		if err != nil {
+			return nil, err
+		}
+	}
+	formatError := func(offset int, reason string) error {
+		return &FormatError{
+			File:   filename,
+			Offset: int64(offset),
+			Err:    fmt.Errorf("%w: %s", ErrCorruptTdx, reason),
+		}
+	}
+
+	tree := &Tree{}
+	// stack has the nodes whose subtree is being read,
+	// with the number of children not read yet
+	type pendingNode struct {
+		node      int
+		remaining uint32
+	}
+	var stack []pendingNode
+	pos := 0
+	for pos < len(data) {
+		recordPos := pos
+		termLen := bytes.IndexByte(data[pos:], 0)
+		if termLen < 0 || pos+termLen+13 > len(data) {
+			return nil, formatError(recordPos, "truncated record")
+		}
+		if termLen > MAX_TERM_LENGTH {
+			return nil, formatError(recordPos, fmt.Sprintf("term longer than %d bytes", MAX_TERM_LENGTH))
+		}
+		term := string(data[pos : pos+termLen])
+		pos += termLen + 1
+		offset := binary.BigEndian.Uint32(data[pos:])
+		size := binary.BigEndian.Uint32(data[pos+4:])
+		childCount := binary.BigEndian.Uint32(data[pos+8:])
+		pos += 12
+
+		nodeIndex := len(tree.nodes)
+		node := &TreeNode{
+			Term:       term,
+			EntryIndex: -1,
+			parent:     -1,
+			offset:     uint64(offset),
+			size:       uint64(size),
+		}
+		if len(stack) > 0 {
+			top := &stack[len(stack)-1]
+			node.parent = top.node
+			parent := tree.nodes[top.node]
+			parent.children = append(parent.children, nodeIndex)
+			top.remaining--
+			if top.remaining == 0 {
+				stack = stack[:len(stack)-1]
+			}
+		} else {
+			tree.roots = append(tree.roots, nodeIndex)
+		}
+		if size > 0 {
+			node.EntryIndex = len(tree.entryNodes)
+			tree.entryNodes = append(tree.entryNodes, nodeIndex)
+		}
+		tree.nodes = append(tree.nodes, node)
+		if childCount > 0 {
+			stack = append(stack, pendingNode{node: nodeIndex, remaining: childCount})
+		}
+	}
+	if len(stack) > 0 {
+		top := stack[len(stack)-1]
+		return nil, formatError(pos, fmt.Sprintf(
+			"missing %d children of %#v", top.remaining, tree.nodes[top.node].Term,
+		))
+	}
+	return tree, nil
+}
+
+// Len returns the number of nodes
+func (tree *Tree) Len() int {
+	return len(tree.nodes)
+}
+
+// Roots returns the indexes of top-level nodes
+func (tree *Tree) Roots() []int {
+	return tree.roots
+}
+
+// Node returns the node with the given index, or nil
+func (tree *Tree) Node(nodeIndex int) *TreeNode {
+	if nodeIndex < 0 || nodeIndex >= len(tree.nodes) {
+		return nil
+	}
+	return tree.nodes[nodeIndex]
+}
+
+// Children returns the indexes of child nodes
+func (tree *Tree) Children(nodeIndex int) []int {
+	node := tree.Node(nodeIndex)
+	if node == nil {
+		return nil
+	}
+	return node.children
+}
+
+// Parent returns the index of parent node, or -1 for top-level nodes
+func (tree *Tree) Parent(nodeIndex int) int {
+	node := tree.Node(nodeIndex)
+	if node == nil {
+		return -1
+	}
+	return node.parent
+}
+
+// Path returns the terms of nodes from top-level node to the given node
+func (tree *Tree) Path(nodeIndex int) []string {
+	var path []string
+	for nodeIndex >= 0 && nodeIndex < len(tree.nodes) {
+		node := tree.nodes[nodeIndex]
+		path = append(path, node.Term)
+		nodeIndex = node.parent
+	}
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	return path
+}
+
+// NodeByEntry returns the node index of the given entry index, or -1
+func (tree *Tree) NodeByEntry(entryIndex int) int {
+	if entryIndex < 0 || entryIndex >= len(tree.entryNodes) {
+		return -1
+	}
+	return tree.entryNodes[entryIndex]
+}
+
+// index builds Idx of the nodes with articles, in the order of nodes
+func (tree *Tree) index(opts indexOptions) *Idx {
+	idx := newIdx(len(tree.entryNodes))
+	idx.normalizer = opts.normalizer
+	idx.errorHandler = opts.errorHandler
+	wordPrefixMap := WordPrefixMap{}
+	for _, nodeIndex := range tree.entryNodes {
+		node := tree.nodes[nodeIndex]
+		termIndex := idx.Add(node.Term, node.offset, node.size)
+		idx.addKey(wordPrefixMap, idx.entries[termIndex], node.Term, termIndex)
+	}
+	idx.setWordPrefixMap(wordPrefixMap)
+	return idx
+}
+
+// memorySize returns an estimate of memory used by tree in bytes
+func (tree *Tree) memorySize() int64 {
+	const nodeSize = 8 + 16 + 8 + 8 + 24 + 16
+	size := int64(len(tree.nodes))*nodeSize + int64(len(tree.roots)+len(tree.entryNodes))*8
+	for _, node := range tree.nodes {
+		size += int64(len(node.Term)) + int64(cap(node.children))*8
+	}
+	return size
+}
+
+// Tree returns the tree of a tree dictionary, loading it first if lazy
+// loading is enabled, or nil for dictionaries with .idx file
+func (d *dictionaryImp) Tree() (*Tree, error) {
+	if !d.isTree {
+		return nil, nil
+	}
+	_, release, err := d.acquire()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return d.tree, nil
+}
@@ -0,0 +1,133 @@
+package stardict
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+type testTreeNode struct {
+	term     string
+	defi     string
+	children []testTreeNode
+}
+
+// writeTestTree writes a tree dictionary with sametypesequence=m,
+// and returns the .tdx data
+func writeTestTree(t *testing.T, dir string, name string, nodes []testTreeNode) []byte {
+	t.Helper()
+	var dictBuf, tdxBuf bytes.Buffer
+	count := 0
+	var write func(nodes []testTreeNode)
+	write = func(nodes []testTreeNode) {
+		for _, node := range nodes {
+			tdxBuf.WriteString(node.term)
+			tdxBuf.WriteByte(0)
+			_ = binary.Write(&tdxBuf, binary.BigEndian, uint32(dictBuf.Len()))
+			_ = binary.Write(&tdxBuf, binary.BigEndian, uint32(len(node.defi)))
+			_ = binary.Write(&tdxBuf, binary.BigEndian, uint32(len(node.children)))
+			dictBuf.WriteString(node.defi)
+			if node.defi != "" {
+				count++
+			}
+			write(node.children)
+		}
+	}
+	write(nodes)
+	ifo := fmt.Sprintf(
+		"StarDict's dict ifo file\nversion=2.4.2\nwordcount=%d\ntdxfilesize=%d\nbookname=%s\nsametypesequence=m\n",
+		count, tdxBuf.Len(), name,
+	)
+	_ = os.WriteFile(filepath.Join(dir, name+".ifo"), []byte(ifo), 0o644)
+	_ = os.WriteFile(filepath.Join(dir, name+".tdx"), tdxBuf.Bytes(), 0o644)
+	_ = os.WriteFile(filepath.Join(dir, name+".dict"), dictBuf.Bytes(), 0o644)
+	return tdxBuf.Bytes()
+}
+
+var testTree = []testTreeNode{
+	{term: "Animals", children: []testTreeNode{
+		{term: "Cat", defi: "a small animal"},
+		{term: "Dogs", defi: "friends", children: []testTreeNode{
+			{term: "Beagle", defi: "a hound"},
+		}},
+	}},
+	{term: "Plants", defi: "green"},
+}
+
+func TestTreeDictionary(t *testing.T) {
+	dir := t.TempDir()
+	writeTestTree(t, dir, "tree", testTree)
+	d, err := NewDictionary(dir, "tree")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Load(); err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	tree, err := d.Tree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tree.Len() != 5 || !reflect.DeepEqual(tree.Roots(), []int{0, 4}) {
+		t.Fatalf("unexpected roots %v of %d nodes", tree.Roots(), tree.Len())
+	}
+	if children := tree.Children(0); !reflect.DeepEqual(children, []int{1, 2}) {
+		t.Fatalf("unexpected children: %v", children)
+	}
+	if parent := tree.Parent(3); parent != 2 {
+		t.Fatalf("unexpected parent: %d", parent)
+	}
+	if path := tree.Path(3); !reflect.DeepEqual(path, []string{"Animals", "Dogs", "Beagle"}) {
+		t.Fatalf("unexpected path: %v", path)
+	}
+	if tree.Node(0).EntryIndex != -1 {
+		t.Fatal("node without article has an entry")
+	}
+
+	if count, _ := d.EntryCount(); count != 4 {
+		t.Fatalf("unexpected entry count %d", count)
+	}
+	res := d.EntryByIndex(tree.Node(3).EntryIndex)
+	if res == nil || res.F_Terms[0] != "Beagle" || string(res.Items()[0].Data) != "a hound" {
+		t.Fatalf("unexpected entry: %v", res)
+	}
+	results := d.SearchExact("dogs", 0, 0)
+	if len(results) != 1 || tree.NodeByEntry(int(results[0].F_EntryIndex)) != 2 {
+		t.Fatalf("unexpected results: %v", results)
+	}
+}
+
+func TestReadTree(t *testing.T) {
+	dir := t.TempDir()
+	data := writeTestTree(t, dir, "tree", testTree)
+
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	_, _ = zw.Write(data)
+	_ = zw.Close()
+	gzPath := filepath.Join(dir, "tree.tdx.gz")
+	_ = os.WriteFile(gzPath, gz.Bytes(), 0o644)
+	tree, err := ReadTree(gzPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tree.Len() != 5 {
+		t.Fatalf("unexpected node count %d", tree.Len())
+	}
+
+	// without the last nodes, "Dogs" misses a child
+	tdxPath := filepath.Join(dir, "tree.tdx")
+	_ = os.WriteFile(tdxPath, data[:bytes.Index(data, []byte("Beagle"))], 0o644)
+	_, err = ReadTree(tdxPath)
+	if !errors.Is(err, ErrCorruptTdx) {
+		t.Fatalf("expected ErrCorruptTdx, got %v", err)
+	}
+}
@@ -0,0 +1,632 @@
+package stardict
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const ifoMagic = "StarDict's dict ifo file"
+
+// maxProblemsPerKind limits the number of reported problems of the same
+// kind (for example unsorted records), the rest are summarized
+const maxProblemsPerKind = 20
+
+// StarDict does not allow terms longer than 255 bytes
+const maxTermBytes = 255
+
+// Severity is the severity of a validation Problem
+type Severity uint8
+
+const (
+	// SeverityWarning is for problems that do not prevent reading
+	SeverityWarning Severity = iota
+	// SeverityError is for problems that cause wrong results or failures
+	SeverityError
+)
+
+func (s Severity) String() string {
+	if s == SeverityError {
+		return "error"
+	}
+	return "warning"
+}
+
+// Problem is an issue found by Validate
+type Problem struct {
+	File     string
+	Offset   int64 // byte position in File, -1 if not applicable
+	Severity Severity
+	Message  string
+}
+
+func (p *Problem) String() string {
+	if p.Offset < 0 {
+		return fmt.Sprintf("%s: %s: %s", p.File, p.Severity, p.Message)
+	}
+	return fmt.Sprintf("%s:%d: %s: %s", p.File, p.Offset, p.Severity, p.Message)
+}
+
+// ValidationReport is the result of Validate
+type ValidationReport struct {
+	Problems []*Problem
+
+	EntryCount   int
+	SynonymCount int
+	IdxFileSize  int64
+	DictSize     int64
+
+	IdxUnsorted bool
+	SynUnsorted bool
+	DanglingSyn int
+
+	kindCount map[string]int
+}
+
+// HasErrors returns true if any problem has SeverityError
+func (r *ValidationReport) HasErrors() bool {
+	for _, p := range r.Problems {
+		if p.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *ValidationReport) add(file string, offset int64, severity Severity, msg string) {
+	r.Problems = append(r.Problems, &Problem{
+		File:     file,
+		Offset:   offset,
+		Severity: severity,
+		Message:  msg,
+	})
+}
+
+// addLimited adds a problem unless too many problems of kind are reported
+func (r *ValidationReport) addLimited(kind string, file string, offset int64, severity Severity, msg string) {
+	if r.kindCount == nil {
+		r.kindCount = map[string]int{}
+	}
+	r.kindCount[kind]++
+	if r.kindCount[kind] > maxProblemsPerKind {
+		return
+	}
+	r.add(file, offset, severity, msg)
+}
+
+func (r *ValidationReport) summarize(kind string, file string, severity Severity) {
+	if n := r.kindCount[kind] - maxProblemsPerKind; n > 0 {
+		r.add(file, -1, severity, fmt.Sprintf("%d more problems of kind %#v", n, kind))
+	}
+}
+
+// dictFiles holds paths of one dictionary's files
+type dictFiles struct {
+	ifo  string
+	idx  string
+	dict string
+	syn  string
+}
+
+func findDictFiles(ifoPath string) (*dictFiles, error) {
+	base := strings.TrimSuffix(ifoPath, ifoExt)
+	files := &dictFiles{
+		ifo:  ifoPath,
+		idx:  base + ".idx",
+		dict: base + ".dict",
+		syn:  base + ".syn",
+	}
+	if _, err := os.Stat(files.ifo); err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(files.idx); err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(files.dict); err != nil {
+		if _, errDz := os.Stat(files.dict + ".dz"); errDz != nil {
+			return nil, err
+		}
+		files.dict += ".dz"
+	}
+	if _, err := os.Stat(files.syn); err != nil {
+		files.syn = ""
+	}
+	return files, nil
+}
+
+type ifoLine struct {
+	key   string
+	value string
+	pos   int64
+}
+
+// parseIfoLines splits .ifo data into key=value lines, reporting
+// malformed lines, and returns lines after the magic line
+func parseIfoLines(data []byte, fpath string, report *ValidationReport) []ifoLine {
+	var lines []ifoLine
+	pos := int64(0)
+	if bytes.HasPrefix(data, []byte("\ufeff")) {
+		data = data[3:]
+		pos = 3
+	}
+	for lineNum, raw := range bytes.SplitAfter(data, []byte{'\n'}) {
+		linePos := pos
+		pos += int64(len(raw))
+		line := strings.TrimRight(string(raw), "\r\n")
+		if lineNum == 0 {
+			if line != ifoMagic {
+				report.add(fpath, linePos, SeverityError, fmt.Sprintf("first line must be %#v", ifoMagic))
+			}
+			continue
+		}
+		if line == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			report.add(fpath, linePos, SeverityError, fmt.Sprintf("invalid line %#v", line))
+			continue
+		}
+		lines = append(lines, ifoLine{key: key, value: value, pos: linePos})
+	}
+	return lines
+}
+
+type idxRecord struct {
+	term   string
+	offset uint64
+	size   uint64
+	pos    int64
+}
+
+// parseIdxRecords parses .idx data, reporting truncated or invalid records
+func parseIdxRecords(data []byte, is64 bool, fpath string, report *ValidationReport) []idxRecord {
+	intSize := 4
+	if is64 {
+		intSize = 8
+	}
+	var records []idxRecord
+	pos := 0
+	for pos < len(data) {
+		end := bytes.IndexByte(data[pos:], 0)
+		if end < 0 {
+			report.add(fpath, int64(pos), SeverityError, "truncated record: missing NUL after term")
+			break
+		}
+		term := data[pos : pos+end]
+		if pos+end+1+2*intSize > len(data) {
+			report.add(fpath, int64(pos), SeverityError, "truncated record: missing offset or size")
+			break
+		}
+		numPos := pos + end + 1
+		rec := idxRecord{
+			term: string(term),
+			pos:  int64(pos),
+		}
+		if is64 {
+			rec.offset = binary.BigEndian.Uint64(data[numPos:])
+			rec.size = binary.BigEndian.Uint64(data[numPos+8:])
+		} else {
+			rec.offset = uint64(binary.BigEndian.Uint32(data[numPos:]))
+			rec.size = uint64(binary.BigEndian.Uint32(data[numPos+4:]))
+		}
+		checkTerm(rec.term, rec.pos, "idx", fpath, report)
+		records = append(records, rec)
+		pos = numPos + 2*intSize
+	}
+	return records
+}
+
+// parseSynRecords parses .syn data, reporting truncated or invalid records
+func parseSynRecords(data []byte, fpath string, report *ValidationReport) ([]synRecord, []int64) {
+	var records []synRecord
+	var positions []int64
+	pos := 0
+	for pos < len(data) {
+		end := bytes.IndexByte(data[pos:], 0)
+		if end < 0 {
+			report.add(fpath, int64(pos), SeverityError, "truncated record: missing NUL after term")
+			break
+		}
+		if pos+end+5 > len(data) {
+			report.add(fpath, int64(pos), SeverityError, "truncated record: missing entry index")
+			break
+		}
+		term := string(data[pos : pos+end])
+		checkTerm(term, int64(pos), "syn", fpath, report)
+		records = append(records, synRecord{
+			term:       term,
+			entryIndex: binary.BigEndian.Uint32(data[pos+end+1:]),
+		})
+		positions = append(positions, int64(pos))
+		pos += end + 5
+	}
+	return records, positions
+}
+
+func checkTerm(term string, pos int64, kindPrefix string, fpath string, report *ValidationReport) {
+	if !utf8.ValidString(term) {
+		report.addLimited(kindPrefix+"-utf8", fpath, pos, SeverityError, fmt.Sprintf("invalid UTF-8 in term %q", term))
+	}
+	if term == "" {
+		report.addLimited(kindPrefix+"-empty", fpath, pos, SeverityError, "empty term")
+	}
+	if len(term) > maxTermBytes {
+		report.addLimited(kindPrefix+"-long", fpath, pos, SeverityWarning, fmt.Sprintf(
+			"term is %d bytes long, more than %d", len(term), maxTermBytes,
+		))
+	}
+}
+
+// dictDataSize returns the (uncompressed) size of .dict or .dict.dz file
+func dictDataSize(fpath string) (int64, error) {
+	stat, err := os.Stat(fpath)
+	if err != nil {
+		return 0, err
+	}
+	if !strings.HasSuffix(fpath, ".dz") {
+		return stat.Size(), nil
+	}
+	// last 4 bytes of gzip file is the uncompressed size (modulo 2^32)
+	file, err := os.Open(fpath)
+	if err != nil {
+		return 0, err
+	}
+	defer closeCloser(file)
+	if stat.Size() < 18 {
+		return 0, fmt.Errorf("%s: file too small for gzip", fpath)
+	}
+	var buf [4]byte
+	_, err = file.ReadAt(buf[:], stat.Size()-4)
+	if err != nil {
+		return 0, err
+	}
+	return int64(binary.LittleEndian.Uint32(buf[:])), nil
+}
+
+// validator holds the state of one Validate call
+type validator struct {
+	files  *dictFiles
+	report *ValidationReport
+
+	options map[string]string
+	is64    bool
+
+	idxData    []byte
+	synData    []byte
+	idxRecords []idxRecord
+	synRecords []synRecord
+}
+
+// Validate checks the files of a dictionary given the path of its .ifo file.
+// The returned error is only for failures to read the files, problems
+// in the dictionary itself are listed in the report.
+func Validate(ifoPath string) (*ValidationReport, error) {
+	v, err := newValidator(ifoPath)
+	if err != nil {
+		return nil, err
+	}
+	err = v.run()
+	if err != nil {
+		return nil, err
+	}
+	return v.report, nil
+}
+
+func newValidator(ifoPath string) (*validator, error) {
+	files, err := findDictFiles(ifoPath)
+	if err != nil {
+		return nil, err
+	}
+	return &validator{
+		files:   files,
+		report:  &ValidationReport{},
+		options: map[string]string{},
+	}, nil
+}
+
+func (v *validator) run() error {
+	err := v.checkInfo()
+	if err != nil {
+		return err
+	}
+	err = v.checkIdx()
+	if err != nil {
+		return err
+	}
+	err = v.checkSyn()
+	if err != nil {
+		return err
+	}
+	return v.checkArticles()
+}
+
+func (v *validator) checkInfo() error {
+	fpath := v.files.ifo
+	report := v.report
+	data, err := os.ReadFile(fpath)
+	if err != nil {
+		return err
+	}
+	positions := map[string]int64{}
+	for _, line := range parseIfoLines(data, fpath, report) {
+		if _, ok := v.options[line.key]; ok {
+			report.add(fpath, line.pos, SeverityWarning, fmt.Sprintf("duplicate key %#v", line.key))
+		}
+		v.options[line.key] = line.value
+		positions[line.key] = line.pos
+	}
+	for _, key := range []string{"version", I_bookname, I_wordcount, I_idxfilesize} {
+		if _, ok := v.options[key]; !ok {
+			report.add(fpath, -1, SeverityError, fmt.Sprintf("missing required key %#v", key))
+		}
+	}
+	version := v.options["version"]
+	if version != "" && version != "2.4.2" && version != "3.0.0" {
+		report.add(fpath, positions["version"], SeverityError, fmt.Sprintf("unsupported version %#v", version))
+	}
+	for _, key := range []string{I_wordcount, I_synwordcount, I_idxfilesize} {
+		value, ok := v.options[key]
+		if !ok {
+			continue
+		}
+		if _, err := strconv.ParseUint(value, 10, 64); err != nil {
+			report.add(fpath, positions[key], SeverityError, fmt.Sprintf("invalid number %#v for %#v", value, key))
+		}
+	}
+	if bits, ok := v.options[I_idxoffsetbits]; ok {
+		switch bits {
+		case "64":
+			v.is64 = true
+		case "32":
+		default:
+			report.add(fpath, positions[I_idxoffsetbits], SeverityError, fmt.Sprintf("invalid idxoffsetbits %#v", bits))
+		}
+		if version == "2.4.2" {
+			report.add(fpath, positions[I_idxoffsetbits], SeverityWarning, "idxoffsetbits requires version 3.0.0")
+		}
+	}
+	if seq, ok := v.options[I_sametypesequence]; ok {
+		if seq == "" {
+			report.add(fpath, positions[I_sametypesequence], SeverityError, "empty sametypesequence")
+		}
+		for _, t := range seq {
+			if !isValidItemType(t) {
+				report.add(fpath, positions[I_sametypesequence], SeverityError, fmt.Sprintf("invalid type %q in sametypesequence", t))
+			}
+		}
+	}
+	// the same checks ReadInfo does when loading, in case we missed something
+	if _, err := ReadInfo(fpath); err != nil {
+		report.add(fpath, -1, SeverityError, fmt.Sprintf("ReadInfo failed: %v", err))
+	}
+	return nil
+}
+
+func (v *validator) checkIdx() error {
+	fpath := v.files.idx
+	report := v.report
+	data, err := os.ReadFile(fpath)
+	if err != nil {
+		return err
+	}
+	v.idxData = data
+	report.IdxFileSize = int64(len(data))
+	report.DictSize, err = dictDataSize(v.files.dict)
+	if err != nil {
+		return err
+	}
+	v.idxRecords = parseIdxRecords(data, v.is64, fpath, report)
+	report.EntryCount = len(v.idxRecords)
+
+	for i, rec := range v.idxRecords {
+		if !articleInBounds(rec.offset, rec.size, uint64(report.DictSize)) {
+			report.addLimited("idx-bounds", fpath, rec.pos, SeverityError, fmt.Sprintf(
+				"article of %q at offset %d with size %d exceeds dict size %d",
+				rec.term, rec.offset, rec.size, report.DictSize,
+			))
+		}
+		if i > 0 && CompareTerms(v.idxRecords[i-1].term, rec.term) > 0 {
+			report.IdxUnsorted = true
+			report.addLimited("idx-sort", fpath, rec.pos, SeverityError, fmt.Sprintf(
+				"not sorted: %q comes after %q", rec.term, v.idxRecords[i-1].term,
+			))
+		}
+	}
+	report.summarize("idx-utf8", fpath, SeverityError)
+	report.summarize("idx-empty", fpath, SeverityError)
+	report.summarize("idx-long", fpath, SeverityWarning)
+	report.summarize("idx-bounds", fpath, SeverityError)
+	report.summarize("idx-sort", fpath, SeverityError)
+
+	v.checkCount(I_wordcount, report.EntryCount)
+	v.checkCount(I_idxfilesize, len(data))
+	return nil
+}
+
+// checkCount compares a numeric .ifo option with the actual value
+func (v *validator) checkCount(key string, actual int) {
+	value, ok := v.options[key]
+	if !ok {
+		return
+	}
+	if value != strconv.Itoa(actual) {
+		v.report.add(v.files.ifo, -1, SeverityError, fmt.Sprintf(
+			"%s=%s does not match actual value %d", key, value, actual,
+		))
+	}
+}
+
+func (v *validator) checkSyn() error {
+	report := v.report
+	if v.files.syn == "" {
+		if _, ok := v.options[I_synwordcount]; ok {
+			report.add(v.files.ifo, -1, SeverityWarning, "synwordcount is set, but there is no .syn file")
+		}
+		return nil
+	}
+	fpath := v.files.syn
+	data, err := os.ReadFile(fpath)
+	if err != nil {
+		return err
+	}
+	v.synData = data
+	records, positions := parseSynRecords(data, fpath, report)
+	v.synRecords = records
+	report.SynonymCount = len(records)
+	for i, rec := range records {
+		if int(rec.entryIndex) >= len(v.idxRecords) {
+			report.DanglingSyn++
+			report.addLimited("syn-dangling", fpath, positions[i], SeverityError, fmt.Sprintf(
+				"synonym %q references entry %d, but there are %d entries",
+				rec.term, rec.entryIndex, len(v.idxRecords),
+			))
+		}
+		if i > 0 && CompareTerms(records[i-1].term, rec.term) > 0 {
+			report.SynUnsorted = true
+			report.addLimited("syn-sort", fpath, positions[i], SeverityError, fmt.Sprintf(
+				"not sorted: %q comes after %q", rec.term, records[i-1].term,
+			))
+		}
+	}
+	report.summarize("syn-utf8", fpath, SeverityError)
+	report.summarize("syn-empty", fpath, SeverityError)
+	report.summarize("syn-long", fpath, SeverityWarning)
+	report.summarize("syn-dangling", fpath, SeverityError)
+	report.summarize("syn-sort", fpath, SeverityError)
+
+	if _, ok := v.options[I_synwordcount]; !ok {
+		report.add(v.files.ifo, -1, SeverityError, "missing synwordcount, required if .syn file exists")
+	}
+	v.checkCount(I_synwordcount, len(records))
+	return nil
+}
+
+// checkArticles reads every article and checks its type sequence
+func (v *validator) checkArticles() error {
+	report := v.report
+	dict, err := ReadDict(v.files.dict)
+	if err != nil {
+		return err
+	}
+	defer dict.Close()
+	seq := v.options[I_sametypesequence]
+	for _, rec := range v.idxRecords {
+		if !articleInBounds(rec.offset, rec.size, uint64(report.DictSize)) {
+			continue
+		}
+		data, err := dict.ReadSequence(rec.offset, rec.size)
+		if err != nil {
+			report.addLimited("dict-read", v.files.dict, int64(rec.offset), SeverityError, fmt.Sprintf(
+				"failed to read article of %q: %v", rec.term, err,
+			))
+			continue
+		}
+		_, err = decodeItems(data, seq)
+		if err != nil {
+			report.addLimited("dict-decode", v.files.dict, int64(rec.offset), SeverityError, fmt.Sprintf(
+				"undecodable article of %q: %v", rec.term, err,
+			))
+		}
+	}
+	report.summarize("dict-read", v.files.dict, SeverityError)
+	report.summarize("dict-decode", v.files.dict, SeverityError)
+	return nil
+}
+
+// Fix validates the dictionary, then rewrites .idx and .syn files sorted,
+// drops synonyms that reference missing entries and truncated records,
+// and updates wordcount, synwordcount and idxfilesize in .ifo file.
+// The returned report describes the dictionary before fixing.
+func Fix(ifoPath string) (*ValidationReport, error) {
+	v, err := newValidator(ifoPath)
+	if err != nil {
+		return nil, err
+	}
+	err = v.run()
+	if err != nil {
+		return nil, err
+	}
+	err = v.fix()
+	if err != nil {
+		return nil, err
+	}
+	return v.report, nil
+}
+
+func (v *validator) fix() error {
+	records := v.idxRecords
+	// newIndex maps old entry index to new one
+	newIndex := make([]int, len(records))
+	order := make([]int, len(records))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return CompareTerms(records[order[i]].term, records[order[j]].term) < 0
+	})
+	entries := make([]*IdxEntry, len(records))
+	for newI, oldI := range order {
+		newIndex[oldI] = newI
+		rec := records[oldI]
+		entries[newI] = &IdxEntry{
+			terms:  []string{rec.term},
+			offset: rec.offset,
+			size:   rec.size,
+		}
+	}
+	idxData := encodeIdx(entries, v.is64)
+	if !bytes.Equal(idxData, v.idxData) {
+		err := writeFileAtomic(v.files.idx, idxData)
+		if err != nil {
+			return err
+		}
+	}
+
+	updates := map[string]string{
+		I_wordcount:   strconv.Itoa(len(entries)),
+		I_idxfilesize: strconv.Itoa(len(idxData)),
+	}
+
+	if v.files.syn != "" {
+		synRecords := make([]synRecord, 0, len(v.synRecords))
+		for _, rec := range v.synRecords {
+			if int(rec.entryIndex) >= len(records) {
+				continue
+			}
+			rec.entryIndex = uint32(newIndex[rec.entryIndex])
+			synRecords = append(synRecords, rec)
+		}
+		sort.SliceStable(synRecords, func(i, j int) bool {
+			return CompareTerms(synRecords[i].term, synRecords[j].term) < 0
+		})
+		synData := encodeSyn(synRecords)
+		if !bytes.Equal(synData, v.synData) {
+			err := writeFileAtomic(v.files.syn, synData)
+			if err != nil {
+				return err
+			}
+		}
+		updates[I_synwordcount] = strconv.Itoa(len(synRecords))
+	}
+
+	return updateInfoFile(v.files.ifo, updates)
+}
+
+// updateInfoFile sets the given options in .ifo file, keeping other
+// lines as they are, and appending missing keys
+func updateInfoFile(ifoPath string, updates map[string]string) error {
+	info, err := ReadInfo(ifoPath)
+	if err != nil {
+		return err
+	}
+	for key, value := range updates {
+		info.Set(key, value)
+	}
+	return WriteInfo(ifoPath, info)
+}
@@ -0,0 +1,130 @@
+package stardict
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCompareTerms(t *testing.T) {
+	sorted := []string{"A", "a", "ab", "Abc", "abc", "b", "é"}
+	for i := 1; i < len(sorted); i++ {
+		if CompareTerms(sorted[i-1], sorted[i]) >= 0 {
+			t.Errorf("expected %#v < %#v", sorted[i-1], sorted[i])
+		}
+	}
+}
+
+func TestValidateAndFix(t *testing.T) {
+	dir := t.TempDir()
+	writeTestDict(t, dir, "test", []testEntry{
+		{terms: []string{"banana"}, defi: "a yellow fruit"},
+		{terms: []string{"apple", "apples"}, defi: "a fruit"},
+	})
+	ifoPath := filepath.Join(dir, "test.ifo")
+	data, _ := os.ReadFile(ifoPath)
+	data = []byte(strings.Replace(string(data), "wordcount=2", "wordcount=3", 1))
+	_ = os.WriteFile(ifoPath, data, 0o644)
+
+	report, err := Validate(ifoPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.IdxUnsorted || !report.HasErrors() {
+		t.Fatalf("expected unsorted idx error: %v", report.Problems)
+	}
+	var messages []string
+	for _, p := range report.Problems {
+		messages = append(messages, p.String())
+	}
+	if !strings.Contains(strings.Join(messages, "\n"), "wordcount=3 does not match actual value 2") {
+		t.Errorf("expected wordcount problem in:\n%s", strings.Join(messages, "\n"))
+	}
+
+	_, err = Fix(ifoPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, err = Validate(ifoPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Problems) > 0 {
+		t.Fatalf("unexpected problems after fix: %v", report.Problems)
+	}
+
+	d, err := NewDictionary(dir, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Load(); err != nil {
+		t.Fatal(err)
+	}
+	entry := d.idx.entries[0]
+	if strings.Join(entry.terms, ",") != "apple,apples" {
+		t.Errorf("unexpected terms of first entry after fix: %v", entry.terms)
+	}
+}
+
+func TestFixKeepsSortedSyn(t *testing.T) {
+	dir := t.TempDir()
+	writeTestDict(t, dir, "test", testEntries)
+	ifoPath := filepath.Join(dir, "test.ifo")
+	data, _ := os.ReadFile(ifoPath)
+	data = []byte(strings.Replace(string(data), "wordcount=3", "wordcount=4", 1))
+	_ = os.WriteFile(ifoPath, data, 0o644)
+	synPath := filepath.Join(dir, "test.syn")
+	oldTime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	if err := os.Chtimes(synPath, oldTime, oldTime); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Fix(ifoPath); err != nil {
+		t.Fatal(err)
+	}
+	stat, err := os.Stat(synPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !stat.ModTime().Equal(oldTime) {
+		t.Errorf("unchanged .syn file was rewritten")
+	}
+	report, err := Validate(ifoPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Problems) > 0 {
+		t.Fatalf("unexpected problems after fix: %v", report.Problems)
+	}
+}
+
+func TestValidateOffsetOverflow(t *testing.T) {
+	dir := t.TempDir()
+	writeTestDict(t, dir, "test", testEntries[:1])
+	// offset 1 with size 2^64-1 overflows offset+size
+	var idx bytes.Buffer
+	idx.WriteString("apple\x00")
+	_ = binary.Write(&idx, binary.BigEndian, uint64(1))
+	_ = binary.Write(&idx, binary.BigEndian, uint64(math.MaxUint64))
+	_ = os.WriteFile(filepath.Join(dir, "test.idx"), idx.Bytes(), 0o644)
+	_ = os.Remove(filepath.Join(dir, "test.syn"))
+	ifo := fmt.Sprintf(
+		"StarDict's dict ifo file\nversion=3.0.0\nbookname=test\nwordcount=1\nidxfilesize=%d\nidxoffsetbits=64\nsametypesequence=m\n",
+		idx.Len(),
+	)
+	_ = os.WriteFile(filepath.Join(dir, "test.ifo"), []byte(ifo), 0o644)
+
+	report, err := Validate(filepath.Join(dir, "test.ifo"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(fmt.Sprint(report.Problems), "exceeds dict size") {
+		t.Fatalf("expected bounds error: %v", report.Problems)
+	}
+}
@@ -0,0 +1,407 @@
+package stardict
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	common "codeberg.org/ilius/go-dict-commons"
+	"github.com/fsnotify/fsnotify"
+)
+
+// DefaultPollInterval is used by Watcher when polling, if PollInterval
+// is not set
+var DefaultPollInterval = 5 * time.Second
+
+// defaultDebounce is the default of WatchOptions.Debounce
+const defaultDebounce = 500 * time.Millisecond
+
+// ChangeKind is the kind of a ChangeEvent
+type ChangeKind uint8
+
+const (
+	ChangeAdded ChangeKind = iota
+	ChangeRemoved
+	ChangeModified
+)
+
+func (k ChangeKind) String() string {
+	switch k {
+	case ChangeAdded:
+		return "added"
+	case ChangeRemoved:
+		return "removed"
+	case ChangeModified:
+		return "modified"
+	}
+	return fmt.Sprintf("ChangeKind(%d)", k)
+}
+
+// ChangeEvent is passed to WatchOptions.OnChange after a dictionary
+// is added, removed or modified
+type ChangeEvent struct {
+	Kind ChangeKind
+	// Path is the path of .ifo file
+	Path     string
+	DictName string
+	// Dictionary is the new dictionary, or the removed one
+	Dictionary common.Dictionary
+	// Err is set if the added or modified dictionary fails to load,
+	// in that case the modified dictionary keeps its old instance
+	Err error
+}
+
+// WatchOptions are the options of Watch
+type WatchOptions struct {
+	OpenOptions
+
+	// Poll disables fsnotify, and directories are scanned every
+	// PollInterval instead. Polling is also used if fsnotify fails.
+	Poll         bool
+	PollInterval time.Duration
+
+	// Debounce is the time to wait after last file system event
+	// before scanning directories, 0 means 500ms
+	Debounce time.Duration
+
+	// OnChange is called after each change, from the watcher goroutine
+	OnChange func(ChangeEvent)
+}
+
+// watchedDict is the state of one .ifo file
+type watchedDict struct {
+	// dic is nil if the dictionary failed to initialize or is filtered out
+	dic       *dictionaryImp
+	signature string
+}
+
+// Watcher keeps a list of dictionaries in sync with directories.
+// Changed dictionaries are swapped atomically, searches in progress
+// continue using the old instances, which are closed afterwards.
+type Watcher struct {
+	opts         *WatchOptions
+	errorHandler func(error)
+
+	dicList atomic.Pointer[[]common.Dictionary]
+
+	// mu serializes scans
+	mu      sync.Mutex
+	dicts   map[string]*watchedDict
+	fsw     *fsnotify.Watcher
+	watched map[string]bool
+
+	done      chan struct{}
+	wg        sync.WaitGroup
+	closeOnce sync.Once
+	closeErr  error
+}
+
+// Watch opens dictionaries like OpenWithOptions, and starts watching
+// their directories for changes
+func Watch(opts *WatchOptions) (*Watcher, *LoadReport, error) {
+	o := newOpener(&opts.OpenOptions)
+	o.open()
+	w := &Watcher{
+		opts:         opts,
+		errorHandler: o.errorHandler,
+		dicts:        map[string]*watchedDict{},
+		watched:      map[string]bool{},
+		done:         make(chan struct{}),
+	}
+	for _, ifoPath := range o.ifoFiles {
+		w.dicts[ifoPath] = &watchedDict{signature: dictSignature(ifoPath)}
+	}
+	for _, dic := range o.dicList {
+		d := dic.(*dictionaryImp)
+		w.dicts[d.ifoPath].dic = d
+	}
+	w.dicList.Store(&o.dicList)
+	if !opts.Poll {
+		fsw, err := fsnotify.NewWatcher()
+		if err != nil {
+			o.logger.Warn("fsnotify is not available, polling directories", "err", err)
+		} else {
+			w.fsw = fsw
+			w.watchDirs(o.dirs)
+		}
+	}
+	w.wg.Add(1)
+	go w.run()
+	return w, o.report, nil
+}
+
+// Dictionaries returns the current list of dictionaries,
+// the returned slice must not be modified
+func (w *Watcher) Dictionaries() []common.Dictionary {
+	return *w.dicList.Load()
+}
+
+// Rescan scans directories and applies changes immediately
+func (w *Watcher) Rescan() {
+	w.rescan()
+}
+
+// Close stops watching, dictionaries are not closed.
+// Calling Close more than once returns the result of the first call.
+func (w *Watcher) Close() error {
+	w.closeOnce.Do(func() {
+		close(w.done)
+		w.wg.Wait()
+		if w.fsw != nil {
+			w.closeErr = w.fsw.Close()
+		}
+	})
+	return w.closeErr
+}
+
+func (w *Watcher) run() {
+	defer w.wg.Done()
+	var events <-chan fsnotify.Event
+	var errors <-chan error
+	var tick <-chan time.Time
+	if w.fsw != nil {
+		events = w.fsw.Events
+		errors = w.fsw.Errors
+	} else {
+		interval := w.opts.PollInterval
+		if interval <= 0 {
+			interval = DefaultPollInterval
+		}
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	debounce := w.opts.Debounce
+	if debounce <= 0 {
+		debounce = defaultDebounce
+	}
+	var timer *time.Timer
+	var timerC <-chan time.Time
+	for {
+		select {
+		case <-w.done:
+			if timer != nil {
+				timer.Stop()
+			}
+			return
+		case event, ok := <-events:
+			if !ok {
+				events = nil
+				continue
+			}
+			if event.Op == fsnotify.Chmod {
+				continue
+			}
+			// wait for copying of all files to finish
+			if timer == nil {
+				timer = time.NewTimer(debounce)
+			} else {
+				if !timer.Stop() {
+					select {
+					case <-timer.C:
+					default:
+					}
+				}
+				timer.Reset(debounce)
+			}
+			timerC = timer.C
+		case err, ok := <-errors:
+			if !ok {
+				errors = nil
+				continue
+			}
+			w.errorHandler(err)
+		case <-timerC:
+			timerC = nil
+			w.rescan()
+		case <-tick:
+			w.rescan()
+		}
+	}
+}
+
+func (w *Watcher) watchDirs(dirs []string) {
+	if w.fsw == nil {
+		return
+	}
+	for _, dir := range dirs {
+		if w.watched[dir] {
+			continue
+		}
+		err := w.fsw.Add(dir)
+		if err != nil {
+			w.errorHandler(err)
+			continue
+		}
+		w.watched[dir] = true
+	}
+	// directories that are removed are removed from fsnotify automatically
+	current := make(map[string]bool, len(dirs))
+	for _, dir := range dirs {
+		current[dir] = true
+	}
+	for dir := range w.watched {
+		if !current[dir] {
+			delete(w.watched, dir)
+		}
+	}
+}
+
+// rescan scans directories, opens added and modified dictionaries,
+// swaps the dictionary list and closes replaced dictionaries.
+// Dictionaries in directories that fail to read are kept.
+func (w *Watcher) rescan() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	o := newOpener(&w.opts.OpenOptions)
+	o.scan()
+	w.watchDirs(o.dirs)
+
+	signatures := map[string]string{}
+	for _, ifoPath := range o.ifoFiles {
+		signature := dictSignature(ifoPath)
+		signatures[ifoPath] = signature
+		old := w.dicts[ifoPath]
+		if old != nil && old.signature == signature {
+			continue
+		}
+		o.addDictionary(ifoPath)
+	}
+	if !o.opts.lazy() {
+		o.loadAll()
+	}
+	results := map[string]*DictLoadResult{}
+	for _, res := range o.report.Dictionaries {
+		results[res.Path] = res
+	}
+	for _, res := range o.results {
+		results[res.Path] = res
+	}
+
+	var events []ChangeEvent
+	var retired []*dictionaryImp
+	dicts := make(map[string]*watchedDict, len(o.ifoFiles))
+	dicList := make([]common.Dictionary, 0, len(o.ifoFiles))
+	for _, ifoPath := range o.ifoFiles {
+		signature := signatures[ifoPath]
+		old := w.dicts[ifoPath]
+		if old != nil && old.signature == signature {
+			dicts[ifoPath] = old
+			if old.dic != nil {
+				dicList = append(dicList, old.dic)
+			}
+			continue
+		}
+		var oldDic *dictionaryImp
+		if old != nil {
+			oldDic = old.dic
+		}
+		res := results[ifoPath]
+		switch {
+		case res == nil:
+			// filtered out
+			dicts[ifoPath] = &watchedDict{signature: signature}
+			if oldDic != nil {
+				retired = append(retired, oldDic)
+				events = append(events, ChangeEvent{
+					Kind:       ChangeRemoved,
+					Path:       ifoPath,
+					DictName:   oldDic.DictName(),
+					Dictionary: oldDic,
+				})
+			}
+			continue
+		case res.Status == LoadStatusFailed:
+			event := ChangeEvent{
+				Kind:     ChangeAdded,
+				Path:     ifoPath,
+				DictName: res.DictName,
+				Err:      res.Err,
+			}
+			if oldDic != nil {
+				event.Kind = ChangeModified
+				event.Dictionary = oldDic
+				dicList = append(dicList, oldDic)
+			}
+			if res.Dictionary != nil {
+				retired = append(retired, res.Dictionary.(*dictionaryImp))
+			}
+			dicts[ifoPath] = &watchedDict{dic: oldDic, signature: signature}
+			events = append(events, event)
+			continue
+		}
+		dic := res.Dictionary.(*dictionaryImp)
+		dicts[ifoPath] = &watchedDict{dic: dic, signature: signature}
+		dicList = append(dicList, dic)
+		event := ChangeEvent{
+			Kind:       ChangeAdded,
+			Path:       ifoPath,
+			DictName:   dic.DictName(),
+			Dictionary: dic,
+		}
+		if oldDic != nil {
+			event.Kind = ChangeModified
+			retired = append(retired, oldDic)
+		}
+		events = append(events, event)
+	}
+	for ifoPath, old := range w.dicts {
+		if _, ok := dicts[ifoPath]; ok {
+			continue
+		}
+		// keep dictionaries of unreadable directories until next scan
+		if o.inFailedDir(ifoPath) {
+			dicts[ifoPath] = old
+			if old.dic != nil {
+				dicList = append(dicList, old.dic)
+			}
+			continue
+		}
+		if old.dic == nil {
+			continue
+		}
+		retired = append(retired, old.dic)
+		events = append(events, ChangeEvent{
+			Kind:       ChangeRemoved,
+			Path:       ifoPath,
+			DictName:   old.dic.DictName(),
+			Dictionary: old.dic,
+		})
+	}
+
+	w.dicts = dicts
+	w.dicList.Store(&dicList)
+
+	for _, dic := range retired {
+		if w.opts.Pool != nil {
+			w.opts.Pool.Remove(dic)
+		}
+		// Close waits for searches in progress
+		go dic.Close()
+	}
+	if w.opts.OnChange != nil {
+		for _, event := range events {
+			w.opts.OnChange(event)
+		}
+	}
+}
+
+// dictSignature returns a string that changes when any file of
+// the dictionary is added, removed or modified
+func dictSignature(ifoPath string) string {
+	base := strings.TrimSuffix(ifoPath, ifoExt)
+	var sb strings.Builder
+	for _, ext := range []string{ifoExt, ".idx", ".tdx", ".tdx.gz", ".syn", ".dict", ".dict.dz"} {
+		stat, err := os.Stat(base + ext)
+		if err != nil {
+			sb.WriteString("-;")
+			continue
+		}
+		fmt.Fprintf(&sb, "%d:%d;", stat.Size(), stat.ModTime().UnixNano())
+	}
+	return sb.String()
+}
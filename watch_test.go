@@ -0,0 +1,178 @@
+package stardict
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWatcher(t *testing.T) {
+	dir := t.TempDir()
+	writeTestDict(t, dir, "first", testEntries)
+
+	var events []ChangeEvent
+	w, _, err := Watch(&WatchOptions{
+		OpenOptions: OpenOptions{Dirs: []string{dir}},
+		Poll:        true,
+		// scans are triggered by Rescan in this test
+		PollInterval: time.Hour,
+		OnChange: func(event ChangeEvent) {
+			events = append(events, event)
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	first := w.Dictionaries()
+	if len(first) != 1 {
+		t.Fatalf("expected 1 dictionary, got %d", len(first))
+	}
+
+	expectEvent := func(kind ChangeKind, dictName string, count int) {
+		t.Helper()
+		if len(events) != 1 || events[0].Kind != kind || events[0].DictName != dictName || events[0].Err != nil {
+			t.Fatalf("expected %v event for %s, got %+v", kind, dictName, events)
+		}
+		if n := len(w.Dictionaries()); n != count {
+			t.Fatalf("expected %d dictionaries, got %d", count, n)
+		}
+		events = nil
+	}
+
+	w.Rescan()
+	if len(events) != 0 {
+		t.Fatalf("unexpected events: %+v", events)
+	}
+
+	writeTestDict(t, dir, "second", testEntries)
+	w.Rescan()
+	expectEvent(ChangeAdded, "second", 2)
+
+	writeTestDict(t, dir, "first", testEntries[:1])
+	w.Rescan()
+	expectEvent(ChangeModified, "first", 2)
+	for _, dic := range w.Dictionaries() {
+		if dic.DictName() == "first" && dic == first[0] {
+			t.Fatal("modified dictionary is not replaced")
+		}
+	}
+
+	for _, ext := range []string{".ifo", ".idx", ".dict", ".syn"} {
+		_ = os.Remove(filepath.Join(dir, "second"+ext))
+	}
+	w.Rescan()
+	expectEvent(ChangeRemoved, "second", 1)
+}
+
+func TestWatcherNotify(t *testing.T) {
+	dir := t.TempDir()
+	changes := make(chan ChangeEvent, 10)
+	w, _, err := Watch(&WatchOptions{
+		OpenOptions: OpenOptions{Dirs: []string{dir}},
+		Debounce:    10 * time.Millisecond,
+		OnChange: func(event ChangeEvent) {
+			changes <- event
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	if w.fsw == nil {
+		t.Skip("fsnotify is not available")
+	}
+	writeTestDict(t, dir, "test", testEntries)
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case event := <-changes:
+			// a scan may happen while files are being written
+			if event.Err != nil {
+				continue
+			}
+			if event.Kind != ChangeAdded && event.Kind != ChangeModified {
+				t.Fatalf("unexpected event %+v", event)
+			}
+			return
+		case <-timeout:
+			t.Fatal("timeout waiting for change event")
+		}
+	}
+}
+
+func TestWatcherUnreadableDir(t *testing.T) {
+	parent := t.TempDir()
+	dir := filepath.Join(parent, "dicts")
+	if err := os.Mkdir(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	writeTestDict(t, dir, "first", testEntries)
+
+	var events []ChangeEvent
+	w, _, err := Watch(&WatchOptions{
+		OpenOptions: OpenOptions{
+			Dirs:         []string{dir},
+			ErrorHandler: func(error) {},
+		},
+		Poll:         true,
+		PollInterval: time.Hour,
+		OnChange: func(event ChangeEvent) {
+			events = append(events, event)
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	first := w.Dictionaries()
+
+	// a file in place of directory can not be read
+	moved := filepath.Join(parent, "moved")
+	if err := os.Rename(dir, moved); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(dir, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	w.Rescan()
+	if len(events) != 0 || len(w.Dictionaries()) != 1 || w.Dictionaries()[0] != first[0] {
+		t.Fatalf("dictionaries of unreadable directory are not kept: %+v", events)
+	}
+
+	_ = os.Remove(dir)
+	if err := os.Rename(moved, dir); err != nil {
+		t.Fatal(err)
+	}
+	w.Rescan()
+	if len(events) != 0 || len(w.Dictionaries()) != 1 || w.Dictionaries()[0] != first[0] {
+		t.Fatalf("unexpected change after directory is readable: %+v", events)
+	}
+
+	// a removed directory removes its dictionaries
+	if err := os.RemoveAll(dir); err != nil {
+		t.Fatal(err)
+	}
+	w.Rescan()
+	if len(events) != 1 || events[0].Kind != ChangeRemoved || len(w.Dictionaries()) != 0 {
+		t.Fatalf("expected removed event, got %+v", events)
+	}
+}
+
+func TestWatcherCloseTwice(t *testing.T) {
+	dir := t.TempDir()
+	writeTestDict(t, dir, "test", testEntries)
+	w, _, err := Watch(&WatchOptions{
+		OpenOptions: OpenOptions{Dirs: []string{dir}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("second Close: %v", err)
+	}
+}
@@ -0,0 +1,355 @@
+package stardict
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	common "codeberg.org/ilius/go-dict-commons"
+	"github.com/ilius/go-stardict/v2/dictzip"
+)
+
+// asciiLower lowercases ASCII letters only, like g_ascii_tolower
+func asciiLower(c byte) byte {
+	if c >= 'A' && c <= 'Z' {
+		return c + 'a' - 'A'
+	}
+	return c
+}
+
+// CompareTerms compares two terms the way StarDict sorts .idx and .syn
+// files: case-insensitive for ASCII letters (g_ascii_strcasecmp), then
+// byte-wise (strcmp) to break ties.
+func CompareTerms(a string, b string) int {
+	n := min(len(a), len(b))
+	for i := range n {
+		ca, cb := asciiLower(a[i]), asciiLower(b[i])
+		if ca != cb {
+			return int(ca) - int(cb)
+		}
+	}
+	if len(a) != len(b) {
+		return len(a) - len(b)
+	}
+	for i := range n {
+		if a[i] != b[i] {
+			return int(a[i]) - int(b[i])
+		}
+	}
+	return 0
+}
+
+type synRecord struct {
+	term       string
+	entryIndex uint32
+}
+
+// encodeIdx returns the content of .idx file, using the first term of
+// each entry as headword
+func encodeIdx(entries []*IdxEntry, is64 bool) []byte {
+	buf := bytes.NewBuffer(nil)
+	for _, entry := range entries {
+		buf.WriteString(entry.terms[0])
+		buf.WriteByte(0)
+		if is64 {
+			_ = binary.Write(buf, binary.BigEndian, entry.offset)
+			_ = binary.Write(buf, binary.BigEndian, entry.size)
+			continue
+		}
+		_ = binary.Write(buf, binary.BigEndian, uint32(entry.offset))
+		_ = binary.Write(buf, binary.BigEndian, uint32(entry.size))
+	}
+	return buf.Bytes()
+}
+
+// encodeSyn returns the content of .syn file
+func encodeSyn(records []synRecord) []byte {
+	buf := bytes.NewBuffer(nil)
+	for _, rec := range records {
+		buf.WriteString(rec.term)
+		buf.WriteByte(0)
+		_ = binary.Write(buf, binary.BigEndian, rec.entryIndex)
+	}
+	return buf.Bytes()
+}
+
+// writeFileAtomic writes data into a temporary file in the same directory
+// and renames it, so a failure never leaves a half-written file.
+// The file keeps the mode of the file it replaces, new files get 0644
+// minus umask.
+func writeFileAtomic(fpath string, data []byte) error {
+	perm := os.FileMode(0o644)
+	stat, statErr := os.Stat(fpath)
+	if statErr == nil {
+		perm = stat.Mode().Perm()
+	}
+	file, err := createTempFile(filepath.Dir(fpath), filepath.Base(fpath), perm)
+	if err != nil {
+		return err
+	}
+	tmpPath := file.Name()
+	// umask applies to created files, but not to the mode we keep
+	if statErr == nil {
+		err = file.Chmod(perm)
+	}
+	if err == nil {
+		_, err = file.Write(data)
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(tmpPath)
+		return err
+	}
+	return os.Rename(tmpPath, fpath)
+}
+
+// createTempFile is like os.CreateTemp, but with the given mode
+// instead of 0600
+func createTempFile(dir string, name string, perm os.FileMode) (*os.File, error) {
+	for try := 0; ; try++ {
+		tmpPath := filepath.Join(dir, fmt.Sprintf(".tmp-%d-%s", rand.Uint32(), name))
+		file, err := os.OpenFile(tmpPath, os.O_RDWR|os.O_CREATE|os.O_EXCL, perm)
+		if os.IsExist(err) && try < 100 {
+			continue
+		}
+		return file, err
+	}
+}
+
+// encodeItems encodes items into article data, the inverse of decodeItems
+func encodeItems(items []*common.SearchResultItem, seq string) ([]byte, error) {
+	if seq != "" && len(items) != len(seq) {
+		return nil, fmt.Errorf("%d items do not match sametypesequence %#v", len(items), seq)
+	}
+	buf := bytes.NewBuffer(nil)
+	for i, item := range items {
+		if item.Type > math.MaxUint8 || !isLowerItemType(byte(item.Type)) && !isUpperItemType(byte(item.Type)) {
+			return nil, fmt.Errorf("invalid item type %q", item.Type)
+		}
+		t := byte(item.Type)
+		last := i == len(items)-1
+		if seq != "" {
+			if seq[i] != t {
+				return nil, fmt.Errorf("item type %q does not match sametypesequence %#v", t, seq)
+			}
+		} else {
+			buf.WriteByte(t)
+		}
+		if isLowerItemType(t) {
+			if bytes.IndexByte(item.Data, 0) >= 0 {
+				return nil, fmt.Errorf("%q item contains NUL", t)
+			}
+			buf.Write(item.Data)
+			if !last || seq == "" {
+				buf.WriteByte(0)
+			}
+			continue
+		}
+		if !last || seq == "" {
+			_ = binary.Write(buf, binary.BigEndian, uint32(len(item.Data)))
+		}
+		buf.Write(item.Data)
+	}
+	return buf.Bytes(), nil
+}
+
+// ErrWriterClosed is returned by Writer methods after Close
+var ErrWriterClosed = errors.New("writer is closed")
+
+type writerEntry struct {
+	terms []string
+	data  []byte
+}
+
+// Writer creates a StarDict dictionary like stardict-editor does:
+// .idx sorted by CompareTerms, synonyms in sorted .syn file, articles
+// in .dict in the same order as .idx, and counts in .ifo.
+// Entries are kept in memory until Close.
+type Writer struct {
+	// Options are written into .ifo file, like bookname, description and
+	// sametypesequence. Counts and sizes are set by Close.
+	Options map[string]string
+
+	// Compress writes .dict.dz (dictzip) instead of .dict
+	Compress bool
+
+	dir     string
+	name    string
+	entries []*writerEntry
+	closed  bool
+}
+
+// NewWriter returns a Writer that creates dictionary files in dir,
+// named name.ifo, name.idx etc
+func NewWriter(dir string, name string, bookname string) *Writer {
+	return &Writer{
+		Options: map[string]string{
+			I_bookname: bookname,
+		},
+		dir:  dir,
+		name: name,
+	}
+}
+
+// Add adds an entry, terms are headword followed by synonyms, and data
+// is the article encoded according to sametypesequence option
+func (w *Writer) Add(terms []string, data []byte) error {
+	if w.closed {
+		return ErrWriterClosed
+	}
+	if err := checkTerms(terms); err != nil {
+		return err
+	}
+	w.entries = append(w.entries, &writerEntry{
+		terms: terms,
+		data:  data,
+	})
+	return nil
+}
+
+// checkTerms returns an error if terms of an entry can not be written
+func checkTerms(terms []string) error {
+	if len(terms) == 0 || terms[0] == "" {
+		return errors.New("entry without headword")
+	}
+	for _, term := range terms {
+		if len(term) >= MAX_TERM_LENGTH {
+			return fmt.Errorf("term is longer than %d bytes: %#v", MAX_TERM_LENGTH-1, term)
+		}
+		if strings.IndexByte(term, 0) >= 0 {
+			return fmt.Errorf("term contains NUL: %#v", term)
+		}
+	}
+	return nil
+}
+
+// AddItems is like Add, but encodes items according to sametypesequence
+func (w *Writer) AddItems(terms []string, items []*common.SearchResultItem) error {
+	if err := checkTerms(terms); err != nil {
+		return err
+	}
+	data, err := encodeItems(items, w.Options[I_sametypesequence])
+	if err != nil {
+		return fmt.Errorf("%#v: %w", terms[0], err)
+	}
+	return w.Add(terms, data)
+}
+
+// EntryCount returns the number of added entries
+func (w *Writer) EntryCount() int {
+	return len(w.entries)
+}
+
+// Close writes the dictionary files, replacing existing ones
+func (w *Writer) Close() error {
+	if w.closed {
+		return ErrWriterClosed
+	}
+	w.closed = true
+	entries := w.entries
+	w.entries = nil
+	sort.SliceStable(entries, func(i, j int) bool {
+		return CompareTerms(entries[i].terms[0], entries[j].terms[0]) < 0
+	})
+
+	var dictBuf bytes.Buffer
+	idxEntries := make([]*IdxEntry, len(entries))
+	var synRecords []synRecord
+	for i, entry := range entries {
+		idxEntries[i] = &IdxEntry{
+			terms:  entry.terms[:1],
+			offset: uint64(dictBuf.Len()),
+			size:   uint64(len(entry.data)),
+		}
+		dictBuf.Write(entry.data)
+		for _, syn := range entry.terms[1:] {
+			if syn == entry.terms[0] {
+				continue
+			}
+			synRecords = append(synRecords, synRecord{
+				term:       syn,
+				entryIndex: uint32(i),
+			})
+		}
+	}
+	sort.SliceStable(synRecords, func(i, j int) bool {
+		return CompareTerms(synRecords[i].term, synRecords[j].term) < 0
+	})
+	is64 := dictBuf.Len() > math.MaxUint32
+
+	options := make(map[string]string, len(w.Options)+4)
+	for key, value := range w.Options {
+		options[key] = value
+	}
+	idxData := encodeIdx(idxEntries, is64)
+	options[I_wordcount] = strconv.Itoa(len(entries))
+	options[I_idxfilesize] = strconv.Itoa(len(idxData))
+	delete(options, I_synwordcount)
+	if len(synRecords) > 0 {
+		options[I_synwordcount] = strconv.Itoa(len(synRecords))
+	}
+	delete(options, I_idxoffsetbits)
+	if is64 {
+		options[I_idxoffsetbits] = "64"
+	}
+
+	basePath := filepath.Join(w.dir, w.name)
+	dictData := dictBuf.Bytes()
+	dictPath := basePath + ".dict"
+	if w.Compress {
+		var dzBuf bytes.Buffer
+		dz := dictzip.NewWriter(&dzBuf)
+		_, _ = dz.Write(dictData)
+		err := dz.Close()
+		if err != nil {
+			return err
+		}
+		dictData = dzBuf.Bytes()
+		dictPath += ".dz"
+	}
+	err := writeFileAtomic(dictPath, dictData)
+	if err != nil {
+		return err
+	}
+	// remove the other variant, since .dict is preferred over .dict.dz
+	otherPath := basePath + ".dict.dz"
+	if w.Compress {
+		otherPath = basePath + ".dict"
+	}
+	err = removeIfExists(otherPath)
+	if err != nil {
+		return err
+	}
+	err = writeFileAtomic(basePath+".idx", idxData)
+	if err != nil {
+		return err
+	}
+	if len(synRecords) > 0 {
+		err = writeFileAtomic(basePath+".syn", encodeSyn(synRecords))
+	} else {
+		err = removeIfExists(basePath + ".syn")
+	}
+	if err != nil {
+		return err
+	}
+	// .ifo is written last, so dictionary is not found before it is complete
+	return writeFileAtomic(basePath+".ifo", newInfo(options).Encode())
+}
+
+func removeIfExists(fpath string) error {
+	err := os.Remove(fpath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
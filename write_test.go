@@ -0,0 +1,137 @@
+package stardict
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+
+	common "codeberg.org/ilius/go-dict-commons"
+)
+
+func TestEncodeItems(t *testing.T) {
+	items := []*common.SearchResultItem{
+		{Type: 'm', Data: []byte("text")},
+		{Type: 'P', Data: []byte("\x00\x01")},
+		{Type: 'h', Data: []byte("<b>x</b>")},
+	}
+	for _, seq := range []string{"", "mPh"} {
+		data, err := encodeItems(items, seq)
+		if err != nil {
+			t.Fatal(err)
+		}
+		decoded, err := decodeItems(data, seq)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(decoded, items) {
+			t.Fatalf("seq=%#v: round trip mismatch: %v", seq, decoded)
+		}
+	}
+	if _, err := encodeItems(items, "mhh"); err == nil {
+		t.Fatal("expected error for mismatching sametypesequence")
+	}
+}
+
+func TestWriter(t *testing.T) {
+	dir := t.TempDir()
+	for _, compress := range []bool{false, true} {
+		w := NewWriter(dir, "out", "Test")
+		w.Options[I_sametypesequence] = "m"
+		w.Options[I_description] = "line 1\nline 2"
+		w.Compress = compress
+		for _, entry := range []testEntry{
+			{terms: []string{"banana"}, defi: "a yellow fruit"},
+			{terms: []string{"Apple", "apples", "pomme"}, defi: "a fruit"},
+			{terms: []string{"apple"}, defi: "lowercase"},
+		} {
+			if err := w.Add(entry.terms, []byte(entry.defi)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Add([]string{"x"}, nil); err != ErrWriterClosed {
+			t.Fatalf("expected ErrWriterClosed, got %v", err)
+		}
+		_, errDict := os.Stat(filepath.Join(dir, "out.dict"))
+		if os.IsNotExist(errDict) != compress {
+			t.Fatalf("compress=%v: unexpected .dict file state: %v", compress, errDict)
+		}
+
+		report, err := Validate(filepath.Join(dir, "out.ifo"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(report.Problems) > 0 {
+			t.Fatalf("unexpected problems: %v", report.Problems)
+		}
+		d, err := NewDictionary(dir, "out")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d.Description() != "line 1\nline 2" {
+			t.Fatalf("unexpected description %#v", d.Description())
+		}
+		if err := d.Load(); err != nil {
+			t.Fatal(err)
+		}
+		var terms [][]string
+		_ = d.WalkEntries(func(_ int, entry *IdxEntry) bool {
+			terms = append(terms, entry.Terms())
+			return true
+		})
+		expected := [][]string{{"Apple", "apples", "pomme"}, {"apple"}, {"banana"}}
+		if !reflect.DeepEqual(terms, expected) {
+			t.Fatalf("unexpected terms: %v", terms)
+		}
+		res := d.SearchExact("pomme", 0, 0)
+		if len(res) != 1 || string(res[0].Items()[0].Data) != "a fruit" {
+			t.Fatalf("unexpected results: %v", res)
+		}
+		d.Close()
+	}
+}
+
+func TestWriteFileAtomicMode(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("file modes are not supported")
+	}
+	dir := t.TempDir()
+	// expected mode of new files, 0644 minus umask
+	refPath := filepath.Join(dir, "ref")
+	_ = os.WriteFile(refPath, nil, 0o644)
+	refStat, _ := os.Stat(refPath)
+
+	newPath := filepath.Join(dir, "new.ifo")
+	if err := writeFileAtomic(newPath, []byte("new")); err != nil {
+		t.Fatal(err)
+	}
+	if stat, _ := os.Stat(newPath); stat.Mode().Perm() != refStat.Mode().Perm() {
+		t.Fatalf("expected mode %v, got %v", refStat.Mode().Perm(), stat.Mode().Perm())
+	}
+
+	oldPath := filepath.Join(dir, "old.ifo")
+	_ = os.WriteFile(oldPath, []byte("old"), 0o644)
+	_ = os.Chmod(oldPath, 0o664)
+	if err := writeFileAtomic(oldPath, []byte("replaced")); err != nil {
+		t.Fatal(err)
+	}
+	if stat, _ := os.Stat(oldPath); stat.Mode().Perm() != 0o664 {
+		t.Fatalf("expected mode 0664, got %v", stat.Mode().Perm())
+	}
+}
+
+func TestWriterAddItemsNoHeadword(t *testing.T) {
+	w := NewWriter(t.TempDir(), "out", "Test")
+	w.Options[I_sametypesequence] = "m"
+	items := []*common.SearchResultItem{{Type: 'x', Data: []byte("a")}}
+	for _, terms := range [][]string{nil, {""}} {
+		if err := w.AddItems(terms, items); err == nil || !strings.Contains(err.Error(), "headword") {
+			t.Fatalf("expected headword error for %#v, got %v", terms, err)
+		}
+	}
+}